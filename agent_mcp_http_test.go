@@ -0,0 +1,100 @@
+package robby
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestAgentWithMCPHTTPClient(t *testing.T) {
+	for _, streamSSE := range []bool{false, true} {
+		mcpServer := newFakeMCPServer(t, "calc", "add", "multiply")
+		mcpServer.streamSSE = streamSSE
+
+		bob, err := NewAgent(
+			WithDMRClient(context.Background(), "http://localhost/v1/"),
+			WithMCPHTTPClient(mcpServer.server.URL+"/mcp", map[string]string{"Authorization": "Bearer secret"}),
+			WithMCPTools([]string{"add"}),
+			WithMCPResources(nil),
+			WithMCPPrompts(nil),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bob.Tools) != 1 || bob.Tools[0].Function.Name != "add" {
+			t.Fatalf("unexpected tools: %+v", bob.Tools)
+		}
+		if len(bob.Resources) != 1 || len(bob.Prompts) != 1 {
+			t.Fatalf("unexpected resources or prompts: %+v %+v", bob.Resources, bob.Prompts)
+		}
+
+		bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+			{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "add", Arguments: `{"name": "Bob"}`}},
+		}
+		results, err := bob.ExecuteMCPToolCalls()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[0] != "calc:add:name=Bob" {
+			t.Fatalf("unexpected result: %q", results[0])
+		}
+
+		resource, err := bob.ReadResource("calc://info")
+		if err != nil || resource.Text != "content of calc://info" || resource.Name != "calc-info" {
+			t.Fatalf("unexpected resource: %+v, %v", resource, err)
+		}
+
+		headers := mcpServer.Headers()
+		last := headers[len(headers)-1]
+		if last.Get("Authorization") != "Bearer secret" || last.Get("Mcp-Session-Id") != "session-calc" {
+			t.Fatalf("headers or session not sent: %v", last)
+		}
+	}
+}
+
+func TestAgentWithMCPSSEClient(t *testing.T) {
+	mcpServer := newFakeMCPServer(t, "search", "brave_web_search")
+
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPSSEClient(mcpServer.server.URL+"/sse", map[string]string{"X-Api-Key": "key"}),
+		WithMCPTools(nil),
+		WithMCPPrompts(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bob.Tools) != 1 || bob.Tools[0].Function.Name != "brave_web_search" {
+		t.Fatalf("unexpected tools: %+v", bob.Tools)
+	}
+
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "brave_web_search", Arguments: `{"name": "pizza"}`}},
+	}
+	results, err := bob.ExecuteMCPToolCalls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0] != "search:brave_web_search:name=pizza" {
+		t.Fatalf("unexpected result: %q", results[0])
+	}
+
+	prompt, err := bob.GetPrompt("search-greet", map[string]string{"name": "Bob"})
+	if err != nil || len(prompt.Messages) != 1 || prompt.Messages[0].Content.Text != "Hello from search" {
+		t.Fatalf("unexpected prompt: %+v, %v", prompt, err)
+	}
+	if mcpServer.Headers()[0].Get("X-Api-Key") != "key" {
+		t.Fatal("custom header not sent")
+	}
+}
+
+func TestMCPOptionsWithoutClient(t *testing.T) {
+	_, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPTools(nil),
+	)
+	if err == nil {
+		t.Fatal("expected an error when using WithMCPTools without MCP client")
+	}
+}
@@ -0,0 +1,141 @@
+package robby
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// syncBuffer is a strings.Builder safe for concurrent use.
+type syncBuffer struct {
+	mu      sync.Mutex
+	builder strings.Builder
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.builder.Write(data)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.builder.String()
+}
+
+func TestAgentCloseStopsMCPServer(t *testing.T) {
+	stderr := &syncBuffer{}
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPStderr(stderr),
+		WithNamedMCPClient("calc", fakeStdioMCPServerCommand(t, "calc", "", "add")),
+		WithMCPTools(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "add", Arguments: `{"name": "Bob"}`}},
+	}
+	results, err := bob.ExecuteMCPToolCalls()
+	if err != nil || results[0] != "calc:add:name=Bob" {
+		t.Fatalf("unexpected results: %v, %v", results, err)
+	}
+
+	cmd := bob.mcpServers[0].conn.cmd
+	if err := bob.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.ProcessState == nil || !cmd.ProcessState.Exited() {
+		t.Fatal("the MCP server process is still running")
+	}
+	if !strings.Contains(stderr.String(), "[calc] server calc started\n") {
+		t.Fatalf("stderr not captured: %q", stderr.String())
+	}
+	if err := bob.Ping(); err == nil {
+		t.Fatal("expected an error when pinging a closed agent")
+	}
+	if err := bob.Close(); err != nil {
+		t.Fatalf("Close must be idempotent: %v", err)
+	}
+}
+
+func TestNewAgentErrorStopsMCPServer(t *testing.T) {
+	var started *Agent
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithNamedMCPClient("calc", fakeStdioMCPServerCommand(t, "calc", "", "add")),
+		func(agent *Agent) { started = agent },
+		WithRAGConfig(RAGConfig{Template: "{{ .Chunks"}),
+	)
+	if bob != nil || err == nil {
+		t.Fatalf("expected an error and no agent, got %v, %v", bob, err)
+	}
+	if len(started.mcpServers) != 0 {
+		t.Fatal("the MCP server of the failed agent is still running")
+	}
+}
+
+func TestAgentDetectsMCPServerExit(t *testing.T) {
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPClient(fakeStdioMCPServerCommand(t, "calc", "", "add", "crash")),
+		WithMCPTools(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "crash", Arguments: `{}`}},
+	}
+	start := time.Now()
+	results, err := bob.ExecuteMCPToolCallsWithResults()
+	if err != nil || results[0].Error == nil {
+		t.Fatalf("expected a tool call error: %+v, %v", results, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("the exit of the server was not detected")
+	}
+
+	err = bob.Ping()
+	if err == nil || !strings.Contains(err.Error(), "exited unexpectedly") || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("unexpected ping error: %v", err)
+	}
+
+	bob.ToolCalls[0].Function.Name = "add"
+	results, _ = bob.ExecuteMCPToolCallsWithResults()
+	if results[0].Error == nil || !strings.Contains(results[0].Error.Error(), "exited unexpectedly") {
+		t.Fatalf("unexpected result after the exit of the server: %+v", results[0])
+	}
+}
+
+func TestAgentCloseKillsHangingMCPServer(t *testing.T) {
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPShutdownTimeout(200*time.Millisecond),
+		WithMCPClient(fakeStdioMCPServerCommand(t, "calc", "hang", "add")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = bob.Close()
+	if err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatalf("expected the server to be killed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("Close took too long: %v", elapsed)
+	}
+}
@@ -0,0 +1,133 @@
+package robby
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+func TestAgentReconnectsMCPServer(t *testing.T) {
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPReconnect(MCPReconnectPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond}),
+		WithMCPClient(fakeStdioMCPServerCommand(t, "calc", "", "add", "crash")),
+		WithMCPTools(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+	firstProcess := bob.mcpServers[0].conn.cmd
+
+	// The crash tool crashes the server (and the new server, when the call is retried)
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "crash", Arguments: `{}`}},
+	}
+	results, _ := bob.ExecuteMCPToolCallsWithResults()
+	if results[0].Error == nil {
+		t.Fatal("expected an error for the crash tool")
+	}
+
+	// The next call re-spawns the server
+	bob.ToolCalls[0].Function.Name = "add"
+	bob.ToolCalls[0].Function.Arguments = `{"name": "Bob"}`
+	results, _ = bob.ExecuteMCPToolCallsWithResults()
+	if results[0].Error != nil || results[0].Output != "calc:add:name=Bob" {
+		t.Fatalf("unexpected result after the reconnection: %+v", results[0])
+	}
+	if bob.mcpServers[0].conn.cmd == firstProcess {
+		t.Fatal("the server process was not started again")
+	}
+	if err := bob.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bob.Tools) != 2 {
+		t.Fatalf("the tools must be kept after the reconnection: %+v", bob.Tools)
+	}
+}
+
+func TestAgentReconnectsExpiredMCPSession(t *testing.T) {
+	mcpServer := newFakeMCPServer(t, "calc", "add")
+	bob, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPReconnect(MCPReconnectPolicy{InitialBackoff: 10 * time.Millisecond}),
+		WithMCPHTTPClient(mcpServer.server.URL+"/mcp", nil),
+		WithMCPTools(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+
+	mcpServer.expireSession()
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "add", Arguments: `{"name": "Bob"}`}},
+	}
+	results, _ := bob.ExecuteMCPToolCallsWithResults()
+	if results[0].Error != nil || results[0].Output != "calc:add:name=Bob" {
+		t.Fatalf("unexpected result after the session expiration: %+v", results[0])
+	}
+	headers := mcpServer.Headers()
+	if session := headers[len(headers)-1].Get("Mcp-Session-Id"); session != "session-calc-1" {
+		t.Fatalf("a new session was not initialized: %q", session)
+	}
+}
+
+func TestAgentRefreshesMCPToolsOnListChanged(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		if index == 0 {
+			return map[string]any{
+				"role":       "assistant",
+				"tool_calls": []map[string]any{fakeToolCall("call_1", "change", `{}`)},
+			}
+		}
+		return map[string]any{"role": "assistant", "content": "done"}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Change the tools")},
+		}),
+		WithTools([]openai.ChatCompletionToolParam{
+			{Function: openai.FunctionDefinitionParam{Name: "say_hello"}},
+		}),
+		WithMCPClient(fakeStdioMCPServerCommand(t, "calc", "", "add", "change")),
+		WithMCPTools(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+
+	// The server sends the notification before the result of the "change" tool:
+	// the second completion must use the new tools list
+	_, _, err = bob.RunToolLoop(map[string]func(any) (any, error){
+		"say_hello": func(args any) (any, error) { return "hello", nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toolNames := func(tools any) string {
+		names := []string{}
+		for _, tool := range tools.([]any) {
+			names = append(names, tool.(map[string]any)["function"].(map[string]any)["name"].(string))
+		}
+		return strings.Join(names, ",")
+	}
+	requests := fake.Requests()
+	if names := toolNames(requests[0]["tools"]); names != "say_hello,add,change" {
+		t.Fatalf("unexpected tools in the first request: %s", names)
+	}
+	if names := toolNames(requests[1]["tools"]); names != "say_hello,add,change,subtract" {
+		t.Fatalf("the tools were not refreshed: %s", names)
+	}
+	if len(bob.Tools) != 4 {
+		t.Fatalf("unexpected tools: %+v", bob.Tools)
+	}
+}
@@ -0,0 +1,111 @@
+package robby
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestAgentWithSeveralMCPServers(t *testing.T) {
+	calc := newFakeMCPServer(t, "calc", "add", "multiply")
+	search := newFakeMCPServer(t, "search", "add", "brave_web_search")
+
+	// Both servers advertise "add": the names must be prefixed
+	_, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithNamedMCPHTTPClient("calc", calc.server.URL+"/mcp", nil),
+		WithNamedMCPSSEClient("search", search.server.URL+"/sse", nil),
+		WithMCPTools(nil),
+	)
+	if err == nil || !strings.Contains(err.Error(), "WithMCPToolsPrefix") {
+		t.Fatalf("expected a collision error, got %v", err)
+	}
+
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		if index == 0 {
+			return map[string]any{
+				"role": "assistant",
+				"tool_calls": []map[string]any{
+					fakeToolCall("call_1", "calc__add", `{"name": "one"}`),
+					fakeToolCall("call_2", "search__add", `{"name": "two"}`),
+					fakeToolCall("call_3", "search__brave_web_search", `{"name": "pizza"}`),
+					fakeToolCall("call_4", "say_hello", `{"name": "Bob"}`),
+				},
+			}
+		}
+		return map[string]any{"role": "assistant", "content": "done"}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Do everything")},
+		}),
+		WithTools([]openai.ChatCompletionToolParam{
+			{Function: openai.FunctionDefinitionParam{Name: "say_hello"}},
+		}),
+		WithNamedMCPHTTPClient("calc", calc.server.URL+"/mcp", nil),
+		WithNamedMCPSSEClient("search", search.server.URL+"/sse", nil),
+		WithMCPToolsPrefix(),
+		WithMCPTools([]string{"calc__add", "search__add", "brave_web_search"}),
+		WithMCPResources(nil),
+		WithMCPPrompts(nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, tool := range bob.Tools {
+		names = append(names, tool.Function.Name)
+	}
+	if strings.Join(names, ",") != "say_hello,calc__add,search__add,search__brave_web_search" {
+		t.Fatalf("unexpected tools: %v", names)
+	}
+	if strings.Join(bob.MCPServers(), ",") != "calc,search" {
+		t.Fatalf("unexpected servers: %v", bob.MCPServers())
+	}
+
+	answer, steps, err := bob.RunToolLoop(map[string]func(any) (any, error){
+		"say_hello": func(args any) (any, error) {
+			return "hello " + args.(map[string]any)["name"].(string), nil
+		},
+	})
+	if err != nil || answer != "done" {
+		t.Fatalf("unexpected answer: %q, %v", answer, err)
+	}
+	expected := []string{"calc:add:name=one", "search:add:name=two", "search:brave_web_search:name=pizza", "hello Bob"}
+	for i, result := range steps[0].Results {
+		if result.Error != nil || result.Output != expected[i] {
+			t.Fatalf("result %d: unexpected result %+v", i, result)
+		}
+	}
+
+	// The resources and prompts are aggregated, and read from the server that advertised them
+	if len(bob.Resources) != 2 || bob.Resources[1].Server != "search" {
+		t.Fatalf("unexpected resources: %+v", bob.Resources)
+	}
+	resource, err := bob.ReadResource("search://info")
+	if err != nil || resource.Text != "content of search://info" || resource.Server != "search" {
+		t.Fatalf("unexpected resource: %+v, %v", resource, err)
+	}
+	prompt, err := bob.GetPrompt("search-greet", map[string]string{"name": "Bob"})
+	if err != nil || prompt.Messages[0].Content.Text != "Hello from search" {
+		t.Fatalf("unexpected prompt: %+v, %v", prompt, err)
+	}
+}
+
+func TestAgentWithDuplicateMCPServerName(t *testing.T) {
+	calc := newFakeMCPServer(t, "calc", "add")
+	_, err := NewAgent(
+		WithDMRClient(context.Background(), "http://localhost/v1/"),
+		WithMCPHTTPClient(calc.server.URL+"/mcp", nil),
+		WithMCPHTTPClient(calc.server.URL+"/mcp", nil),
+	)
+	if err == nil || !strings.Contains(err.Error(), "already attached") {
+		t.Fatalf("expected a duplicate server error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package robby
+
+import (
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestChatCompletionStreamWithTools(t *testing.T) {
+	fake := newFakeStreamingDMR(t, func(index int, request map[string]any) []map[string]any {
+		toolCallDelta := func(index int, id, name, arguments string) map[string]any {
+			return map[string]any{"tool_calls": []map[string]any{
+				{"index": index, "id": id, "type": "function", "function": map[string]any{"name": name, "arguments": arguments}},
+			}}
+		}
+		return []map[string]any{
+			{"delta": map[string]any{"role": "assistant", "content": "Let me "}},
+			{"delta": map[string]any{"content": "check."}},
+			{"delta": toolCallDelta(0, "call_1", "say_hello", "")},
+			{"delta": toolCallDelta(0, "", "", `{"name":`)},
+			{"delta": toolCallDelta(0, "", "", `"Bob"}`)},
+			{"delta": toolCallDelta(1, "call_2", "vulcan_salute", `{"name":"Spock"}`)},
+			{"delta": map[string]any{}, "finish_reason": "tool_calls"},
+		}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Say hello to Bob and Spock")},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []StreamEvent{}
+	content, toolCalls, err := bob.ChatCompletionStreamWithTools(func(self *Agent, event StreamEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content != "Let me check." {
+		t.Fatalf("unexpected content: %q", content)
+	}
+	if len(toolCalls) != 2 || len(bob.ToolCalls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(toolCalls))
+	}
+	if toolCalls[0].ID != "call_1" || toolCalls[0].Function.Name != "say_hello" || toolCalls[0].Function.Arguments != `{"name":"Bob"}` {
+		t.Fatalf("unexpected first tool call: %+v", toolCalls[0])
+	}
+	if toolCalls[1].ID != "call_2" || toolCalls[1].Function.Arguments != `{"name":"Spock"}` {
+		t.Fatalf("unexpected second tool call: %+v", toolCalls[1])
+	}
+
+	expected := []StreamEventType{
+		StreamEventContentDelta,
+		StreamEventContentDelta,
+		StreamEventToolCallStarted,
+		StreamEventToolCallArgumentsComplete,
+		StreamEventToolCallStarted,
+		StreamEventToolCallArgumentsComplete,
+		StreamEventFinishReason,
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, eventType := range expected {
+		if events[i].Type != eventType {
+			t.Fatalf("event %d: expected %s, got %s", i, eventType, events[i].Type)
+		}
+	}
+	if events[3].ToolCall.Function.Arguments != `{"name":"Bob"}` {
+		t.Fatalf("arguments not complete: %q", events[3].ToolCall.Function.Arguments)
+	}
+	if events[6].FinishReason != "tool_calls" {
+		t.Fatalf("unexpected finish reason: %q", events[6].FinishReason)
+	}
+}
@@ -0,0 +1,284 @@
+package robby
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultChunkSize is the maximum size of the chunks (in characters) of the chunkers chosen by ChunkDocuments,
+// and of the chunkers created with a size <= 0.
+const DefaultChunkSize = 1000
+
+// Chunk is a piece of a document to save in the RAG memory, with its metadata
+// (the source, the heading path... see WithRAGMemoryChunks and Filter).
+type Chunk struct {
+	Text     string
+	Metadata map[string]any
+}
+
+// Chunker splits a text into chunks. The sizes of the chunkers are in characters (runes).
+type Chunker interface {
+	Chunk(text string) []Chunk
+}
+
+// ChunkerFunc is a function that implements Chunker.
+type ChunkerFunc func(text string) []Chunk
+
+// Chunk implements Chunker.
+func (f ChunkerFunc) Chunk(text string) []Chunk {
+	return f(text)
+}
+
+// FixedSizeChunker splits a text into chunks of size characters, every chunk repeating the last overlap characters
+// of the previous one (the chunks end at a space when possible).
+func FixedSizeChunker(size int, overlap int) Chunker {
+	size = chunkSize(size)
+	overlap = min(max(overlap, 0), size-1)
+	return ChunkerFunc(func(text string) []Chunk {
+		return textChunks(splitFixedSize(text, size, overlap))
+	})
+}
+
+// SentenceChunker splits a text into sentences, and groups the consecutive sentences in chunks of at most maxSize characters
+// (a longer sentence is split by FixedSizeChunker).
+func SentenceChunker(maxSize int) Chunker {
+	maxSize = chunkSize(maxSize)
+	return ChunkerFunc(func(text string) []Chunk {
+		return textChunks(splitSentences(text, maxSize))
+	})
+}
+
+// ParagraphChunker splits a text at the blank lines, and groups the consecutive paragraphs in chunks of at most maxSize characters
+// (a longer paragraph is split by SentenceChunker).
+func ParagraphChunker(maxSize int) Chunker {
+	maxSize = chunkSize(maxSize)
+	return ChunkerFunc(func(text string) []Chunk {
+		return textChunks(splitParagraphs(text, maxSize))
+	})
+}
+
+// MarkdownChunker splits a Markdown text at its headings (not in the code blocks).
+// The "headings" metadata of every chunk is the path of the headings of its section (e.g. ["The Avengers", "Emma Peel"]).
+// A section longer than maxSize characters is split by ParagraphChunker; the sections without text are skipped.
+func MarkdownChunker(maxSize int) Chunker {
+	maxSize = chunkSize(maxSize)
+	return ChunkerFunc(func(text string) []Chunk {
+		var chunks []Chunk
+		headings := []string{}
+		section := []string{}
+		flush := func() {
+			content := strings.TrimSpace(strings.Join(section, "\n"))
+			section = section[:0]
+			if content == "" || markdownHeading.MatchString(content) && !strings.Contains(content, "\n") {
+				return
+			}
+			for _, part := range splitParagraphs(content, maxSize) {
+				chunks = append(chunks, Chunk{Text: part, Metadata: map[string]any{"headings": slices.Clone(headings)}})
+			}
+		}
+
+		fence := ""
+		for _, line := range strings.Split(text, "\n") {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case fence != "":
+				if strings.HasPrefix(trimmed, fence) {
+					fence = ""
+				}
+			case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+				fence = trimmed[:3]
+			default:
+				if match := markdownHeading.FindStringSubmatch(trimmed); match != nil {
+					flush()
+					level := len(match[1])
+					headings = append(headings[:min(level-1, len(headings))], match[2])
+				}
+			}
+			section = append(section, line)
+		}
+		flush()
+		return chunks
+	})
+}
+
+// CodeChunker splits source code at its top-level blocks (the lines that start a declaration after a blank line:
+// functions, types, classes...), and groups the consecutive blocks in chunks of at most maxSize characters
+// (a longer block is split at the line boundaries).
+func CodeChunker(maxSize int) Chunker {
+	maxSize = chunkSize(maxSize)
+	return ChunkerFunc(func(text string) []Chunk {
+		var blocks []string
+		block := []string{}
+		blank := false
+		for _, line := range strings.Split(text, "\n") {
+			if blank && startsCodeBlock(line) && len(block) > 0 {
+				blocks = append(blocks, strings.Join(block, "\n"))
+				block = block[:0]
+			}
+			block = append(block, line)
+			blank = strings.TrimSpace(line) == ""
+		}
+		blocks = append(blocks, strings.Join(block, "\n"))
+		return textChunks(mergeParts(blocks, maxSize, "\n\n", func(block string) []string {
+			return mergeParts(strings.Split(block, "\n"), maxSize, "\n", func(line string) []string {
+				return splitFixedSize(line, maxSize, 0)
+			})
+		}))
+	})
+}
+
+// markdownHeading matches a Markdown heading: the level and the title.
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)[\s#]*$`)
+
+// sentenceEnd matches the end of a sentence: a punctuation followed by spaces.
+var sentenceEnd = regexp.MustCompile(`[.!?。！？]["')\]]*\s+`)
+
+// paragraphSeparator matches the blank lines between the paragraphs.
+var paragraphSeparator = regexp.MustCompile(`\n[ \t]*\n\s*`)
+
+// startsCodeBlock returns true if the line can start a top-level block of code
+// (not indented, and not the closing of a block).
+func startsCodeBlock(line string) bool {
+	if line == "" || unicode.IsSpace(rune(line[0])) {
+		return false
+	}
+	return !strings.ContainsAny(line[:1], "})]")
+}
+
+// splitFixedSize splits the text into parts of size characters, with overlap characters repeated between the parts.
+// A part ends at the last space of its second half if there is one.
+func splitFixedSize(text string, size int, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	var parts []string
+	for start := 0; start < len(runes); {
+		for unicode.IsSpace(runes[start]) {
+			start++
+		}
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			for i := end; i > start+size/2; i-- {
+				if unicode.IsSpace(runes[i]) {
+					end = i
+					break
+				}
+			}
+		}
+		if part := strings.TrimSpace(string(runes[start:end])); part != "" {
+			parts = append(parts, part)
+		}
+		if end == len(runes) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return parts
+}
+
+// splitSentences splits the text into sentences, grouped in parts of at most maxSize characters.
+func splitSentences(text string, maxSize int) []string {
+	var sentences []string
+	start := 0
+	for _, match := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, strings.TrimSpace(text[start:match[1]]))
+		start = match[1]
+	}
+	sentences = append(sentences, strings.TrimSpace(text[start:]))
+	return mergeParts(sentences, maxSize, " ", func(sentence string) []string {
+		return splitFixedSize(sentence, maxSize, 0)
+	})
+}
+
+// splitParagraphs splits the text at the blank lines, grouped in parts of at most maxSize characters.
+func splitParagraphs(text string, maxSize int) []string {
+	return mergeParts(paragraphSeparator.Split(text, -1), maxSize, "\n\n", func(paragraph string) []string {
+		return splitSentences(paragraph, maxSize)
+	})
+}
+
+// mergeParts joins the consecutive parts with the separator while they fit in maxSize characters.
+// The parts longer than maxSize are split by split; the empty parts are skipped.
+func mergeParts(parts []string, maxSize int, separator string, split func(part string) []string) []string {
+	maxSize = max(maxSize, 1)
+	var merged []string
+	current, currentSize := []string{}, 0
+	flush := func() {
+		if len(current) > 0 {
+			merged = append(merged, strings.Join(current, separator))
+			current, currentSize = current[:0], 0
+		}
+	}
+	for _, part := range parts {
+		part = strings.TrimRightFunc(part, unicode.IsSpace)
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		size := utf8.RuneCountInString(part)
+		if size > maxSize {
+			flush()
+			merged = append(merged, split(part)...)
+			continue
+		}
+		if len(current) > 0 && currentSize+len(separator)+size > maxSize {
+			flush()
+		}
+		if len(current) > 0 {
+			currentSize += len(separator)
+		}
+		current = append(current, part)
+		currentSize += size
+	}
+	flush()
+	return merged
+}
+
+// chunkSize returns the size, or DefaultChunkSize if the size is not positive.
+func chunkSize(size int) int {
+	if size <= 0 {
+		return DefaultChunkSize
+	}
+	return size
+}
+
+// textChunks returns the chunks of the texts, without metadata.
+func textChunks(texts []string) []Chunk {
+	chunks := make([]Chunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = Chunk{Text: text}
+	}
+	return chunks
+}
+
+// ChunkDocuments splits the documents with the chunker, and returns the chunks with the metadata of their document
+// (source, title...), the metadata of the chunker (headings...) and their index in the document ("chunk").
+// With a nil chunker, the chunker depends on the "type" of the document (see the loaders):
+// MarkdownChunker for Markdown and HTML, CodeChunker for code, ParagraphChunker for the other documents (DefaultChunkSize).
+func ChunkDocuments(documents []Document, chunker Chunker) []Chunk {
+	var chunks []Chunk
+	for _, document := range documents {
+		documentChunker := chunker
+		if documentChunker == nil {
+			switch document.Metadata["type"] {
+			case "markdown", "html":
+				documentChunker = MarkdownChunker(DefaultChunkSize)
+			case "code":
+				documentChunker = CodeChunker(DefaultChunkSize)
+			default:
+				documentChunker = ParagraphChunker(DefaultChunkSize)
+			}
+		}
+		for i, chunk := range documentChunker.Chunk(document.Text) {
+			metadata := maps.Clone(document.Metadata)
+			if metadata == nil {
+				metadata = map[string]any{}
+			}
+			maps.Copy(metadata, chunk.Metadata)
+			metadata["chunk"] = i
+			chunks = append(chunks, Chunk{Text: chunk.Text, Metadata: metadata})
+		}
+	}
+	return chunks
+}
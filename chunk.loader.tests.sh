@@ -0,0 +1,2 @@
+##!/bin/bash
+go test -v -run "TestFixedSizeChunker|TestSentenceAndParagraphChunkers|TestMarkdownChunker|TestCodeChunker|TestChunkDocuments|TestLoadHTMLFile|TestJSONLoaders|TestLoadDirectoryAndWithRAGMemoryChunks"
@@ -0,0 +1,128 @@
+package robby
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// chunkTexts returns the texts of the chunks.
+func chunkTexts(chunks []Chunk) []string {
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	return texts
+}
+
+func TestFixedSizeChunker(t *testing.T) {
+	text := strings.Repeat("Emma Peel and John Steed. ", 20)
+	chunks := FixedSizeChunker(100, 20).Chunk(text)
+	if len(chunks) < 6 {
+		t.Fatalf("expected at least 6 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if utf8.RuneCountInString(chunk.Text) > 100 {
+			t.Fatalf("chunk %d is too long: %q", i, chunk.Text)
+		}
+		// The chunks end at a space, and start with the end of the previous chunk
+		if i > 0 && !strings.Contains(chunks[i-1].Text, chunk.Text[:10]) {
+			t.Fatalf("expected an overlap between the chunks %d and %d: %q, %q", i-1, i, chunks[i-1].Text, chunk.Text)
+		}
+	}
+
+	if chunks := FixedSizeChunker(4, 0).Chunk("été à l'eau"); !slices.Equal(chunkTexts(chunks), []string{"été", "à l'", "eau"}) {
+		t.Fatalf("unexpected chunks: %q", chunkTexts(chunks))
+	}
+}
+
+func TestSentenceAndParagraphChunkers(t *testing.T) {
+	text := "Emma Peel is a scientist. She fights! Who is Steed?\n\nJohn Steed wears a bowler hat.\n  \nMother is the superior."
+
+	sentences := chunkTexts(SentenceChunker(30).Chunk(text))
+	expected := []string{"Emma Peel is a scientist.", "She fights! Who is Steed?", "John Steed wears a bowler hat.", "Mother is the superior."}
+	if !slices.Equal(sentences, expected) {
+		t.Fatalf("unexpected sentences: %q", sentences)
+	}
+
+	paragraphs := chunkTexts(ParagraphChunker(60).Chunk(text))
+	expected = []string{
+		"Emma Peel is a scientist. She fights! Who is Steed?",
+		"John Steed wears a bowler hat.\n\nMother is the superior.",
+	}
+	if !slices.Equal(paragraphs, expected) {
+		t.Fatalf("unexpected paragraphs: %q", paragraphs)
+	}
+}
+
+func TestMarkdownChunker(t *testing.T) {
+	text := "# The Avengers\n\nA British series.\n\n## John Steed\n\nA gentleman spy.\n\n```sh\n# not a heading\n```\n\n" +
+		"## Emma Peel\n\n### Skills\n\nMartial arts.\n\n# Mother ##\n\nThe superior."
+	chunks := MarkdownChunker(1000).Chunk(text)
+
+	expected := []struct {
+		headings []string
+		start    string
+	}{
+		{[]string{"The Avengers"}, "# The Avengers\n\nA British series."},
+		{[]string{"The Avengers", "John Steed"}, "## John Steed\n\nA gentleman spy.\n\n```sh\n# not a heading\n```"},
+		{[]string{"The Avengers", "Emma Peel", "Skills"}, "### Skills"},
+		{[]string{"Mother"}, "# Mother ##"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %q", len(expected), chunkTexts(chunks))
+	}
+	for i, chunk := range chunks {
+		if !slices.Equal(chunk.Metadata["headings"].([]string), expected[i].headings) || !strings.HasPrefix(chunk.Text, expected[i].start) {
+			t.Fatalf("unexpected chunk %d: %q, %v", i, chunk.Text, chunk.Metadata)
+		}
+	}
+}
+
+func TestCodeChunker(t *testing.T) {
+	code := "package main\n\nimport \"fmt\"\n\n// hello says hello\nfunc hello() {\n\tfmt.Println(\"hello\")\n\n\tfmt.Println(\"world\")\n}\n\nfunc main() {\n\thello()\n}\n"
+
+	chunks := chunkTexts(CodeChunker(45).Chunk(code))
+	expected := []string{
+		"package main\n\nimport \"fmt\"",
+		// The block longer than the size is split at the line boundaries
+		"// hello says hello\nfunc hello() {",
+		"\tfmt.Println(\"hello\")\n\tfmt.Println(\"world\")\n}",
+		"func main() {\n\thello()\n}",
+	}
+	if !slices.Equal(chunks, expected) {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+
+	if chunks := CodeChunker(1000).Chunk(code); len(chunks) != 1 {
+		t.Fatalf("expected a single chunk, got %q", chunkTexts(chunks))
+	}
+}
+
+func TestChunkDocuments(t *testing.T) {
+	documents := []Document{
+		{Text: "# Emma Peel\n\nA scientist.\n\n# John Steed\n\nA spy.", Metadata: map[string]any{"source": "avengers.md", "type": "markdown"}},
+		{Text: "Mother is the superior.", Metadata: map[string]any{"source": "mother.txt", "type": "text"}},
+	}
+	chunks := ChunkDocuments(documents, nil)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %q", chunkTexts(chunks))
+	}
+	steed := chunks[1].Metadata
+	if steed["source"] != "avengers.md" || steed["chunk"] != 1 || !slices.Equal(steed["headings"].([]string), []string{"John Steed"}) {
+		t.Fatalf("unexpected metadata: %v", steed)
+	}
+	if mother := chunks[2].Metadata; mother["source"] != "mother.txt" || mother["chunk"] != 0 {
+		t.Fatalf("unexpected metadata: %v", mother)
+	}
+	// The metadata of the documents are not shared
+	if _, ok := documents[0].Metadata["chunk"]; ok {
+		t.Fatal("expected the metadata of the document to be unchanged")
+	}
+
+	chunks = ChunkDocuments(documents, ParagraphChunker(1000))
+	if len(chunks) != 2 || chunks[1].Metadata["source"] != "mother.txt" || chunks[0].Metadata["headings"] != nil {
+		t.Fatalf("unexpected chunks: %q", chunkTexts(chunks))
+	}
+}
@@ -0,0 +1,3 @@
+##!/bin/bash
+go test -v ./cmd/robby/...
+go test -v -run "TestAgentConfig"
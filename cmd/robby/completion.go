@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/sea-monkeys/robby"
+)
+
+// chat runs the REPL: every line is sent to the agent, and the answer is streamed.
+// The conversation history is kept until /reset; /history prints it, /exit (or the end of the input) quits.
+func (cli *cli) chat(args []string) error {
+	agentFlags := newAgentFlags("chat", cli)
+	config, _, err := cli.parseAgentFlags(agentFlags, args)
+	if err != nil {
+		return err
+	}
+	agent, err := cli.newAgent(config)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	fmt.Fprintf(cli.stdout, "Chatting with %s (/history, /reset, /exit)\n", config.Model)
+	scanner := bufio.NewScanner(cli.stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(cli.stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(cli.stdout)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			agent.ResetConversation()
+			continue
+		case "/history":
+			for _, message := range agent.Messages() {
+				fmt.Fprintf(cli.stdout, "%s: %s\n", messageRole(message), messageContent(message))
+			}
+			continue
+		}
+
+		if _, err := cli.complete(agent, line, true); err != nil {
+			// The conversation goes on
+			fmt.Fprintln(cli.stderr, "Error:", err)
+		}
+	}
+}
+
+// ask sends a question to the agent and prints the answer. The question is made of the arguments
+// and of the standard input if it is not a terminal (e.g. cat notes.md | robby ask "Summarize this").
+func (cli *cli) ask(args []string) error {
+	agentFlags := newAgentFlags("ask", cli)
+	noStream := agentFlags.flags.Bool("no-stream", false, "print the answer when it is complete")
+	config, args, err := cli.parseAgentFlags(agentFlags, args)
+	if err != nil {
+		return err
+	}
+
+	question := strings.Join(args, " ")
+	if cli.piped {
+		input, err := io.ReadAll(cli.stdin)
+		if err != nil {
+			return err
+		}
+		question = strings.TrimSpace(strings.Join([]string{question, string(input)}, "\n\n"))
+	}
+	if question == "" {
+		return errors.New("no question: give it as arguments or on the standard input")
+	}
+
+	agent, err := cli.newAgent(config)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	answer, err := cli.complete(agent, question, !*noStream)
+	if err != nil {
+		return err
+	}
+	if *noStream {
+		fmt.Fprintln(cli.stdout, answer)
+	}
+	return nil
+}
+
+// complete sends the question to the agent with its RAG memory and its tools (see robby.Agent.CompleteMessages),
+// and adds the question and the answer to the conversation history.
+func (cli *cli) complete(agent *robby.Agent, question string, stream bool) (string, error) {
+	var callBack func(self *robby.Agent, content string, err error) error
+	if stream {
+		callBack = func(self *robby.Agent, content string, err error) error {
+			_, err = fmt.Fprint(cli.stdout, content)
+			return err
+		}
+	}
+	agent.AddUserMessage(question)
+	answer, err := agent.CompleteMessages(agent.Messages(), callBack)
+	if stream && answer != "" {
+		fmt.Fprintln(cli.stdout)
+	}
+	if err != nil {
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return answer, err
+	}
+	agent.AddAssistantMessage(answer)
+	return answer, nil
+}
+
+// messageRole returns the role of a message.
+func messageRole(message openai.ChatCompletionMessageParamUnion) string {
+	switch {
+	case message.OfSystem != nil:
+		return "system"
+	case message.OfUser != nil:
+		return "user"
+	case message.OfAssistant != nil:
+		return "assistant"
+	case message.OfTool != nil:
+		return "tool"
+	}
+	return ""
+}
+
+// messageContent returns the text of a message.
+func messageContent(message openai.ChatCompletionMessageParamUnion) string {
+	if text, ok := message.GetContent().AsAny().(*string); ok {
+		return *text
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+// Command robby runs a robby Agent from the command line:
+//
+//	robby chat                          chat with the agent (streamed answers, conversation history)
+//	robby ask [question]                ask a question (the standard input is added to the question)
+//	robby tools list                    list the tools of the MCP server
+//	robby tools call <name> [json]      call a tool of the MCP server
+//	robby resources list                list the resources of the MCP server
+//	robby resources read <uri|name>     read a resource of the MCP server
+//	robby prompts list                  list the prompts of the MCP server
+//	robby prompts get <name> [key=value...]
+//	                                    get a prompt of the MCP server with its arguments
+//	robby rag index <directory>         index the files of a directory into the vector store file
+//	robby rag search <query>            search the vector store file
+//
+// The agent is configured with a configuration file (a robby.AgentConfig in YAML or JSON, see -config),
+// overridden by the environment variables (ROBBY_MODEL...), overridden by the flags of the command (-model...).
+// Run "robby <command> -h" for the flags of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/sea-monkeys/robby"
+)
+
+const usage = `Usage: robby <command> [flags] [arguments]
+
+Commands:
+  chat                              chat with the agent
+  ask [question]                    ask a question (the standard input is added to the question)
+  tools list | call <name> [json]   list or call the tools of the MCP server
+  resources list | read <uri|name>  list or read the resources of the MCP server
+  prompts list | get <name> [k=v]   list or get the prompts of the MCP server
+  rag index <dir> | search <query>  index a directory into the vector store file, or search it
+
+Run "robby <command> -h" for the flags of a command.
+`
+
+// cli is the environment of the commands.
+type cli struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(key string) string
+	piped  bool // the standard input is not a terminal
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	piped := false
+	if info, err := os.Stdin.Stat(); err == nil {
+		piped = info.Mode()&os.ModeCharDevice == 0
+	}
+	cli := &cli{ctx: ctx, stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv, piped: piped}
+	if err := cli.run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the command of the arguments.
+func (cli *cli) run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(cli.stderr, usage)
+		return flag.ErrHelp
+	}
+	command, args := args[0], args[1:]
+	switch command {
+	case "chat":
+		return cli.chat(args)
+	case "ask":
+		return cli.ask(args)
+	case "tools", "resources", "prompts", "rag":
+		if len(args) == 0 {
+			return fmt.Errorf("missing %s subcommand\n\n%s", command, usage)
+		}
+		subcommand := command + " " + args[0]
+		switch subcommand {
+		case "tools list":
+			return cli.listTools(args[1:])
+		case "tools call":
+			return cli.callTool(args[1:])
+		case "resources list":
+			return cli.listResources(args[1:])
+		case "resources read":
+			return cli.readResource(args[1:])
+		case "prompts list":
+			return cli.listPrompts(args[1:])
+		case "prompts get":
+			return cli.getPrompt(args[1:])
+		case "rag index":
+			return cli.indexDirectory(args[1:])
+		case "rag search":
+			return cli.search(args[1:])
+		}
+		return fmt.Errorf("unknown command %q\n\n%s", subcommand, usage)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(cli.stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+}
+
+// newAgent creates the agent of the configuration, with the other options.
+// The agent must be closed (it stops the MCP servers).
+func (cli *cli) newAgent(config robby.AgentConfig, options ...robby.AgentOption) (*robby.Agent, error) {
+	configOptions, err := config.Options(cli.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return robby.NewAgent(append(configOptions, options...)...)
+}
+
+// parseAgentFlags parses the flags of a command, and returns the configuration of the agent and the arguments.
+// The flags must be before the arguments.
+func (cli *cli) parseAgentFlags(agentFlags *agentFlags, args []string) (robby.AgentConfig, []string, error) {
+	if err := agentFlags.flags.Parse(args); err != nil {
+		return robby.AgentConfig{}, nil, err
+	}
+	config, err := agentFlags.agentConfig(cli)
+	return config, agentFlags.flags.Args(), err
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sea-monkeys/robby/internal/fakedmr"
+)
+
+// newTestCLI returns a CLI reading the input, with the environment variables, and its output.
+func newTestCLI(input string, env map[string]string) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &cli{
+		ctx:    context.Background(),
+		stdin:  strings.NewReader(input),
+		stdout: stdout,
+		stderr: stderr,
+		getenv: func(key string) string { return env[key] },
+		piped:  input != "",
+	}, stdout, stderr
+}
+
+// messagesOf returns the "role: content" of the messages of a chat completion request.
+func messagesOf(request map[string]any) []string {
+	var messages []string
+	for _, message := range request["messages"].([]any) {
+		message := message.(map[string]any)
+		messages = append(messages, message["role"].(string)+": "+message["content"].(string))
+	}
+	return messages
+}
+
+func TestAgentConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bob.json")
+	err := os.WriteFile(path, []byte(`{"model": "file-model", "temperature": 0.5, "system_prompt": "You are Bob"}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlPath := filepath.Join(dir, "bob.yaml")
+	if err := os.WriteFile(yamlPath, []byte("model: ${ROBBY_TEST_MODEL:-yaml-model}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		flags []string
+		model string
+	}{
+		{"file", map[string]string{"ROBBY_CONFIG": path}, nil, "file-model"},
+		{"YAML file", nil, []string{"-config", yamlPath}, "yaml-model"},
+		{"env over file", map[string]string{"ROBBY_CONFIG": path, "ROBBY_MODEL": "env-model"}, nil, "env-model"},
+		{"flag over env", map[string]string{"ROBBY_MODEL": "env-model"}, []string{"-config", path, "-model", "flag-model"}, "flag-model"},
+		{"default", nil, nil, defaultModel},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cli, _, _ := newTestCLI("", test.env)
+			config, args, err := cli.parseAgentFlags(newAgentFlags("test", cli), append(test.flags, "question"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Model != test.model {
+				t.Errorf("model = %q, want %q", config.Model, test.model)
+			}
+			if config.BaseURL != defaultBaseURL {
+				t.Errorf("base URL = %q, want the default", config.BaseURL)
+			}
+			if len(args) != 1 || args[0] != "question" {
+				t.Errorf("arguments = %v", args)
+			}
+		})
+	}
+
+	cli, _, _ := newTestCLI("", map[string]string{"ROBBY_TEMPERATURE": "hot"})
+	if _, _, err := cli.parseAgentFlags(newAgentFlags("test", cli), nil); err == nil || !strings.Contains(err.Error(), "temperature") {
+		t.Errorf("invalid temperature: error = %v", err)
+	}
+}
+
+func TestAsk(t *testing.T) {
+	fake := fakedmr.New(t, fakedmr.Answer("Hello from Bob"))
+
+	cli, stdout, _ := newTestCLI("the notes\n", nil)
+	err := cli.run([]string{"ask", "-base-url", fake.BaseURL(), "-system", "You are Bob", "-no-stream", "Summarize"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "Hello from Bob\n" {
+		t.Errorf("output = %q", stdout.String())
+	}
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	want := []string{"system: You are Bob", "user: Summarize\n\nthe notes"}
+	if got := messagesOf(requests[0]); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestAskStream(t *testing.T) {
+	fake := fakedmr.New(t, fakedmr.Answer("Hello from Bob"))
+
+	cli, stdout, _ := newTestCLI("", map[string]string{"ROBBY_BASE_URL": fake.BaseURL()})
+	if err := cli.run([]string{"ask", "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "Hello from Bob\n" {
+		t.Errorf("output = %q", stdout.String())
+	}
+	if stream, _ := fake.Requests()[0]["stream"].(bool); !stream {
+		t.Error("the answer is not streamed")
+	}
+
+	cli, _, _ = newTestCLI("", map[string]string{"ROBBY_BASE_URL": fake.BaseURL()})
+	if err := cli.run([]string{"ask"}); err == nil {
+		t.Error("no error without a question")
+	}
+}
+
+func TestChat(t *testing.T) {
+	fake := fakedmr.New(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Answer " + string(rune('0'+len(request["messages"].([]any))))}
+	})
+
+	cli, stdout, _ := newTestCLI("Hi\nHow are you?\n/history\n/reset\nBye\n/exit\n", nil)
+	if err := cli.run([]string{"chat", "-base-url", fake.BaseURL(), "-system", "You are Bob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(requests))
+	}
+	want := []string{"system: You are Bob", "user: Hi", "assistant: Answer 2", "user: How are you?"}
+	if got := messagesOf(requests[1]); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	// The history is reset
+	if got := messagesOf(requests[2]); len(got) != 2 || got[1] != "user: Bye" {
+		t.Errorf("messages after /reset = %q", got)
+	}
+	if !strings.Contains(stdout.String(), "assistant: Answer 4\n") {
+		t.Errorf("no history in the output:\n%s", stdout.String())
+	}
+}
+
+func TestRAGIndexAndSearch(t *testing.T) {
+	embed := func(text string) []float64 {
+		switch {
+		case strings.Contains(text, "Emma"):
+			return []float64{1, 0.1, 0}
+		case strings.Contains(text, "Steed"):
+			return []float64{0.1, 1, 0}
+		}
+		return []float64{0, 0, 1}
+	}
+	fake := fakedmr.New(t, nil)
+	fake.Embed = embed
+
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	if err := os.Mkdir(docs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"emma.md":  "# Emma Peel\n\nEmma Peel is a secret agent.",
+		"steed.md": "# John Steed\n\nJohn Steed wears a bowler hat (code BH-42).",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(docs, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := filepath.Join(dir, "avengers.json")
+	env := map[string]string{"ROBBY_BASE_URL": fake.BaseURL(), "ROBBY_VECTOR_STORE": store}
+
+	cli, stdout, _ := newTestCLI("", env)
+	if err := cli.run([]string{"rag", "index", docs}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "Indexed 2 documents (2 chunks)") {
+		t.Errorf("output = %q", stdout.String())
+	}
+
+	for _, test := range []struct{ mode, query, want string }{
+		{"vector", "Who is Emma?", "emma.md"},
+		{"keyword", "BH-42", "steed.md"},
+		{"hybrid", "Tell me about Steed", "steed.md"},
+	} {
+		cli, stdout, _ := newTestCLI("", env)
+		if err := cli.run([]string{"rag", "search", "-mode", test.mode, "-max", "1", test.query}); err != nil {
+			t.Fatal(test.mode, err)
+		}
+		if !strings.HasPrefix(stdout.String(), "1. ") || !strings.Contains(stdout.String(), test.want) || strings.Contains(stdout.String(), "2. ") {
+			t.Errorf("%s search: output = %q, want the first result from %s", test.mode, stdout.String(), test.want)
+		}
+	}
+
+	cli, _, _ = newTestCLI("", env)
+	if err := cli.run([]string{"rag", "search", "-mode", "fuzzy", "Emma"}); err == nil {
+		t.Error("no error with an unknown search mode")
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"dance"}, `unknown command "dance"`},
+		{[]string{"tools"}, "missing tools subcommand"},
+		{[]string{"tools", "delete"}, `unknown command "tools delete"`},
+		{[]string{"tools", "list"}, "no MCP server"},
+	}
+	for _, test := range tests {
+		cli, _, _ := newTestCLI("", nil)
+		err := cli.run(test.args)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: error = %v, want %q", test.args, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openai/openai-go"
+	"github.com/sea-monkeys/robby"
+)
+
+// newMCPAgent creates the agent of a command of the MCP server, with the other options
+// (it returns an error if no MCP server is configured).
+func (cli *cli) newMCPAgent(name string, args []string, options ...robby.AgentOption) (*robby.Agent, []string, error) {
+	agentFlags := newAgentFlags(name, cli)
+	config, args, err := cli.parseAgentFlags(agentFlags, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(config.MCPServers) == 0 {
+		return nil, nil, errors.New("no MCP server: use -mcp-command or -mcp-url (or mcp_servers in the configuration file)")
+	}
+	agent, err := cli.newAgent(config, options...)
+	return agent, args, err
+}
+
+// listTools prints the tools of the MCP server: name and description.
+func (cli *cli) listTools(args []string) error {
+	agent, _, err := cli.newMCPAgent("tools list", args)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	table := tabwriter.NewWriter(cli.stdout, 0, 4, 2, ' ', 0)
+	for _, tool := range agent.Tools {
+		fmt.Fprintf(table, "%s\t%s\n", tool.Function.Name, firstLine(tool.Function.Description.Value))
+	}
+	return table.Flush()
+}
+
+// callTool calls a tool of the MCP server with the JSON arguments, and prints its result.
+func (cli *cli) callTool(args []string) error {
+	agent, args, err := cli.newMCPAgent("tools call", args)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: robby tools call [flags] <name> [json arguments]")
+	}
+	arguments := "{}"
+	if len(args) == 2 {
+		arguments = args[1]
+	}
+
+	agent.ToolCalls = []openai.ChatCompletionMessageToolCall{{
+		ID:       "robby-cli",
+		Type:     "function",
+		Function: openai.ChatCompletionMessageToolCallFunction{Name: args[0], Arguments: arguments},
+	}}
+	results, err := agent.ExecuteMCPToolCallsWithResults()
+	if err != nil {
+		return err
+	}
+	if results[0].Error != nil {
+		return results[0].Error
+	}
+	fmt.Fprintln(cli.stdout, results[0].Output)
+	return nil
+}
+
+// listResources prints the resources of the MCP server: URI, name and description.
+func (cli *cli) listResources(args []string) error {
+	agent, _, err := cli.newMCPAgent("resources list", args, robby.WithMCPResources(nil))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	table := tabwriter.NewWriter(cli.stdout, 0, 4, 2, ' ', 0)
+	for _, resource := range agent.Resources {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", resource.URI, resource.Name, firstLine(resource.Description))
+	}
+	return table.Flush()
+}
+
+// readResource prints the content of a resource of the MCP server, found by URI or by name.
+func (cli *cli) readResource(args []string) error {
+	agent, args, err := cli.newMCPAgent("resources read", args, robby.WithMCPResources(nil))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	if len(args) != 1 {
+		return errors.New("usage: robby resources read [flags] <uri|name>")
+	}
+
+	resource, err := agent.ReadResource(args[0])
+	if err != nil {
+		var errByName error
+		if resource, errByName = agent.ReadResourceByName(args[0]); errByName != nil {
+			return err
+		}
+	}
+	if resource.Text == "" && resource.Blob != "" {
+		fmt.Fprintf(cli.stdout, "(binary content, %s, %d bytes in base64)\n", resource.MimeType, len(resource.Blob))
+		return nil
+	}
+	fmt.Fprintln(cli.stdout, resource.Text)
+	return nil
+}
+
+// listPrompts prints the prompts of the MCP server: name, arguments and description.
+func (cli *cli) listPrompts(args []string) error {
+	agent, _, err := cli.newMCPAgent("prompts list", args, robby.WithMCPPrompts(nil))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	table := tabwriter.NewWriter(cli.stdout, 0, 4, 2, ' ', 0)
+	for _, prompt := range agent.Prompts {
+		arguments := []string{}
+		for _, argument := range prompt.Arguments {
+			if name, ok := argument["name"].(string); ok {
+				arguments = append(arguments, name)
+			}
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", prompt.Name, strings.Join(arguments, ","), firstLine(prompt.Description))
+	}
+	return table.Flush()
+}
+
+// getPrompt prints the messages of a prompt of the MCP server, with the key=value arguments.
+func (cli *cli) getPrompt(args []string) error {
+	agent, args, err := cli.newMCPAgent("prompts get", args, robby.WithMCPPrompts(nil))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	if len(args) == 0 {
+		return errors.New("usage: robby prompts get [flags] <name> [key=value...]")
+	}
+
+	arguments := map[string]string{}
+	for _, argument := range args[1:] {
+		key, value, ok := strings.Cut(argument, "=")
+		if !ok {
+			return fmt.Errorf("invalid prompt argument %q: expected key=value", argument)
+		}
+		arguments[key] = value
+	}
+	prompt, err := agent.GetPrompt(args[0], arguments)
+	if err != nil {
+		return err
+	}
+	for _, message := range prompt.Messages {
+		fmt.Fprintf(cli.stdout, "%s: %s\n", message.Role, message.Content.Text)
+	}
+	return nil
+}
+
+// firstLine returns the first line of a description.
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
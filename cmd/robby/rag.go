@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sea-monkeys/robby"
+)
+
+// ragConfig returns the configuration of the agent of a RAG command: the vector store file
+// and the embedding model have default values, the MCP servers are not started, and the RAG sources are not loaded.
+func ragConfig(config robby.AgentConfig) robby.AgentConfig {
+	config.VectorStore = firstOf(config.VectorStore, defaultVectorStore)
+	config.EmbeddingModel = firstOf(config.EmbeddingModel, defaultEmbeddingModel)
+	config.MCPServers, config.Tools, config.RAGSources = nil, nil, nil
+	return config
+}
+
+// indexDirectory loads and chunks the files of a directory (see robby.LoadDirectory and robby.ChunkDocuments),
+// computes the embeddings of the chunks, and saves them in the vector store file (replaced).
+func (cli *cli) indexDirectory(args []string) error {
+	agentFlags := newAgentFlags("rag index", cli)
+	config, args, err := cli.parseAgentFlags(agentFlags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: robby rag index [flags] <directory>")
+	}
+	config = ragConfig(config)
+
+	documents, err := robby.LoadDirectory(args[0], nil)
+	if err != nil {
+		return err
+	}
+	chunks := robby.ChunkDocuments(documents, nil)
+	if len(chunks) == 0 {
+		return fmt.Errorf("no documents to index in %s", args[0])
+	}
+
+	path := config.VectorStore
+	config.VectorStore = "" // the file is created, not loaded
+	agent, err := cli.newAgent(config, robby.WithRAGMemoryChunks(chunks))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+	if err := agent.Store.(*robby.MemoryVectorStore).SaveToFile(path, robby.VectorStoreJSON); err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.stdout, "Indexed %d documents (%d chunks) into %s\n", len(documents), len(chunks), path)
+	return nil
+}
+
+// search searches the vector store file, and prints the records found with their scores and their sources.
+// The mode is vector (cosine similarity), keyword (BM25) or hybrid (both, see robby.Agent.RAGMemoryHybridSearch).
+func (cli *cli) search(args []string) error {
+	agentFlags := newAgentFlags("rag search", cli)
+	max := agentFlags.flags.Int("max", 5, "the maximum number of results")
+	mode := agentFlags.flags.String("mode", "hybrid", "the search: vector, keyword or hybrid")
+	config, args, err := cli.parseAgentFlags(agentFlags, args)
+	if err != nil {
+		return err
+	}
+	query := strings.Join(args, " ")
+	if query == "" {
+		return errors.New("usage: robby rag search [flags] <query>")
+	}
+	if *max <= 0 {
+		return fmt.Errorf("invalid maximum number of results %d", *max)
+	}
+
+	agent, err := cli.newAgent(ragConfig(config), robby.WithHybridSearch(robby.HybridSearchConfig{Candidates: *max}))
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	var records []robby.VectorRecord
+	switch *mode {
+	case "hybrid":
+		records, err = agent.RAGMemoryHybridSearch(query, *max)
+	case "keyword":
+		records, err = agent.RAGMemoryKeywordSearch(query, *max)
+	case "vector":
+		records, err = agent.RAGMemoryVectorSearch(query, *max)
+	default:
+		return fmt.Errorf("unknown search mode %q: use vector, keyword or hybrid", *mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		fmt.Fprintf(cli.stdout, "%d. [similarity %.4f, keywords %.4f, score %.4f] %v\n",
+			i+1, record.CosineSimilarity, record.KeywordScore, record.Score, record.Metadata["source"])
+		fmt.Fprintln(cli.stdout, strings.TrimSpace(record.Prompt))
+		fmt.Fprintln(cli.stdout)
+	}
+	return nil
+}
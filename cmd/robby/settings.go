@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sea-monkeys/robby"
+)
+
+// The default settings, when they are not set by a flag, an environment variable or the configuration file.
+const (
+	defaultBaseURL        = "http://localhost:12434/engines/llama.cpp/v1/"
+	defaultModel          = "ai/qwen2.5:latest"
+	defaultEmbeddingModel = "ai/mxbai-embed-large"
+	defaultVectorStore    = "robby.vectors.json"
+)
+
+// defaultConfigFiles are the configuration files used when -config and ROBBY_CONFIG are not set (the first that exists).
+var defaultConfigFiles = []string{"robby.yaml", "robby.yml", "robby.json"}
+
+// setting is a setting of the agent, with its flag and its environment variable.
+type setting struct {
+	flag        string
+	env         string
+	description string
+	apply       func(config *robby.AgentConfig, value string) error
+}
+
+// settings are the settings of the agent that can be set with a flag or an environment variable.
+// They override the settings of the configuration file (a robby.AgentConfig in YAML or JSON).
+var settings = []setting{
+	{"base-url", "ROBBY_BASE_URL", "the URL of the model runner", func(config *robby.AgentConfig, value string) error {
+		config.BaseURL = value
+		return nil
+	}},
+	{"model", "ROBBY_MODEL", "the chat model", func(config *robby.AgentConfig, value string) error {
+		config.Model = value
+		return nil
+	}},
+	{"temperature", "ROBBY_TEMPERATURE", "the temperature of the completions", func(config *robby.AgentConfig, value string) error {
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid temperature %q", value)
+		}
+		config.Temperature = &temperature
+		return nil
+	}},
+	{"system", "ROBBY_SYSTEM_PROMPT", "the system prompt", func(config *robby.AgentConfig, value string) error {
+		config.SystemPrompt = value
+		return nil
+	}},
+	{"embedding-model", "ROBBY_EMBEDDING_MODEL", "the embedding model of the RAG memory", func(config *robby.AgentConfig, value string) error {
+		config.EmbeddingModel = value
+		return nil
+	}},
+	{"vector-store", "ROBBY_VECTOR_STORE", "the file of the RAG memory", func(config *robby.AgentConfig, value string) error {
+		config.VectorStore = value
+		return nil
+	}},
+	{"mcp-command", "ROBBY_MCP_COMMAND", "the command of the MCP server (STDIO transport), e.g. \"socat STDIO TCP:localhost:8811\"", func(config *robby.AgentConfig, value string) error {
+		config.MCPServers = []robby.MCPServerConfig{{Command: strings.Fields(value)}}
+		return nil
+	}},
+	{"mcp-url", "ROBBY_MCP_URL", "the URL of the MCP server (Streamable HTTP transport)", func(config *robby.AgentConfig, value string) error {
+		config.MCPServers = []robby.MCPServerConfig{{URL: value}}
+		return nil
+	}},
+}
+
+// agentFlags are the flags of the settings of the agent of a command.
+type agentFlags struct {
+	flags  *flag.FlagSet
+	config *string
+	values map[string]*string
+}
+
+// newAgentFlags creates the flag set of a command, with the flags of the settings of the agent.
+func newAgentFlags(name string, cli *cli) *agentFlags {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cli.stderr)
+	agentFlags := &agentFlags{
+		flags:  flags,
+		config: flags.String("config", "", fmt.Sprintf("the configuration file (env ROBBY_CONFIG, default the first of %s that exists)", strings.Join(defaultConfigFiles, ", "))),
+		values: map[string]*string{},
+	}
+	for _, setting := range settings {
+		agentFlags.values[setting.flag] = flags.String(setting.flag, "", fmt.Sprintf("%s (env %s)", setting.description, setting.env))
+	}
+	return agentFlags
+}
+
+// agentConfig returns the configuration of the agent: the configuration file, overridden by the environment variables,
+// overridden by the flags (the flags must be parsed).
+func (agentFlags *agentFlags) agentConfig(cli *cli) (robby.AgentConfig, error) {
+	config := robby.AgentConfig{}
+	path := firstOf(*agentFlags.config, cli.getenv("ROBBY_CONFIG"))
+	for _, file := range defaultConfigFiles {
+		if path == "" && fileExists(file) {
+			path = file
+		}
+	}
+	if path != "" {
+		loaded, err := robby.LoadAgentConfig(path)
+		if err != nil {
+			return config, err
+		}
+		config = loaded
+	}
+
+	set := map[string]bool{}
+	agentFlags.flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, setting := range settings {
+		value := cli.getenv(setting.env)
+		if set[setting.flag] {
+			value = *agentFlags.values[setting.flag]
+		}
+		if value == "" {
+			continue
+		}
+		if err := setting.apply(&config, value); err != nil {
+			return config, fmt.Errorf("%s: %w", setting.flag, err)
+		}
+	}
+
+	config.BaseURL = firstOf(config.BaseURL, defaultBaseURL)
+	config.Model = firstOf(config.Model, defaultModel)
+	return config, nil
+}
+
+// firstOf returns the first non-empty value.
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// fileExists returns true if the file exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
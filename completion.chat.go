@@ -1,11 +1,18 @@
 package robby
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/openai/openai-go"
+)
 
 // ChatCompletion handles the chat completion request using the DMR client.
 // It sends the parameters set in the Agent and returns the response content or an error.
 // It is a synchronous operation that waits for the completion to finish.
 func (agent *Agent) ChatCompletion() (string, error) {
+	if err := agent.prepareMessages(); err != nil {
+		return "", err
+	}
 	completion, err := agent.dmrClient.Chat.Completions.New(agent.ctx, agent.Params)
 
 	if err != nil {
@@ -26,8 +33,16 @@ func (agent *Agent) ChatCompletion() (string, error) {
 // It returns the accumulated response content and any error that occurred during the streaming process.
 // The callback function should return an error if it wants to stop the streaming process.
 func (agent *Agent) ChatCompletionStream(callBack func(self *Agent, content string, err error) error) (string, error) {
+	if err := agent.prepareMessages(); err != nil {
+		return "", err
+	}
+	return agent.chatCompletionStream(agent.Params, callBack)
+}
+
+// chatCompletionStream streams the completion of the parameters (see ChatCompletionStream).
+func (agent *Agent) chatCompletionStream(params openai.ChatCompletionNewParams, callBack func(self *Agent, content string, err error) error) (string, error) {
 	response := ""
-	stream := agent.dmrClient.Chat.Completions.NewStreaming(agent.ctx, agent.Params)
+	stream := agent.dmrClient.Chat.Completions.NewStreaming(agent.ctx, params)
 	var cbkRes error
 
 	for stream.Next() {
@@ -0,0 +1,181 @@
+package robby
+
+import (
+	"sort"
+
+	"github.com/openai/openai-go"
+)
+
+// StreamEventType is the type of an event sent by ChatCompletionStreamWithTools.
+type StreamEventType string
+
+const (
+	// StreamEventContentDelta is sent for every chunk of text content.
+	StreamEventContentDelta StreamEventType = "content_delta"
+	// StreamEventToolCallStarted is sent when the model starts a new tool call (ID and name are known).
+	StreamEventToolCallStarted StreamEventType = "tool_call_started"
+	// StreamEventToolCallArgumentsComplete is sent when all the arguments of a tool call have been received.
+	StreamEventToolCallArgumentsComplete StreamEventType = "tool_call_arguments_complete"
+	// StreamEventFinishReason is sent when the model gives the reason why the completion stopped.
+	StreamEventFinishReason StreamEventType = "finish_reason"
+)
+
+// StreamEvent is a typed event sent by ChatCompletionStreamWithTools.
+// Content is set for StreamEventContentDelta,
+// ToolCall is set for StreamEventToolCallStarted and StreamEventToolCallArgumentsComplete,
+// FinishReason is set for StreamEventFinishReason.
+type StreamEvent struct {
+	Type         StreamEventType
+	Content      string
+	ToolCall     *openai.ChatCompletionMessageToolCall
+	FinishReason string
+}
+
+// toolCallsAccumulator assembles the partial tool call deltas of a stream by index.
+type toolCallsAccumulator struct {
+	toolCalls map[int64]*openai.ChatCompletionMessageToolCall
+	completed map[int64]bool
+}
+
+func newToolCallsAccumulator() *toolCallsAccumulator {
+	return &toolCallsAccumulator{
+		toolCalls: make(map[int64]*openai.ChatCompletionMessageToolCall),
+		completed: make(map[int64]bool),
+	}
+}
+
+// add merges a delta into the tool call with the same index.
+// It returns the tool call and true if the delta started a new tool call.
+func (acc *toolCallsAccumulator) add(delta openai.ChatCompletionChunkChoiceDeltaToolCall) (*openai.ChatCompletionMessageToolCall, bool) {
+	toolCall, exists := acc.toolCalls[delta.Index]
+	if !exists {
+		toolCall = &openai.ChatCompletionMessageToolCall{Type: "function"}
+		acc.toolCalls[delta.Index] = toolCall
+	}
+	if delta.ID != "" {
+		toolCall.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		toolCall.Function.Name += delta.Function.Name
+	}
+	toolCall.Function.Arguments += delta.Function.Arguments
+	return toolCall, !exists
+}
+
+// pending returns the indexes of the tool calls not yet completed, lower than the given index, in order.
+// Use -1 to get all the pending tool calls.
+func (acc *toolCallsAccumulator) pending(before int64) []int64 {
+	indexes := []int64{}
+	for index := range acc.toolCalls {
+		if !acc.completed[index] && (before < 0 || index < before) {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
+
+// result returns the assembled tool calls ordered by index.
+func (acc *toolCallsAccumulator) result() []openai.ChatCompletionMessageToolCall {
+	indexes := []int64{}
+	for index := range acc.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	toolCalls := []openai.ChatCompletionMessageToolCall{}
+	for _, index := range indexes {
+		toolCall := *acc.toolCalls[index]
+		if toolCall.Function.Arguments == "" {
+			toolCall.Function.Arguments = "{}"
+		}
+		toolCalls = append(toolCalls, toolCall)
+	}
+	return toolCalls
+}
+
+// ChatCompletionStreamWithTools handles the chat completion request with the Agent's tools in a streaming manner.
+// The text content is streamed to the callback as StreamEventContentDelta events,
+// and the tool call deltas are assembled (by index) into complete tool calls.
+// The callback is notified when a tool call starts, when its arguments are complete, and with the finish reason.
+// The detected tool calls are stored in the Agent's ToolCalls (they can be executed with ExecuteToolCalls or ExecuteMCPToolCalls).
+// It returns the accumulated response content, the detected tool calls and any error that occurred.
+// The callback function should return an error if it wants to stop the streaming process.
+func (agent *Agent) ChatCompletionStreamWithTools(callBack func(self *Agent, event StreamEvent) error) (string, []openai.ChatCompletionMessageToolCall, error) {
+	// Apply the changes of the MCP tools lists (the errors are ignored: the current tools are used)
+	_ = agent.refreshMCPIfChanged()
+	agent.Params.Tools = agent.Tools
+	if err := agent.prepareMessages(); err != nil {
+		return "", nil, err
+	}
+
+	response := ""
+	accumulator := newToolCallsAccumulator()
+	stream := agent.dmrClient.Chat.Completions.NewStreaming(agent.ctx, agent.Params)
+
+	// complete notifies the callback for the tool calls whose arguments are complete
+	complete := func(before int64) error {
+		for _, index := range accumulator.pending(before) {
+			accumulator.completed[index] = true
+			toolCall := *accumulator.toolCalls[index]
+			if err := callBack(agent, StreamEvent{Type: StreamEventToolCallArgumentsComplete, ToolCall: &toolCall}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var cbkRes error
+	for cbkRes == nil && stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+
+		if choice.Delta.Content != "" {
+			response += choice.Delta.Content
+			cbkRes = callBack(agent, StreamEvent{Type: StreamEventContentDelta, Content: choice.Delta.Content})
+		}
+
+		for _, delta := range choice.Delta.ToolCalls {
+			if cbkRes != nil {
+				break
+			}
+			// A new index means that the previous tool calls are complete
+			if cbkRes = complete(delta.Index); cbkRes != nil {
+				break
+			}
+			toolCall, started := accumulator.add(delta)
+			if started {
+				startedToolCall := *toolCall
+				cbkRes = callBack(agent, StreamEvent{Type: StreamEventToolCallStarted, ToolCall: &startedToolCall})
+			}
+		}
+
+		if choice.FinishReason != "" && cbkRes == nil {
+			if cbkRes = complete(-1); cbkRes == nil {
+				cbkRes = callBack(agent, StreamEvent{Type: StreamEventFinishReason, FinishReason: choice.FinishReason})
+			}
+		}
+	}
+
+	toolCalls := accumulator.result()
+	agent.ToolCalls = toolCalls
+
+	if cbkRes != nil {
+		return response, toolCalls, cbkRes
+	}
+	if err := stream.Err(); err != nil {
+		return response, toolCalls, err
+	}
+	// Some servers do not send a finish reason
+	if err := complete(-1); err != nil {
+		return response, toolCalls, err
+	}
+	if err := stream.Close(); err != nil {
+		return response, toolCalls, err
+	}
+
+	return response, toolCalls, nil
+}
@@ -0,0 +1,73 @@
+package robby
+
+import (
+	"context"
+	"slices"
+
+	"github.com/openai/openai-go"
+)
+
+// CompleteMessages answers a whole conversation given by the caller (e.g. the messages of an OpenAI chat completion
+// request) with everything the Agent has, without changing its conversation history or its session:
+//   - if the Agent has a RAG memory, the chunks most similar to the last user message are given to the model
+//     in a context message before it (see AskWithRAG and WithRAGConfig; no message if no chunk is similar enough),
+//   - the tools of the Agent (tool registry and MCP servers) are run until the model answers (see RunToolLoop).
+//
+// If the callback is not nil, the completions are streamed and the callback is called with every chunk of the answer
+// (see ChatCompletionStream). It returns the answer, or ErrMaxToolLoopIterations if the model is still asking
+// for tools after the maximum number of iterations.
+func (agent *Agent) CompleteMessages(messages []openai.ChatCompletionMessageParamUnion, callBack func(self *Agent, content string, err error) error) (string, error) {
+	history, session, toolCalls := agent.Params.Messages, agent.session, agent.ToolCalls
+	defer func() {
+		agent.Params.Messages, agent.session, agent.ToolCalls = history, session, toolCalls
+	}()
+	agent.session = nil
+	agent.Params.Messages = slices.Clone(messages)
+
+	if last := len(messages) - 1; agent.Store != nil && last >= 0 && messages[last].OfUser != nil {
+		contextMessage, sources, err := agent.ragContext(messageText(messages[last]), nil)
+		if err != nil {
+			return "", err
+		}
+		if len(sources) > 0 {
+			agent.Params.Messages = slices.Insert(agent.Params.Messages, last, openai.SystemMessage(contextMessage))
+		}
+	}
+
+	if callBack == nil {
+		answer, _, err := agent.RunToolLoop(nil)
+		return answer, err
+	}
+	return agent.runToolLoopStream(callBack)
+}
+
+// runToolLoopStream is RunToolLoop with streamed completions: the content of every completion is sent to the callback.
+func (agent *Agent) runToolLoopStream(callBack func(self *Agent, content string, err error) error) (string, error) {
+	maxIterations := agent.maxToolLoopIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolLoopIterations
+	}
+
+	for range maxIterations {
+		content, toolCalls, err := agent.ChatCompletionStreamWithTools(func(self *Agent, event StreamEvent) error {
+			if event.Type == StreamEventContentDelta {
+				return callBack(self, event.Content, nil)
+			}
+			return nil
+		})
+		if err != nil {
+			return content, err
+		}
+		if len(toolCalls) == 0 {
+			agent.AddAssistantMessage(content)
+			return content, agent.persistSession()
+		}
+
+		// Keep the tool call request in the conversation so the tool messages can be linked to it
+		agent.Params.Messages = append(agent.Params.Messages, openai.ChatCompletionMessage{Content: content, ToolCalls: toolCalls}.ToParam())
+		agent.reportToolCalls(toolCalls, func(ctx context.Context, idx int, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
+			return agent.executeToolCall(ctx, toolCall, nil)
+		})
+	}
+	return "", ErrMaxToolLoopIterations
+}
@@ -0,0 +1,2 @@
+##!/bin/bash
+go test -v -run "TestCompleteMessages"
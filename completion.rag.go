@@ -0,0 +1,203 @@
+package robby
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/openai/openai-go"
+)
+
+// DefaultRAGTemplate is the template of the context message of AskWithRAG and AskStreamWithRAG
+// (see RAGTemplateData for its data).
+const DefaultRAGTemplate = `Answer the question with the following sources. Cite the sources you use with their number in square brackets, like [1].
+If the sources do not answer the question, say that you do not know.
+
+Sources:
+{{range .Sources}}[{{.Number}}]{{with index .Metadata "source"}} ({{.}}){{end}} {{.Text}}
+{{end}}`
+
+// RAGConfig configures the retrieval of AskWithRAG and AskStreamWithRAG.
+// A zero TopN or an empty Template is replaced by the value of DefaultRAGConfig.
+type RAGConfig struct {
+	TopN     int     // Maximum number of chunks retrieved from the RAG memory
+	MinScore float64 // Minimum cosine similarity of the retrieved chunks
+	Template string  // text/template of the context message (see RAGTemplateData)
+}
+
+// DefaultRAGConfig is the configuration used by AskWithRAG and AskStreamWithRAG for the fields that are not set.
+var DefaultRAGConfig = RAGConfig{
+	TopN:     3,
+	MinScore: 0.5,
+	Template: DefaultRAGTemplate,
+}
+
+// RAGSource is a chunk of the RAG memory used to answer a question.
+type RAGSource struct {
+	Number   int            // the number of the source in the context message, cited as [Number]
+	Id       string         // the ID of the vector record
+	Text     string         // the chunk
+	Score    float64        // the cosine similarity of the chunk with the question
+	Metadata map[string]any // the metadata of the chunk (source, headings...)
+}
+
+// RAGTemplateData is the data of the template of the context message.
+type RAGTemplateData struct {
+	Question string
+	Sources  []RAGSource
+}
+
+// RAGAnswer is the answer of AskWithRAG and AskStreamWithRAG, with the sources given to the model.
+type RAGAnswer struct {
+	Answer  string
+	Sources []RAGSource
+}
+
+// ragCitation matches the citations of the sources in an answer: [1], [2, 3]...
+var ragCitation = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// CitedSources returns the sources cited in the answer (e.g. "[1]" or "[1, 3]"), in the order of their numbers.
+func (answer *RAGAnswer) CitedSources() []RAGSource {
+	cited := map[int]bool{}
+	for _, match := range ragCitation.FindAllStringSubmatch(answer.Answer, -1) {
+		for _, number := range strings.Split(match[1], ",") {
+			if n, err := strconv.Atoi(strings.TrimSpace(number)); err == nil {
+				cited[n] = true
+			}
+		}
+	}
+	sources := []RAGSource{}
+	for _, source := range answer.Sources {
+		if cited[source.Number] {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// WithRAGConfig sets the retrieval configuration of AskWithRAG and AskStreamWithRAG (number of chunks, minimum score, template).
+// NewAgent returns an error if the template is invalid.
+// It returns an AgentOption that can be used to configure the agent.
+func WithRAGConfig(config RAGConfig) AgentOption {
+	return func(agent *Agent) {
+		if config.TopN <= 0 {
+			config.TopN = DefaultRAGConfig.TopN
+		}
+		if config.Template == "" {
+			config.Template = DefaultRAGConfig.Template
+		}
+		ragTemplate, err := template.New("rag").Parse(config.Template)
+		if err != nil {
+			agent.lastError = fmt.Errorf("invalid RAG template: %w", err)
+			return
+		}
+		agent.ragSettings = &ragSettings{config: config, template: ragTemplate}
+	}
+}
+
+// ragSettings is the retrieval configuration of the Agent, with its parsed template.
+type ragSettings struct {
+	config   RAGConfig
+	template *template.Template
+}
+
+// defaultRAGSettings are the settings of the Agents without WithRAGConfig.
+var defaultRAGSettings = &ragSettings{
+	config:   DefaultRAGConfig,
+	template: template.Must(template.New("rag").Parse(DefaultRAGTemplate)),
+}
+
+// AskWithRAG answers the question with the chunks of the RAG memory: it retrieves the chunks most similar
+// to the question (matched by the filters, see WithRAGConfig), gives them to the model in a context message
+// with their numbers so the model can cite them, and runs the chat completion.
+// Like Ask, the question and the answer are appended to the conversation history (the context message is not).
+// It returns the answer with the sources given to the model (see RAGAnswer.CitedSources).
+func (agent *Agent) AskWithRAG(question string, filters ...Filter) (*RAGAnswer, error) {
+	params, sources, err := agent.ragCompletionParams(question, filters)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := agent.dmrClient.Chat.Completions.New(agent.ctx, params)
+	if err == nil && len(completion.Choices) == 0 {
+		err = errors.New("no choices found")
+	}
+	if err != nil {
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return nil, err
+	}
+	answer := completion.Choices[0].Message.Content
+	agent.AddAssistantMessage(answer)
+	return &RAGAnswer{Answer: answer, Sources: sources}, agent.persistSession()
+}
+
+// AskStreamWithRAG is AskWithRAG with a streamed answer: the callback is called with every chunk of the answer
+// (see ChatCompletionStream). The answer received before an error is returned with the error.
+func (agent *Agent) AskStreamWithRAG(question string, callBack func(self *Agent, content string, err error) error, filters ...Filter) (*RAGAnswer, error) {
+	params, sources, err := agent.ragCompletionParams(question, filters)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := agent.chatCompletionStream(params, callBack)
+	if err != nil {
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return &RAGAnswer{Answer: answer, Sources: sources}, err
+	}
+	agent.AddAssistantMessage(answer)
+	return &RAGAnswer{Answer: answer, Sources: sources}, agent.persistSession()
+}
+
+// ragCompletionParams retrieves the sources of the question, appends the question to the conversation history,
+// and returns the parameters of the completion: the messages with the context message before the question.
+func (agent *Agent) ragCompletionParams(question string, filters []Filter) (openai.ChatCompletionNewParams, []RAGSource, error) {
+	params := agent.Params
+	contextMessage, sources, err := agent.ragContext(question, filters)
+	if err != nil {
+		return params, nil, err
+	}
+
+	agent.AddUserMessage(question)
+	if err := agent.prepareMessages(); err != nil {
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return params, nil, err
+	}
+	// The context message is only sent with this request
+	messages := agent.Params.Messages
+	params = agent.Params
+	params.Messages = slices.Insert(slices.Clone(messages), len(messages)-1, openai.SystemMessage(contextMessage))
+	return params, sources, nil
+}
+
+// ragContext retrieves the chunks of the RAG memory most similar to the question (see WithRAGConfig),
+// and returns the context message rendered with the template, and the sources.
+func (agent *Agent) ragContext(question string, filters []Filter) (string, []RAGSource, error) {
+	store, err := agent.vectorStore()
+	if err != nil {
+		return "", nil, err
+	}
+	settings := agent.ragSettings
+	if settings == nil {
+		settings = defaultRAGSettings
+	}
+
+	embeddings, err := agent.createEmbeddings(agent.context(), agent.embeddingPolicyOrDefault(), []string{question})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create the embedding of the question: %w", err)
+	}
+	records, err := store.SearchTopNSimilarities(VectorRecord{Embedding: embeddings[0]}, settings.config.MinScore, settings.config.TopN, filters...)
+	if err != nil {
+		return "", nil, err
+	}
+	sources := make([]RAGSource, len(records))
+	for i, record := range records {
+		sources[i] = RAGSource{Number: i + 1, Id: record.Id, Text: record.Prompt, Score: record.CosineSimilarity, Metadata: record.Metadata}
+	}
+	contextMessage := strings.Builder{}
+	if err := settings.template.Execute(&contextMessage, RAGTemplateData{Question: question, Sources: sources}); err != nil {
+		return "", nil, fmt.Errorf("failed to render the RAG template: %w", err)
+	}
+	return contextMessage.String(), sources, nil
+}
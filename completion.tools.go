@@ -11,7 +11,12 @@ import (
 // It is a synchronous operation that waits for the completion to finish.
 func (agent *Agent) ToolsCompletion() ([]openai.ChatCompletionMessageToolCall, error) {
 
+	// Apply the changes of the MCP tools lists (the errors are ignored: the current tools are used)
+	_ = agent.refreshMCPIfChanged()
 	agent.Params.Tools = agent.Tools
+	if err := agent.prepareMessages(); err != nil {
+		return nil, err
+	}
 
 	completion, err := agent.dmrClient.Chat.Completions.New(agent.ctx, agent.Params)
 	if err != nil {
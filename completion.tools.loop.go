@@ -0,0 +1,114 @@
+package robby
+
+import (
+	"context"
+	"errors"
+
+	"github.com/openai/openai-go"
+)
+
+// DefaultMaxToolLoopIterations is the number of model round trips RunToolLoop allows
+// when no limit has been set with WithMaxToolLoopIterations.
+const DefaultMaxToolLoopIterations = 10
+
+// ErrMaxToolLoopIterations is returned by RunToolLoop when the model is still asking
+// for tools after the maximum number of iterations.
+var ErrMaxToolLoopIterations = errors.New("maximum number of tool loop iterations reached")
+
+// ToolLoopStep records one round trip of the tool calling loop.
+// When the model asked for tools, ToolCalls and Results are set (in the same order).
+// When the model answered with text, Content holds the final answer and ToolCalls is empty.
+type ToolLoopStep struct {
+	Iteration int                                    `json:"iteration"`
+	ToolCalls []openai.ChatCompletionMessageToolCall `json:"tool_calls,omitempty"`
+	Results   []ToolCallResult                       `json:"results,omitempty"`
+	Content   string                                 `json:"content,omitempty"`
+}
+
+// RunToolLoop runs the tool calling loop until the model stops asking for tools.
+// At each iteration, it sends the Agent's parameters (with the Agent's tools) to the model.
+// If the model asks for tools, the assistant tool call message and every tool result
+// are appended to the Agent's messages, and the model is called again.
+// The tool calls are dispatched to the provided implementations (same format as ExecuteToolCalls)
+// and, when a tool is not implemented locally, to the MCP client if the Agent has one.
+// A failing tool does not stop the loop: the error is sent back to the model as the tool result
+// (formatted by the ToolErrorFormatter, see WithToolErrorFormatter).
+// When the model answers with text, the answer is appended to the messages as an assistant message
+// and returned together with the trace of every step.
+// If the model is still asking for tools after the maximum number of iterations
+// (see WithMaxToolLoopIterations), it returns ErrMaxToolLoopIterations with the trace.
+// When the loop stops with an error, the messages already added (tool calls and results) are kept,
+// and saved in the session of the Agent (if any), so the conversation can go on.
+func (agent *Agent) RunToolLoop(toolsImpl map[string]func(any) (any, error)) (string, []ToolLoopStep, error) {
+	maxIterations := agent.maxToolLoopIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolLoopIterations
+	}
+
+	steps := []ToolLoopStep{}
+	fail := func(err error) (string, []ToolLoopStep, error) {
+		if persistErr := agent.persistSession(); persistErr != nil {
+			err = errors.Join(err, persistErr)
+		}
+		return "", steps, err
+	}
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		// The MCP tools may have changed during the previous tool calls (the errors are ignored: the current tools are used)
+		_ = agent.refreshMCPIfChanged()
+		agent.Params.Tools = agent.Tools
+		if err := agent.prepareMessages(); err != nil {
+			return fail(err)
+		}
+
+		completion, err := agent.dmrClient.Chat.Completions.New(agent.ctx, agent.Params)
+		if err != nil {
+			return fail(err)
+		}
+		if len(completion.Choices) == 0 {
+			return fail(errors.New("no choices found"))
+		}
+		message := completion.Choices[0].Message
+
+		if len(message.ToolCalls) == 0 {
+			// The model answered: this is the end of the loop
+			agent.ToolCalls = nil
+			agent.Params.Messages = append(agent.Params.Messages, openai.AssistantMessage(message.Content))
+			steps = append(steps, ToolLoopStep{
+				Iteration: iteration,
+				Content:   message.Content,
+			})
+			return message.Content, steps, agent.persistSession()
+		}
+
+		// Keep the tool call request in the conversation
+		// so the tool messages can be linked to it
+		agent.ToolCalls = message.ToolCalls
+		agent.Params.Messages = append(agent.Params.Messages, message.ToParam())
+
+		step := ToolLoopStep{
+			Iteration: iteration,
+			ToolCalls: message.ToolCalls,
+		}
+		// The tool calls are run concurrently if WithToolCallsConcurrency is set,
+		// but the results are always added in the tool calls order
+		step.Results = agent.reportToolCalls(message.ToolCalls, func(ctx context.Context, idx int, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
+			return agent.executeToolCall(ctx, toolCall, toolsImpl)
+		})
+		steps = append(steps, step)
+	}
+	return fail(ErrMaxToolLoopIterations)
+}
+
+// executeToolCall runs a single tool call.
+// The local implementations are tried first (see lookupToolImpl), then the MCP client if the Agent has one.
+// It returns the tool result as a string.
+func (agent *Agent) executeToolCall(ctx context.Context, toolCall openai.ChatCompletionMessageToolCall, toolsImpl map[string]func(any) (any, error)) (string, error) {
+	if toolFunc, ok := agent.lookupToolImpl(toolCall.Function.Name, toolsImpl); ok {
+		args, err := decodeToolArguments(toolCall)
+		if err != nil {
+			return "", err
+		}
+		return callWithContext(ctx, toolCall.Function.Name, toolFunc, args)
+	}
+	return agent.callMCPTool(ctx, toolCall)
+}
@@ -0,0 +1,115 @@
+package robby
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+type sayHelloArgs struct {
+	Name string `json:"name"`
+}
+
+// sayHelloRegistry returns a tool registry with the say_hello tool.
+func sayHelloRegistry(t *testing.T) *ToolRegistry {
+	t.Helper()
+	registry := NewToolRegistry()
+	if err := RegisterTool(registry, "say_hello", "Say hello", func(args sayHelloArgs) (any, error) {
+		return "Hello " + args.Name, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func TestCompleteMessages(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, func(index int, request map[string]any) map[string]any {
+		if index == 0 {
+			return map[string]any{"role": "assistant", "tool_calls": []map[string]any{fakeToolCall("call_1", "say_hello", `{"name":"Emma"}`)}}
+		}
+		return map[string]any{"role": "assistant", "content": "Hello Emma, the scientist [1]"}
+	})
+	store := NewMemorySessionStore()
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are Bob")},
+		}),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithToolRegistry(sayHelloRegistry(t)),
+		WithRAGConfig(RAGConfig{TopN: 1, MinScore: 0.9}),
+		WithRAGMemoryChunks(ragChunks),
+		WithSession(store, "bob"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := bob.CompleteMessages([]openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are a client"),
+		openai.UserMessage("Say hello to Emma"),
+	}, nil)
+	if err != nil || answer != "Hello Emma, the scientist [1]" {
+		t.Fatalf("unexpected answer: %q, %v", answer, err)
+	}
+
+	// The context message is sent before the question, and the tool result with the second request
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	contents := requestContents(requests[1])
+	if len(contents) != 5 || contents[0] != "You are a client" || !strings.Contains(contents[1], "Emma Peel is a scientist") ||
+		contents[2] != "Say hello to Emma" || contents[4] != "Hello Emma" {
+		t.Fatalf("unexpected messages: %q", contents)
+	}
+	if len(requests[0]["tools"].([]any)) != 1 {
+		t.Fatalf("expected the tools of the agent, got %v", requests[0]["tools"])
+	}
+
+	// The conversation history and the session are not changed
+	if messages := bob.Messages(); len(messages) != 1 || messageText(messages[0]) != "You are Bob" {
+		t.Fatalf("unexpected conversation history: %d messages", len(messages))
+	}
+	if messages, _ := store.Load("bob"); len(messages) != 1 {
+		t.Fatalf("unexpected session: %d messages", len(messages))
+	}
+}
+
+func TestCompleteMessagesStream(t *testing.T) {
+	fake := newFakeStreamingDMR(t, func(index int, request map[string]any) []map[string]any {
+		if index == 0 {
+			return []map[string]any{
+				{"delta": map[string]any{"role": "assistant", "tool_calls": []map[string]any{
+					{"index": 0, "id": "call_1", "type": "function", "function": map[string]any{"name": "say_hello", "arguments": `{"name":"Bob"}`}},
+				}}, "finish_reason": "tool_calls"},
+			}
+		}
+		return []map[string]any{
+			{"delta": map[string]any{"role": "assistant", "content": "Hello "}},
+			{"delta": map[string]any{"content": "Bob"}, "finish_reason": "stop"},
+		}
+	})
+	bob, err := NewAgent(fake.Option(), WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}), WithToolRegistry(sayHelloRegistry(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := ""
+	answer, err := bob.CompleteMessages([]openai.ChatCompletionMessageParamUnion{openai.UserMessage("Say hello to Bob")}, func(self *Agent, content string, err error) error {
+		streamed += content
+		return nil
+	})
+	if err != nil || answer != "Hello Bob" || streamed != answer {
+		t.Fatalf("unexpected answer: %q, streamed %q, %v", answer, streamed, err)
+	}
+	messages := fake.Requests()[1]["messages"].([]any)
+	if len(messages) != 3 || messages[1].(map[string]any)["role"] != "assistant" || messages[1].(map[string]any)["tool_calls"] == nil || messages[2].(map[string]any)["content"] != "Hello Bob" {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+	if len(bob.Messages()) != 0 {
+		t.Fatalf("expected no conversation history, got %d messages", len(bob.Messages()))
+	}
+}
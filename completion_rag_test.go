@@ -0,0 +1,105 @@
+package robby
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// ragChunks are the chunks of the RAG memory of the tests.
+var ragChunks = []Chunk{
+	{Text: "Emma Peel is a scientist", Metadata: map[string]any{"source": "emma.md"}},
+	{Text: "John Steed wears a bowler hat", Metadata: map[string]any{"source": "steed.md"}},
+	{Text: "Mother is the superior", Metadata: map[string]any{"source": "mother.md"}},
+}
+
+func TestAskWithRAG(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Emma Peel is a scientist [1]."}
+	})
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are a support bot")},
+		}),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithRAGConfig(RAGConfig{TopN: 2, MinScore: 0.9}),
+		WithRAGMemoryChunks(ragChunks),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := bob.AskWithRAG("Who is Emma?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Sources) != 1 || answer.Sources[0].Text != "Emma Peel is a scientist" || answer.Sources[0].Score < 0.99 {
+		t.Fatalf("unexpected sources: %+v", answer.Sources)
+	}
+	if cited := answer.CitedSources(); len(cited) != 1 || cited[0].Metadata["source"] != "emma.md" {
+		t.Fatalf("unexpected cited sources: %+v", cited)
+	}
+
+	// The context message is sent before the question, but not kept in the conversation history
+	contents := requestContents(fake.Requests()[0])
+	if len(contents) != 3 || contents[0] != "You are a support bot" || contents[2] != "Who is Emma?" {
+		t.Fatalf("unexpected messages: %q", contents)
+	}
+	if !strings.Contains(contents[1], "[1] (emma.md) Emma Peel is a scientist") || strings.Contains(contents[1], "Steed") {
+		t.Fatalf("unexpected context message: %q", contents[1])
+	}
+	if messages := bob.Messages(); len(messages) != 3 || messageText(messages[2]) != answer.Answer {
+		t.Fatalf("unexpected conversation history: %d messages", len(messages))
+	}
+
+	// The filters select the chunks
+	answer, err = bob.AskWithRAG("Who is Emma?", Eq("source", "steed.md"))
+	if err != nil || len(answer.Sources) != 0 || len(answer.CitedSources()) != 0 {
+		t.Fatalf("expected no sources, got %+v, %v", answer, err)
+	}
+
+	if _, err := NewAgent(WithRAGConfig(RAGConfig{Template: "{{.Sources"})); err == nil {
+		t.Fatal("expected an error with an invalid template")
+	}
+}
+
+func TestAskStreamWithRAG(t *testing.T) {
+	fake := newFakeStreamingDMR(t, func(index int, request map[string]any) []map[string]any {
+		return []map[string]any{
+			{"delta": map[string]any{"role": "assistant", "content": "John Steed "}},
+			{"delta": map[string]any{"content": "wears a bowler hat [1, 2]."}, "finish_reason": "stop"},
+		}
+	})
+	fake.Embed = fakeEmbedding
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithRAGConfig(RAGConfig{MinScore: 0.5, Template: "{{range .Sources}}<{{.Number}}:{{.Text}}>{{end}} {{.Question}}"}),
+		WithRAGMemoryChunks(ragChunks),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := ""
+	answer, err := bob.AskStreamWithRAG("What does Steed wear?", func(self *Agent, content string, err error) error {
+		streamed += content
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed != answer.Answer || answer.Answer != "John Steed wears a bowler hat [1, 2]." {
+		t.Fatalf("unexpected answer: %q, streamed %q", answer.Answer, streamed)
+	}
+	if cited := answer.CitedSources(); len(cited) != 1 || cited[0].Number != 1 || cited[0].Text != "John Steed wears a bowler hat" {
+		t.Fatalf("unexpected cited sources: %+v", cited)
+	}
+	if contents := requestContents(fake.Requests()[0]); contents[0] != "<1:John Steed wears a bowler hat> What does Steed wear?" {
+		t.Fatalf("unexpected context message: %q", contents[0])
+	}
+}
@@ -0,0 +1,408 @@
+package robby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"gopkg.in/yaml.v3"
+)
+
+// AgentConfig describes an Agent with plain settings, in a YAML or JSON file, so the agents can be tuned
+// without recompiling, and the same settings can configure the robby CLI and the library code
+// (see LoadAgentConfig, NewAgentFromConfig and Options):
+//
+//	base_url: http://localhost:12434/engines/llama.cpp/v1/
+//	model: ai/qwen2.5:latest
+//	temperature: 0.0
+//	system_prompt: You are Bob, a helpful assistant
+//	embedding_model: ai/mxbai-embed-large
+//	rag_sources: [./docs]
+//	mcp_servers:
+//	  - name: toolkit
+//	    command: [socat, STDIO, "TCP:host.docker.internal:8811"]
+//	  - name: github
+//	    url: http://localhost:9090/mcp
+//	    headers: {Authorization: "Bearer ${GITHUB_TOKEN}"}
+//	tools: [fetch, github__search_issues]
+type AgentConfig struct {
+	BaseURL        string            `json:"base_url,omitempty" yaml:"base_url,omitempty"`               // the URL of the model runner (OpenAI API)
+	Model          string            `json:"model,omitempty" yaml:"model,omitempty"`                     // the chat model
+	Temperature    *float64          `json:"temperature,omitempty" yaml:"temperature,omitempty"`         // the temperature of the completions, between 0 and 2 (default of the model if not set)
+	SystemPrompt   string            `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`     // the first message of the conversation
+	EmbeddingModel string            `json:"embedding_model,omitempty" yaml:"embedding_model,omitempty"` // the embedding model of the RAG memory
+	MCPServers     []MCPServerConfig `json:"mcp_servers,omitempty" yaml:"mcp_servers,omitempty"`         // the MCP servers, whose tools are given to the model
+	Tools          []string          `json:"tools,omitempty" yaml:"tools,omitempty"`                     // the MCP tools to enable (all the tools if not set, see WithMCPTools)
+	VectorStore    string            `json:"vector_store,omitempty" yaml:"vector_store,omitempty"`       // the file of the RAG memory (see WithRAGMemoryFromFile), relative to the configuration file
+	RAGSources     []string          `json:"rag_sources,omitempty" yaml:"rag_sources,omitempty"`         // the files and directories to load into the RAG memory (see LoadDirectory), relative to the configuration file
+}
+
+// MCPServerConfig describes an MCP server of an AgentConfig: a command (STDIO transport)
+// or the URL of a Streamable HTTP server.
+type MCPServerConfig struct {
+	Name    string            `json:"name,omitempty" yaml:"name,omitempty"`       // the name of the server (DefaultMCPServerName if not set)
+	Command []string          `json:"command,omitempty" yaml:"command,omitempty"` // the command starting the server
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty"`         // the URL of the server
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"` // the HTTP headers of the requests to the URL
+}
+
+// ConfigError is an error of an AgentConfig, with the offending field (e.g. "mcp_servers[1].url").
+type ConfigError struct {
+	Field   string
+	Line    int // the line of the field in the configuration file (0 if unknown)
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// LoadAgentConfig reads an AgentConfig from a YAML or JSON file (the settings can be completed before Options validates them).
+// The ${VAR} references in the values are replaced with the environment variables,
+// e.g. "Bearer ${GITHUB_TOKEN}"; ${VAR:-default} uses the default if the variable is not set or empty,
+// and $${ is a literal ${. A variable without default that is not set is an error.
+// The relative paths of vector_store and rag_sources are relative to the directory of the file,
+// so the configuration works from any working directory.
+// The errors point at the offending fields: unknown fields, values of the wrong type, variables not set
+// (see ConfigError, several errors are joined).
+func LoadAgentConfig(path string) (AgentConfig, error) {
+	config := AgentConfig{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read the agent configuration: %w", err)
+	}
+	// JSON is parsed as YAML (it is a subset of YAML)
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return config, fmt.Errorf("invalid agent configuration %s: %w", path, err)
+	}
+	if document.Kind == 0 {
+		return config, fmt.Errorf("invalid agent configuration %s: the file is empty", path)
+	}
+	if errs := resolveConfigNode(&document, reflect.TypeOf(config), ""); len(errs) > 0 {
+		return config, fmt.Errorf("invalid agent configuration %s: %w", path, errors.Join(errs...))
+	}
+	if err := document.Decode(&config); err != nil {
+		return config, fmt.Errorf("invalid agent configuration %s: %w", path, err)
+	}
+	config.resolvePaths(filepath.Dir(path))
+	return config, nil
+}
+
+// resolvePaths joins the directory to the relative paths of the files of the configuration (the empty paths are kept).
+func (config *AgentConfig) resolvePaths(dir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	config.VectorStore = resolve(config.VectorStore)
+	for i, source := range config.RAGSources {
+		config.RAGSources[i] = resolve(source)
+	}
+}
+
+// NewAgentFromConfig creates an Agent from a YAML or JSON configuration file (see LoadAgentConfig and Options),
+// with the other options (added after the options of the configuration).
+// The agent must be closed when the configuration has MCP servers (see Close).
+func NewAgentFromConfig(ctx context.Context, path string, options ...AgentOption) (*Agent, error) {
+	config, err := LoadAgentConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	configOptions, err := config.Options(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent configuration %s: %w", path, err)
+	}
+	return NewAgent(append(configOptions, options...)...)
+}
+
+// Validate checks the configuration, and returns the errors of the offending fields (see ConfigError, joined):
+// the base URL and the model are required, an MCP server has a command or a URL (not both),
+// the tools require MCP servers, and the RAG memory requires an embedding model.
+func (config AgentConfig) Validate() error {
+	var errs []error
+	invalid := func(field string, format string, args ...any) {
+		errs = append(errs, &ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if config.BaseURL == "" {
+		invalid("base_url", "required")
+	} else if err := checkHTTPURL(config.BaseURL); err != nil {
+		invalid("base_url", "%v", err)
+	}
+	if config.Model == "" {
+		invalid("model", "required")
+	}
+	if config.Temperature != nil && (*config.Temperature < 0 || *config.Temperature > 2) {
+		invalid("temperature", "%v is not between 0 and 2", *config.Temperature)
+	}
+
+	names := map[string]bool{}
+	for i, server := range config.MCPServers {
+		field := fmt.Sprintf("mcp_servers[%d]", i)
+		name := server.Name
+		if name == "" {
+			name = DefaultMCPServerName
+		}
+		if names[name] {
+			invalid(field+".name", "duplicate MCP server name %q", name)
+		}
+		names[name] = true
+		switch {
+		case len(server.Command) > 0 && server.URL != "":
+			invalid(field, "set a command or a URL, not both")
+		case len(server.Command) > 0:
+			if strings.TrimSpace(server.Command[0]) == "" {
+				invalid(field+".command", "the program is empty")
+			}
+			if len(server.Headers) > 0 {
+				invalid(field+".headers", "the headers require a URL")
+			}
+		case server.URL != "":
+			if err := checkHTTPURL(server.URL); err != nil {
+				invalid(field+".url", "%v", err)
+			}
+		default:
+			invalid(field, "no command and no URL")
+		}
+	}
+	if len(config.Tools) > 0 && len(config.MCPServers) == 0 {
+		invalid("tools", "the tools are the tools of the MCP servers: set mcp_servers")
+	}
+	for i, tool := range config.Tools {
+		if tool == "" {
+			invalid(fmt.Sprintf("tools[%d]", i), "empty tool name")
+		}
+	}
+
+	for i, source := range config.RAGSources {
+		if source == "" {
+			invalid(fmt.Sprintf("rag_sources[%d]", i), "empty path")
+		}
+	}
+	if config.EmbeddingModel == "" && (len(config.RAGSources) > 0 || config.VectorStore != "") {
+		invalid("embedding_model", "required by the RAG memory (rag_sources, vector_store)")
+	}
+	return errors.Join(errs...)
+}
+
+// Options returns the AgentOptions of the configuration, to use with NewAgent (with other options if needed):
+// the model runner client, the chat and embedding parameters, the MCP servers with their tools,
+// and the RAG memory of the vector store file and of the RAG sources
+// (the files are loaded and chunked with the default loaders and chunkers, see LoadDirectory and ChunkDocuments).
+// It returns an error if the configuration is not valid (see Validate) or if a RAG source cannot be loaded.
+func (config AgentConfig) Options(ctx context.Context) ([]AgentOption, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := openai.ChatCompletionNewParams{Model: config.Model}
+	if config.Temperature != nil {
+		params.Temperature = openai.Opt(*config.Temperature)
+	}
+	if config.SystemPrompt != "" {
+		params.Messages = []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(config.SystemPrompt)}
+	}
+	options := []AgentOption{
+		WithDMRClient(ctx, config.BaseURL),
+		WithParams(params),
+	}
+	if config.EmbeddingModel != "" {
+		options = append(options, WithEmbeddingParams(openai.EmbeddingNewParams{Model: config.EmbeddingModel}))
+	}
+
+	for _, server := range config.MCPServers {
+		name := server.Name
+		if name == "" {
+			name = DefaultMCPServerName
+		}
+		if len(server.Command) > 0 {
+			options = append(options, WithNamedMCPClient(name, server.Command))
+		} else {
+			options = append(options, WithNamedMCPHTTPClient(name, server.URL, server.Headers))
+		}
+	}
+	if len(config.MCPServers) > 0 {
+		options = append(options, WithMCPTools(config.Tools))
+	}
+
+	if config.VectorStore != "" {
+		options = append(options, WithRAGMemoryFromFile(config.VectorStore))
+	}
+	if len(config.RAGSources) > 0 {
+		chunks, err := loadRAGSources(config.RAGSources)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithRAGMemoryChunks(chunks))
+	}
+	return options, nil
+}
+
+// loadRAGSources loads the files and the directories with the default loaders, and returns their chunks.
+func loadRAGSources(sources []string) ([]Chunk, error) {
+	var documents []Document
+	for i, source := range sources {
+		field := fmt.Sprintf("rag_sources[%d]", i)
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, &ConfigError{Field: field, Message: err.Error()}
+		}
+		var loaded []Document
+		if info.IsDir() {
+			loaded, err = LoadDirectory(source, nil)
+		} else if loader, ok := DefaultLoaders[strings.ToLower(filepath.Ext(source))]; ok {
+			loaded, err = loader(source)
+		} else {
+			return nil, &ConfigError{Field: field, Message: fmt.Sprintf("no loader for the %q files", filepath.Ext(source))}
+		}
+		if err != nil {
+			return nil, &ConfigError{Field: field, Message: err.Error()}
+		}
+		documents = append(documents, loaded...)
+	}
+	return ChunkDocuments(documents, nil), nil
+}
+
+// checkHTTPURL returns an error if the URL is not an absolute HTTP(S) URL.
+func checkHTTPURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected http(s)://host/...", rawURL)
+	}
+	return nil
+}
+
+// resolveConfigNode checks the fields of a YAML node against the type of its value (unknown fields,
+// lists, mappings and numbers), and replaces the environment variables of its values (see LoadAgentConfig).
+// The field is the path of the node (e.g. "mcp_servers[1].url").
+func resolveConfigNode(node *yaml.Node, typ reflect.Type, field string) []error {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	invalid := func(node *yaml.Node, format string, args ...any) []error {
+		return []error{&ConfigError{Field: field, Line: node.Line, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	var errs []error
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			errs = append(errs, resolveConfigNode(child, typ, field)...)
+		}
+	case yaml.MappingNode:
+		if typ.Kind() != reflect.Struct && typ.Kind() != reflect.Map {
+			return invalid(node, "expected a %s, not a mapping", kindName(typ))
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyField := key.Value
+			if field != "" {
+				keyField = field + "." + key.Value
+			}
+			valueType := typ
+			if typ.Kind() == reflect.Map {
+				valueType = typ.Elem()
+			} else if valueType = configFieldType(typ, key.Value); valueType == nil {
+				errs = append(errs, &ConfigError{Field: keyField, Line: key.Line, Message: "unknown field"})
+				continue
+			}
+			errs = append(errs, resolveConfigNode(value, valueType, keyField)...)
+		}
+	case yaml.SequenceNode:
+		if typ.Kind() != reflect.Slice {
+			return invalid(node, "expected a %s, not a list", kindName(typ))
+		}
+		for i, child := range node.Content {
+			errs = append(errs, resolveConfigNode(child, typ.Elem(), fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil
+		}
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map {
+			return invalid(node, "expected a %s, not %q", kindName(typ), node.Value)
+		}
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return invalid(node, "%v", err)
+		}
+		node.Value = value
+		if typ.Kind() != reflect.String {
+			// The type of the value is resolved again (e.g. "${TEMPERATURE}" or "0.5" for a number)
+			node.Tag, node.Style = "", 0
+		}
+		if typ.Kind() == reflect.Float64 {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return invalid(node, "expected a number, not %q", value)
+			}
+		}
+	}
+	return errs
+}
+
+// configFieldType returns the type of the field of the struct with the YAML name (nil if there is none).
+func configFieldType(typ reflect.Type, name string) reflect.Type {
+	for i := 0; i < typ.NumField(); i++ {
+		tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if tag == name {
+			return typ.Field(i).Type
+		}
+	}
+	return nil
+}
+
+// kindName returns the name of the kind of a configuration value.
+func kindName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Slice:
+		return "list"
+	case reflect.Struct, reflect.Map:
+		return "mapping"
+	case reflect.Float64:
+		return "number"
+	}
+	return typ.Kind().String()
+}
+
+// envReference matches $${ (a literal ${), ${VAR} and ${VAR:-default}.
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces the environment variables of a value (see LoadAgentConfig).
+func expandEnv(value string) (string, error) {
+	var err error
+	expanded := envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		match := envReference.FindStringSubmatch(reference)
+		name, hasDefault, defaultValue := match[1], match[2] != "", match[3]
+		variable, ok := os.LookupEnv(name)
+		switch {
+		case hasDefault && variable == "":
+			return defaultValue
+		case !ok:
+			if err == nil {
+				err = fmt.Errorf("environment variable %s is not set", name)
+			}
+		}
+		return variable
+	})
+	return expanded, err
+}
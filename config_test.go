@@ -0,0 +1,218 @@
+package robby
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAgentConfigOptions(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Hello"}
+	})
+	path := filepath.Join(t.TempDir(), "bob.json")
+	content := `{"base_url": "` + fake.URL + `/v1/", "model": "bob-model", "temperature": 0.2, "system_prompt": "You are Bob"}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadAgentConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := config.Options(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := NewAgent(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.ChatCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	request := fake.Requests()[0]
+	if request["model"] != "bob-model" || request["temperature"] != 0.2 {
+		t.Errorf("model = %v, temperature = %v", request["model"], request["temperature"])
+	}
+	if contents := requestContents(request); len(contents) != 1 || contents[0] != "You are Bob" {
+		t.Errorf("messages = %v, want the system prompt", contents)
+	}
+}
+
+func TestNewAgentFromConfig(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Hello"}
+	})
+	mcpServer := newFakeMCPServer(t, "calc", "add", "multiply")
+	dir := t.TempDir()
+	docs := filepath.Join(dir, "docs")
+	if err := os.Mkdir(docs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(docs, "emma.md"), []byte("# Emma Peel\n\nEmma Peel is a secret agent."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ROBBY_TEST_BASE_URL", fake.URL)
+	t.Setenv("ROBBY_TEST_TOKEN", "secret")
+	path := filepath.Join(dir, "bob.yaml")
+	content := `
+base_url: ${ROBBY_TEST_BASE_URL}/v1/
+model: bob-model
+temperature: ${ROBBY_TEST_TEMPERATURE:-0.3}
+system_prompt: "You are Bob, use $${NAME} for the name"
+embedding_model: embedding-model
+rag_sources: [./docs]
+mcp_servers:
+  - name: calc
+    url: ` + mcpServer.server.URL + `/mcp
+    headers:
+      Authorization: Bearer ${ROBBY_TEST_TOKEN}
+tools: [add]
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	agent, err := NewAgentFromConfig(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+	if len(agent.Tools) != 1 || agent.Tools[0].Function.Name != "add" {
+		t.Errorf("tools = %v, want add", agent.Tools)
+	}
+	if got := mcpServer.Headers()[0].Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q", got)
+	}
+	records, err := agent.Store.GetAll()
+	if err != nil || len(records) != 1 || records[0].Metadata["source"] != filepath.Join(docs, "emma.md") {
+		t.Errorf("RAG memory = %v, %v", records, err)
+	}
+
+	if _, err := agent.ChatCompletion(); err != nil {
+		t.Fatal(err)
+	}
+	request := fake.Requests()[0]
+	if request["temperature"] != 0.3 {
+		t.Errorf("temperature = %v, want the default of the variable", request["temperature"])
+	}
+	if contents := requestContents(request); contents[0] != "You are Bob, use ${NAME} for the name" {
+		t.Errorf("system prompt = %q", contents[0])
+	}
+}
+
+func TestLoadAgentConfigRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bob.yaml")
+	absolute := filepath.Join(t.TempDir(), "notes.md")
+	content := "vector_store: data/store.json\nrag_sources: [./docs, " + absolute + "]\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadAgentConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "data", "store.json"); config.VectorStore != want {
+		t.Errorf("vector_store = %q, want %q", config.VectorStore, want)
+	}
+	if want := []string{filepath.Join(dir, "docs"), absolute}; strings.Join(config.RAGSources, "|") != strings.Join(want, "|") {
+		t.Errorf("rag_sources = %q, want %q", config.RAGSources, want)
+	}
+}
+
+func TestLoadAgentConfigErrors(t *testing.T) {
+	if _, err := LoadAgentConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("no error with a missing file")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"unknown field", "model: bob\nmodle: bob\n", []string{"line 2: modle: unknown field"}},
+		{"unknown nested field", `{"mcp_servers": [{"url": "http://localhost/mcp"}, {"commmand": ["mcp"]}]}`, []string{"mcp_servers[1].commmand: unknown field"}},
+		{"not a list", "mcp_servers:\n  - command: socat STDIO\n", []string{"line 2: mcp_servers[0].command: expected a list"}},
+		{"not a number", "temperature: hot\n", []string{"temperature: expected a number"}},
+		{"variable not set", "mcp_servers:\n  - url: http://localhost/mcp\n    headers:\n      Authorization: Bearer ${ROBBY_TEST_UNSET}\n",
+			[]string{"line 4: mcp_servers[0].headers.Authorization: environment variable ROBBY_TEST_UNSET is not set"}},
+		{"several errors", "modle: bob\ntemperature: [0.5]\n", []string{"modle: unknown field", "temperature: expected a number, not a list"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bob.yaml")
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadAgentConfig(path)
+			for _, want := range test.want {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %v, want %q", err, want)
+				}
+			}
+			var configError *ConfigError
+			if !errors.As(err, &configError) {
+				t.Errorf("error = %v, want a ConfigError", err)
+			}
+		})
+	}
+}
+
+func TestAgentConfigValidate(t *testing.T) {
+	valid := AgentConfig{BaseURL: "http://localhost:12434/engines/llama.cpp/v1/", Model: "bob-model"}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	hot := 3.0
+	tests := []struct {
+		name   string
+		change func(config *AgentConfig)
+		want   string
+	}{
+		{"no model", func(config *AgentConfig) { config.Model = "" }, "model: required"},
+		{"invalid base URL", func(config *AgentConfig) { config.BaseURL = "localhost:12434" }, "base_url: invalid URL"},
+		{"temperature", func(config *AgentConfig) { config.Temperature = &hot }, "temperature: 3 is not between 0 and 2"},
+		{"no command and no URL", func(config *AgentConfig) {
+			config.MCPServers = []MCPServerConfig{{Name: "toolkit"}}
+		}, "mcp_servers[0]: no command and no URL"},
+		{"command and URL", func(config *AgentConfig) {
+			config.MCPServers = []MCPServerConfig{{Command: []string{"mcp-server"}, URL: "http://localhost:9090/mcp"}}
+		}, "mcp_servers[0]: set a command or a URL, not both"},
+		{"invalid MCP URL", func(config *AgentConfig) {
+			config.MCPServers = []MCPServerConfig{{Name: "a", Command: []string{"mcp-server"}}, {Name: "b", URL: "localhost:9090"}}
+		}, "mcp_servers[1].url: invalid URL"},
+		{"duplicate names", func(config *AgentConfig) {
+			config.MCPServers = []MCPServerConfig{{Command: []string{"mcp-server"}}, {URL: "http://localhost:9090/mcp"}}
+		}, "mcp_servers[1].name: duplicate MCP server name"},
+		{"tools without MCP servers", func(config *AgentConfig) { config.Tools = []string{"fetch"} }, "tools: "},
+		{"RAG without embedding model", func(config *AgentConfig) { config.RAGSources = []string{"./docs"} }, "embedding_model: required"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := valid
+			test.change(&config)
+			err := config.Validate()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want %q", err, test.want)
+			}
+			if _, err := config.Options(context.Background()); err == nil {
+				t.Error("Options: no error")
+			}
+		})
+	}
+
+	config := valid
+	config.EmbeddingModel = "embedding-model"
+	config.RAGSources = []string{filepath.Join(t.TempDir(), "missing")}
+	if _, err := config.Options(context.Background()); err == nil || !strings.Contains(err.Error(), "rag_sources[0]: ") {
+		t.Errorf("missing RAG source: error = %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package robby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// EmbeddingFailureMode defines what WithRAGMemory and WithRAGMemoryChunks do when the embedding of a chunk fails.
+type EmbeddingFailureMode int
+
+const (
+	// EmbeddingFailFast stops at the first failed chunk: NewAgent returns an *EmbeddingError (and no Agent).
+	EmbeddingFailFast EmbeddingFailureMode = iota
+	// EmbeddingSkipAndReport saves all the other chunks: NewAgent returns the Agent with an *EmbeddingError
+	// listing the failed chunks (see FailedChunks).
+	EmbeddingSkipAndReport
+)
+
+// EmbeddingPolicy defines how WithRAGMemory and WithRAGMemoryChunks create the embeddings of the chunks:
+// the chunks are sent by batches (an embeddings request with an array of inputs), by concurrent workers,
+// and the failed requests are retried with an exponential backoff.
+// The zero values are replaced by the values of DefaultEmbeddingPolicy.
+type EmbeddingPolicy struct {
+	BatchSize      int                   // Maximum number of chunks of an embeddings request
+	Workers        int                   // Maximum number of concurrent embeddings requests
+	MaxRetries     int                   // Maximum number of retries of a failed request (a negative value disables the retries)
+	InitialBackoff time.Duration         // Delay before the first retry, doubled after every failed retry
+	MaxBackoff     time.Duration         // Maximum delay between two retries
+	FailureMode    EmbeddingFailureMode  // Fail fast (default), or skip the failed chunks and report them
+	Progress       func(done, total int) // Called after every batch, with the number of processed chunks (saved or failed)
+}
+
+// DefaultEmbeddingPolicy is the embedding policy used for the fields that are not set.
+var DefaultEmbeddingPolicy = EmbeddingPolicy{
+	BatchSize:      32,
+	Workers:        4,
+	MaxRetries:     3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// WithEmbeddingPolicy sets how the embeddings of the chunks of WithRAGMemory and WithRAGMemoryChunks are created
+// (batches, workers, retries, failure mode and progress).
+// It must be used before these options.
+// It returns an AgentOption that can be used to configure the agent.
+func WithEmbeddingPolicy(policy EmbeddingPolicy) AgentOption {
+	return func(agent *Agent) {
+		if policy.BatchSize <= 0 {
+			policy.BatchSize = DefaultEmbeddingPolicy.BatchSize
+		}
+		if policy.Workers <= 0 {
+			policy.Workers = DefaultEmbeddingPolicy.Workers
+		}
+		if policy.MaxRetries == 0 {
+			policy.MaxRetries = DefaultEmbeddingPolicy.MaxRetries
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = DefaultEmbeddingPolicy.InitialBackoff
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = DefaultEmbeddingPolicy.MaxBackoff
+		}
+		agent.embeddingPolicy = &policy
+	}
+}
+
+// FailedChunk is a chunk whose embedding could not be created or saved.
+type FailedChunk struct {
+	Index int    // the index of the chunk in the chunks of WithRAGMemory or WithRAGMemoryChunks
+	Text  string // the text of the chunk
+	Err   error
+}
+
+// EmbeddingError is the error of WithRAGMemory and WithRAGMemoryChunks when chunks could not be saved.
+// With EmbeddingFailFast, Failed holds the first failed chunk, and the chunks of the other batches may not be saved.
+// With EmbeddingSkipAndReport, Failed holds all the skipped chunks, and NewAgent returns the Agent with the error.
+type EmbeddingError struct {
+	Failed []FailedChunk // the failed chunks, by index
+	Total  int           // the number of chunks
+	Mode   EmbeddingFailureMode
+}
+
+func (e *EmbeddingError) Error() string {
+	if len(e.Failed) == 0 {
+		return "failed to create the embeddings of the chunks"
+	}
+	message := fmt.Sprintf("failed to create the embeddings of %d of %d chunks", len(e.Failed), e.Total)
+	if e.Mode == EmbeddingFailFast {
+		message = fmt.Sprintf("failed to create the embeddings of the %d chunks", e.Total)
+	}
+	return fmt.Sprintf("%s: chunk %d: %v", message, e.Failed[0].Index, e.Failed[0].Err)
+}
+
+// Unwrap returns the errors of the failed chunks.
+func (e *EmbeddingError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, failed := range e.Failed {
+		errs[i] = failed.Err
+	}
+	return errs
+}
+
+// FailedChunks returns the chunks of WithRAGMemory and WithRAGMemoryChunks that were skipped
+// with EmbeddingSkipAndReport (nil if all the chunks were saved), as listed by the *EmbeddingError of NewAgent.
+// The Err of a chunk can be the API error of its embeddings request (see errors.As and openai.Error).
+func (agent *Agent) FailedChunks() []FailedChunk {
+	if agent.skippedChunks == nil {
+		return nil
+	}
+	return agent.skippedChunks.Failed
+}
+
+// setEmbeddingError reports the error of saveChunks: with EmbeddingSkipAndReport, the failed chunks
+// of all the options are kept in one *EmbeddingError returned by NewAgent with the Agent,
+// the other errors make NewAgent fail.
+func (agent *Agent) setEmbeddingError(err error) {
+	var embeddingError *EmbeddingError
+	if errors.As(err, &embeddingError) && embeddingError.Mode == EmbeddingSkipAndReport {
+		if agent.skippedChunks == nil {
+			agent.skippedChunks = &EmbeddingError{Mode: EmbeddingSkipAndReport}
+		}
+		agent.skippedChunks.Failed = append(agent.skippedChunks.Failed, embeddingError.Failed...)
+		agent.skippedChunks.Total += embeddingError.Total
+		return
+	}
+	if err != nil {
+		agent.lastError = err
+	}
+}
+
+// embeddingBatch is a batch of chunks and its outcome.
+type embeddingBatch struct {
+	start      int // the index of the first chunk of the batch
+	chunks     []Chunk
+	embeddings [][]float64 // the embedding of every chunk (nil if it failed)
+	errs       []error     // the error of every failed chunk
+}
+
+// saveChunks creates the embeddings of the chunks with the embedding policy of the Agent,
+// and saves them in its vector store (created if needed). It returns an *EmbeddingError if chunks failed.
+func (agent *Agent) saveChunks(chunks []Chunk) error {
+	if agent.Store == nil {
+		agent.Store = &MemoryVectorStore{
+			Records: make(map[string]VectorRecord),
+			Model:   agent.EmbeddingParams.Model,
+		}
+	}
+	policy := agent.embeddingPolicyOrDefault()
+	ctx, cancel := context.WithCancel(agent.context())
+	defer cancel()
+
+	batches := make(chan *embeddingBatch)
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(chunks); start += policy.BatchSize {
+			batch := &embeddingBatch{start: start, chunks: chunks[start:min(start+policy.BatchSize, len(chunks))]}
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan *embeddingBatch)
+	workers := sync.WaitGroup{}
+	for range policy.Workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for batch := range batches {
+				agent.embedBatch(ctx, policy, batch)
+				results <- batch
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	// The records are saved by this goroutine only (the vector stores are not safe for concurrent use)
+	embeddingError := &EmbeddingError{Total: len(chunks), Mode: policy.FailureMode}
+	done := 0
+	for batch := range results {
+		if ctx.Err() != nil {
+			// Fail fast: the batches still running are dropped
+			continue
+		}
+		for i, chunk := range batch.chunks {
+			err := batch.errs[i]
+			if err == nil {
+				_, err = agent.Store.Save(VectorRecord{Prompt: chunk.Text, Embedding: batch.embeddings[i], Metadata: chunk.Metadata})
+			}
+			if err != nil {
+				embeddingError.Failed = append(embeddingError.Failed, FailedChunk{Index: batch.start + i, Text: chunk.Text, Err: err})
+				if policy.FailureMode == EmbeddingFailFast {
+					cancel()
+					break
+				}
+			}
+		}
+		done += len(batch.chunks)
+		if policy.Progress != nil {
+			policy.Progress(done, len(chunks))
+		}
+	}
+
+	if len(embeddingError.Failed) == 0 {
+		// The context of the Agent was canceled
+		if err := agent.context().Err(); err != nil {
+			return fmt.Errorf("failed to create the embeddings of the chunks: %w", err)
+		}
+		return nil
+	}
+	slices.SortFunc(embeddingError.Failed, func(a, b FailedChunk) int { return a.Index - b.Index })
+	return embeddingError
+}
+
+// embeddingPolicyOrDefault returns the embedding policy of the Agent, or DefaultEmbeddingPolicy.
+func (agent *Agent) embeddingPolicyOrDefault() *EmbeddingPolicy {
+	if agent.embeddingPolicy == nil {
+		return &DefaultEmbeddingPolicy
+	}
+	return agent.embeddingPolicy
+}
+
+// embedBatch creates the embeddings of the chunks of the batch. If the batch fails with an invalid request
+// (e.g. a chunk too long for the model), every chunk is sent alone, so only the invalid chunks fail.
+func (agent *Agent) embedBatch(ctx context.Context, policy *EmbeddingPolicy, batch *embeddingBatch) {
+	batch.embeddings = make([][]float64, len(batch.chunks))
+	batch.errs = make([]error, len(batch.chunks))
+	texts := make([]string, len(batch.chunks))
+	for i, chunk := range batch.chunks {
+		texts[i] = chunk.Text
+	}
+
+	embeddings, err := agent.createEmbeddings(ctx, policy, texts)
+	if err == nil {
+		batch.embeddings = embeddings
+		return
+	}
+	if len(texts) == 1 || !isInvalidRequest(err) {
+		for i := range batch.errs {
+			batch.errs[i] = err
+		}
+		return
+	}
+	for i, text := range texts {
+		if embeddings, err := agent.createEmbeddings(ctx, policy, []string{text}); err != nil {
+			batch.errs[i] = err
+		} else {
+			batch.embeddings[i] = embeddings[0]
+		}
+	}
+}
+
+// createEmbeddings sends an embeddings request for the texts, retried with an exponential backoff
+// (the invalid requests are not retried).
+func (agent *Agent) createEmbeddings(ctx context.Context, policy *EmbeddingPolicy, texts []string) ([][]float64, error) {
+	// The request parameters are copied: the workers use the same Agent
+	params := agent.EmbeddingParams
+	params.Input = openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts}
+
+	backoff := policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		// The retries of the client are disabled: the policy retries the requests
+		response, err := agent.dmrClient.Embeddings.New(ctx, params, option.WithMaxRetries(0))
+		if err == nil {
+			embeddings := make([][]float64, len(texts))
+			for _, data := range response.Data {
+				if data.Index < 0 || int(data.Index) >= len(texts) {
+					return nil, fmt.Errorf("invalid embedding index %d", data.Index)
+				}
+				embeddings[data.Index] = data.Embedding
+			}
+			for i, embedding := range embeddings {
+				if len(embedding) == 0 {
+					return nil, fmt.Errorf("no embedding for the input %d", i)
+				}
+			}
+			return embeddings, nil
+		}
+		if attempt >= policy.MaxRetries || isInvalidRequest(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff = min(2*backoff, policy.MaxBackoff)
+	}
+}
+
+// isInvalidRequest returns true if the error is a client error that would fail again (not a timeout or a rate limit).
+func isInvalidRequest(err error) bool {
+	var apiError *openai.Error
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	status := apiError.StatusCode
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
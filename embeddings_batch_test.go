@@ -0,0 +1,167 @@
+package robby
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// testChunks returns count chunks; the chunks of Mother are rejected by the fake model runner of the tests.
+func testChunks(count int) []string {
+	chunks := make([]string, count)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("chunk %d about Emma Peel", i)
+	}
+	return chunks
+}
+
+// rejectMother answers 400 to the embeddings requests with a chunk about Mother.
+func rejectMother(index int, inputs []string) int {
+	for _, input := range inputs {
+		if strings.Contains(input, "Mother") {
+			return http.StatusBadRequest
+		}
+	}
+	return 0
+}
+
+func TestEmbeddingBatches(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	progress := []int{}
+	mu := sync.Mutex{}
+	bob, err := NewAgent(
+		fake.Option(),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithEmbeddingPolicy(EmbeddingPolicy{BatchSize: 4, Workers: 2, Progress: func(done, total int) {
+			mu.Lock()
+			defer mu.Unlock()
+			if total != 10 {
+				t.Errorf("expected 10 chunks, got %d", total)
+			}
+			progress = append(progress, done)
+		}}),
+		WithRAGMemory(testChunks(10)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 requests with an array of inputs
+	requests := fake.EmbeddingRequests()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	sizes := []int{}
+	for _, request := range requests {
+		sizes = append(sizes, len(request["input"].([]any)))
+	}
+	slices.Sort(sizes)
+	if !slices.Equal(sizes, []int{2, 4, 4}) {
+		t.Fatalf("unexpected batch sizes: %v", sizes)
+	}
+
+	records, _ := bob.Store.GetAll()
+	prompts := []string{}
+	for _, record := range records {
+		prompts = append(prompts, record.Prompt)
+	}
+	slices.Sort(prompts)
+	expected := testChunks(10)
+	slices.Sort(expected)
+	if !slices.Equal(prompts, expected) {
+		t.Fatalf("unexpected records: %v", prompts)
+	}
+	if len(progress) != 3 || progress[2] != 10 || !slices.IsSorted(progress) {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+}
+
+func TestEmbeddingRetries(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	fake.EmbeddingStatus = func(index int, inputs []string) int {
+		if index < 2 {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	_, err := NewAgent(
+		fake.Option(),
+		WithEmbeddingPolicy(EmbeddingPolicy{Workers: 1, InitialBackoff: time.Millisecond}),
+		WithRAGMemory(testChunks(3)),
+	)
+	if err != nil || len(fake.EmbeddingRequests()) != 3 {
+		t.Fatalf("expected a success after 2 retries, got %d requests, %v", len(fake.EmbeddingRequests()), err)
+	}
+
+	fake = newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	fake.EmbeddingStatus = func(index int, inputs []string) int { return http.StatusServiceUnavailable }
+	_, err = NewAgent(
+		fake.Option(),
+		WithEmbeddingPolicy(EmbeddingPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+		WithRAGMemory(testChunks(3)),
+	)
+	embeddingError := &EmbeddingError{}
+	if !errors.As(err, &embeddingError) || len(fake.EmbeddingRequests()) != 3 {
+		t.Fatalf("expected an EmbeddingError after 3 attempts, got %d requests, %v", len(fake.EmbeddingRequests()), err)
+	}
+}
+
+func TestEmbeddingFailureModes(t *testing.T) {
+	chunks := testChunks(10)
+	chunks[6] = "Mother is the superior"
+
+	// Skip and report: the other chunks of the rejected batch are sent alone
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	fake.EmbeddingStatus = rejectMother
+	bob, err := NewAgent(
+		fake.Option(),
+		WithEmbeddingPolicy(EmbeddingPolicy{BatchSize: 4, FailureMode: EmbeddingSkipAndReport}),
+		WithRAGMemory(chunks),
+	)
+	embeddingError := &EmbeddingError{}
+	if bob == nil || !errors.As(err, &embeddingError) {
+		t.Fatalf("expected the agent and an EmbeddingError, got %v, %v", bob, err)
+	}
+	failed := embeddingError.Failed
+	if len(failed) != 1 || failed[0].Index != 6 || failed[0].Text != chunks[6] {
+		t.Fatalf("unexpected failed chunks: %+v", failed)
+	}
+	if agentFailed := bob.FailedChunks(); len(agentFailed) != 1 || agentFailed[0].Index != 6 {
+		t.Fatalf("unexpected failed chunks of the agent: %+v", bob.FailedChunks())
+	}
+	var apiError *openai.Error
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the API error of the chunk, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "1 of 10 chunks: chunk 6") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	if records, _ := bob.Store.GetAll(); len(records) != 9 {
+		t.Fatalf("expected 9 records, got %d", len(records))
+	}
+
+	// Fail fast: no Agent
+	fake = newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	fake.EmbeddingStatus = rejectMother
+	store := &MemoryVectorStore{}
+	embeddingError = &EmbeddingError{}
+	bob, err = NewAgent(
+		fake.Option(),
+		WithVectorStore(store),
+		WithEmbeddingPolicy(EmbeddingPolicy{BatchSize: 4, Workers: 1}),
+		WithRAGMemory(chunks),
+	)
+	if bob != nil || !errors.As(err, &embeddingError) || embeddingError.Failed[0].Index != 6 {
+		t.Fatalf("expected an EmbeddingError and no agent, got %v, %v", bob, err)
+	}
+	if records, _ := store.GetAll(); len(records) > 6 {
+		t.Fatalf("expected the chunks after the failed chunk not to be saved, got %d records", len(records))
+	}
+}
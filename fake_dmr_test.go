@@ -0,0 +1,56 @@
+package robby
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sea-monkeys/robby/internal/fakedmr"
+)
+
+// fakeDMR is an in-process OpenAI compatible server used to test the Agent
+// without a running Docker Model Runner (see fakedmr.Server).
+type fakeDMR struct {
+	*fakedmr.Server
+}
+
+// newFakeDMR starts a fake model runner.
+// The reply function receives the decoded request body and the index of the request,
+// and returns the chat completion message to send back (role, content, tool_calls...).
+func newFakeDMR(t *testing.T, reply fakedmr.Reply) *fakeDMR {
+	t.Helper()
+	return &fakeDMR{fakedmr.New(t, reply)}
+}
+
+// newFakeEmbeddingsDMR starts a fake model runner answering the embeddings requests with the embed function
+// (and the chat completion requests with the reply function, if any).
+func newFakeEmbeddingsDMR(t *testing.T, embed func(text string) []float64, reply fakedmr.Reply) *fakeDMR {
+	t.Helper()
+	fake := newFakeDMR(t, reply)
+	fake.Embed = embed
+	return fake
+}
+
+// newFakeStreamingDMR starts a fake model runner answering with Server-Sent Events.
+// The chunks function returns the choices of every chunk to stream (delta, finish_reason...).
+// The embeddings requests are answered if the Embed function of the fake is set.
+func newFakeStreamingDMR(t *testing.T, chunks fakedmr.Chunks) *fakeDMR {
+	t.Helper()
+	return &fakeDMR{fakedmr.NewStreaming(t, chunks)}
+}
+
+// Option returns the WithDMRClient option pointing to the fake server.
+func (fake *fakeDMR) Option() AgentOption {
+	return WithDMRClient(context.Background(), fake.BaseURL())
+}
+
+// fakeToolCall builds a tool call as returned by the model.
+func fakeToolCall(id, name, arguments string) map[string]any {
+	return map[string]any{
+		"id":   id,
+		"type": "function",
+		"function": map[string]any{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+}
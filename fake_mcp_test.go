@@ -0,0 +1,275 @@
+package robby
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMCPServer is an in-process MCP server used to test the MCP client transports.
+// It exposes the Streamable HTTP transport on /mcp and the HTTP+SSE transport on /sse (+ /messages).
+// Every tool answers with "<server name>:<tool name>:<arguments>".
+type fakeMCPServer struct {
+	name       string
+	tools      []string
+	server     *httptest.Server
+	streamSSE  bool // answer the Streamable HTTP requests with SSE instead of JSON
+	mu         sync.Mutex
+	headers    []http.Header
+	sseStreams map[string]chan []byte
+	done       chan struct{}
+	generation int // incremented when the sessions expire (server restart)
+}
+
+// newFakeMCPServer starts a fake MCP server with the given tools.
+func newFakeMCPServer(t *testing.T, name string, tools ...string) *fakeMCPServer {
+	t.Helper()
+	fake := &fakeMCPServer{
+		name:       name,
+		tools:      tools,
+		sseStreams: make(map[string]chan []byte),
+		done:       make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", fake.handleStreamableHTTP)
+	mux.HandleFunc("/sse", fake.handleSSE)
+	mux.HandleFunc("/messages", fake.handleSSEMessage)
+	fake.server = httptest.NewServer(mux)
+	t.Cleanup(fake.server.Close)
+	// The SSE streams must be closed before the server (cleanups run in reverse order)
+	t.Cleanup(func() { close(fake.done) })
+	return fake
+}
+
+// Headers returns the headers of the received POST requests.
+func (fake *fakeMCPServer) Headers() []http.Header {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.headers
+}
+
+// expireSession simulates a restart of the server: the requests of the current session are rejected.
+func (fake *fakeMCPServer) expireSession() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.generation++
+}
+
+// sessionID returns the ID of the current session.
+func (fake *fakeMCPServer) sessionID() string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.generation == 0 {
+		return "session-" + fake.name
+	}
+	return fmt.Sprintf("session-%s-%d", fake.name, fake.generation)
+}
+
+func (fake *fakeMCPServer) handleStreamableHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	fake.mu.Lock()
+	fake.headers = append(fake.headers, r.Header.Clone())
+	fake.mu.Unlock()
+	if session := r.Header.Get("Mcp-Session-Id"); session != "" && session != fake.sessionID() {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	response, isRequest := fake.handleMessage(body)
+	if !isRequest {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Mcp-Session-Id", fake.sessionID())
+	if fake.streamSSE {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", response)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(response)
+}
+
+func (fake *fakeMCPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher := w.(http.Flusher)
+	stream := make(chan []byte, 16)
+	fake.mu.Lock()
+	sessionID := fmt.Sprintf("%d", len(fake.sseStreams)+1)
+	fake.sseStreams[sessionID] = stream
+	fake.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "event: endpoint\ndata: /messages?sessionId=%s\n\n", sessionID)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-fake.done:
+			return
+		case message := <-stream:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", message)
+			flusher.Flush()
+		}
+	}
+}
+
+func (fake *fakeMCPServer) handleSSEMessage(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	fake.headers = append(fake.headers, r.Header.Clone())
+	stream, ok := fake.sseStreams[r.URL.Query().Get("sessionId")]
+	fake.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if response, isRequest := fake.handleMessage(body); isRequest {
+		stream <- response
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleMessage handles a JSON-RPC message and returns the JSON-RPC response (if the message is a request).
+func (fake *fakeMCPServer) handleMessage(body []byte) ([]byte, bool) {
+	var request struct {
+		Id     *int64          `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Id == nil {
+		return nil, false
+	}
+
+	result, err := fake.handleRequest(request.Method, request.Params)
+	message := map[string]any{"jsonrpc": "2.0", "id": *request.Id}
+	if err != nil {
+		message["error"] = map[string]any{"code": -32601, "message": err.Error()}
+	} else {
+		message["result"] = result
+	}
+	response, _ := json.Marshal(message)
+	return response, true
+}
+
+func (fake *fakeMCPServer) handleRequest(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": "2024-11-05",
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": fake.name, "version": "1.0.0"},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		tools := []map[string]any{}
+		for _, tool := range fake.tools {
+			tools = append(tools, map[string]any{
+				"name":        tool,
+				"description": tool + " tool of " + fake.name,
+				"inputSchema": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"name": map[string]any{"type": "string"}},
+					"required":   []string{"name"},
+				},
+			})
+		}
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var call struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		_ = json.Unmarshal(params, &call)
+		keys := []string{}
+		for key := range call.Arguments {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		arguments := ""
+		for _, key := range keys {
+			arguments += fmt.Sprintf("%s=%v", key, call.Arguments[key])
+		}
+		return map[string]any{"content": []map[string]any{
+			{"type": "text", "text": fmt.Sprintf("%s:%s:%s", fake.name, call.Name, arguments)},
+		}}, nil
+	case "resources/list":
+		return map[string]any{"resources": []map[string]any{
+			{"uri": fake.name + "://info", "name": fake.name + "-info", "description": "info of " + fake.name, "mimeType": "text/plain"},
+		}}, nil
+	case "resources/read":
+		var read struct {
+			Uri string `json:"uri"`
+		}
+		_ = json.Unmarshal(params, &read)
+		return map[string]any{"contents": []map[string]any{
+			{"uri": read.Uri, "mimeType": "text/plain", "text": "content of " + read.Uri},
+		}}, nil
+	case "prompts/list":
+		return map[string]any{"prompts": []map[string]any{
+			{"name": fake.name + "-greet", "description": "greet prompt of " + fake.name, "arguments": []map[string]any{{"name": "name", "required": true}}},
+		}}, nil
+	case "prompts/get":
+		return map[string]any{"description": "greet", "messages": []map[string]any{
+			{"role": "user", "content": map[string]any{"type": "text", "text": "Hello from " + fake.name}},
+		}}, nil
+	}
+	return nil, fmt.Errorf("method %s not found", method)
+}
+
+// fakeStdioMCPServerEnv is set to run the test binary as a STDIO MCP server (see TestFakeStdioMCPServer).
+const fakeStdioMCPServerEnv = "ROBBY_FAKE_STDIO_MCP_SERVER"
+
+// fakeStdioMCPServerCommand returns the command that runs the test binary as a STDIO MCP server with the given tools.
+// The "crash" tool makes the server exit with an error, the "change" tool adds a "subtract" tool (with a tools/list_changed notification);
+// with the "hang" mode, the server does not stop when its stdin is closed.
+func fakeStdioMCPServerCommand(t *testing.T, name string, mode string, tools ...string) STDIOCommandOption {
+	t.Setenv(fakeStdioMCPServerEnv, strings.Join(append([]string{name, mode}, tools...), ","))
+	return STDIOCommandOption{os.Args[0], "-test.run=^TestFakeStdioMCPServer$"}
+}
+
+// TestFakeStdioMCPServer is not a test: it is the STDIO MCP server started by fakeStdioMCPServerCommand.
+func TestFakeStdioMCPServer(t *testing.T) {
+	config := os.Getenv(fakeStdioMCPServerEnv)
+	if config == "" {
+		t.Skip("only run as a STDIO MCP server")
+	}
+	parts := strings.Split(config, ",")
+	fake := &fakeMCPServer{name: parts[0], tools: parts[2:]}
+	fmt.Fprintf(os.Stderr, "server %s started\n", fake.name)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if bytes.Contains(scanner.Bytes(), []byte(`"name":"crash"`)) {
+			fmt.Fprintln(os.Stderr, "boom: the server crashed")
+			os.Exit(3)
+		}
+		if bytes.Contains(scanner.Bytes(), []byte(`"name":"change"`)) {
+			// The "change" tool adds the "subtract" tool and notifies the client
+			fake.tools = append(fake.tools, "subtract")
+			fmt.Println(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+		}
+		if response, isRequest := fake.handleMessage(scanner.Bytes()); isRequest {
+			_, _ = os.Stdout.Write(append(response, '\n'))
+		}
+	}
+	if parts[1] == "hang" {
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,32 @@
+package robby
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a file with the write function, through a temporary file of the same directory
+// renamed at the end, so a crash never leaves a truncated file.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	buffer := bufio.NewWriter(temp)
+	if err := write(buffer); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := buffer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,318 @@
+package robby
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// messageTokensOverhead is the estimated number of tokens added by the chat template to every message (role, separators).
+const messageTokensOverhead = 4
+
+// historySummaryPrefix starts the system message that replaces the turns summarized by SummarizeOlderTurns.
+const historySummaryPrefix = "Summary of the earlier conversation:\n"
+
+// DefaultSummaryPrompt is the system prompt used by SummarizeOlderTurns to summarize the older turns of the conversation.
+const DefaultSummaryPrompt = "Summarize the following conversation in a few sentences. " +
+	"Keep the facts, the names, the decisions and the results of the tools that are needed to continue the conversation."
+
+// TokenEstimator estimates the number of tokens of a text.
+// It is used by the history policies to compute the size of the conversation (see WithTokenEstimator).
+type TokenEstimator interface {
+	EstimateTokens(text string) int
+}
+
+// TokenEstimatorFunc is a function that implements TokenEstimator.
+type TokenEstimatorFunc func(text string) int
+
+// EstimateTokens implements TokenEstimator.
+func (f TokenEstimatorFunc) EstimateTokens(text string) int {
+	return f(text)
+}
+
+// HeuristicTokenEstimator is the default TokenEstimator: it does not need a tokenizer.
+// It counts one token for 4 ASCII characters, and one token for every other character (accents, CJK, emojis...).
+type HeuristicTokenEstimator struct{}
+
+// EstimateTokens implements TokenEstimator.
+func (HeuristicTokenEstimator) EstimateTokens(text string) int {
+	ascii, others := 0, 0
+	for _, r := range text {
+		if r < 128 {
+			ascii++
+		} else {
+			others++
+		}
+	}
+	return (ascii+3)/4 + others
+}
+
+// HistoryPolicy reduces the conversation history (the Agent's messages) before every completion
+// (see WithHistoryPolicy). Apply returns the messages to keep.
+type HistoryPolicy interface {
+	Apply(agent *Agent, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error)
+}
+
+// WithHistoryPolicy sets the policy applied to the conversation history before every completion
+// (ChatCompletion, ChatCompletionStream, ToolsCompletion, ChatCompletionStreamWithTools and every iteration of RunToolLoop),
+// to keep the conversation in the context window of the model: see KeepLastTurns, TokenBudgetWindow and SummarizeOlderTurns.
+// The Agent's messages are replaced by the reduced history.
+// It returns an AgentOption that can be used to configure the agent.
+func WithHistoryPolicy(policy HistoryPolicy) AgentOption {
+	return func(agent *Agent) {
+		agent.historyPolicy = policy
+	}
+}
+
+// WithTokenEstimator sets the TokenEstimator used by the history policies (default: HeuristicTokenEstimator).
+// It returns an AgentOption that can be used to configure the agent.
+func WithTokenEstimator(estimator TokenEstimator) AgentOption {
+	return func(agent *Agent) {
+		agent.tokenEstimator = estimator
+	}
+}
+
+// EstimateTokens estimates the number of tokens of the messages with the TokenEstimator of the Agent.
+func (agent *Agent) EstimateTokens(messages []openai.ChatCompletionMessageParamUnion) int {
+	estimator := agent.tokenEstimator
+	if estimator == nil {
+		estimator = HeuristicTokenEstimator{}
+	}
+	tokens := 0
+	for _, message := range messages {
+		tokens += estimator.EstimateTokens(messageText(message)) + messageTokensOverhead
+	}
+	return tokens
+}
+
+// applyHistoryPolicy replaces the Agent's messages by the messages kept by the history policy (if any).
+func (agent *Agent) applyHistoryPolicy() error {
+	if agent.historyPolicy == nil {
+		return nil
+	}
+	messages, err := agent.historyPolicy.Apply(agent, agent.Params.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to apply the history policy: %w", err)
+	}
+	agent.Params.Messages = messages
+	return nil
+}
+
+// KeepLastTurns returns a HistoryPolicy that keeps the system messages and the last turns of the conversation.
+// A turn starts with a user message and contains the answers of the assistant, its tool calls and the tool results.
+// The last turn is always kept (turns <= 0 is the same as 1).
+func KeepLastTurns(turns int) HistoryPolicy {
+	return keepLastTurnsPolicy{turns: turns}
+}
+
+type keepLastTurnsPolicy struct {
+	turns int
+}
+
+// Apply implements HistoryPolicy.
+func (policy keepLastTurnsPolicy) Apply(agent *Agent, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	cut := turnsStart(messages, policy.turns)
+	return append(systemMessages(messages[:cut]), messages[cut:]...), nil
+}
+
+// TokenBudgetWindow returns a HistoryPolicy that keeps the conversation under maxTokens tokens
+// (estimated with the TokenEstimator of the Agent) by removing the oldest messages.
+// The system messages are always kept, a tool call is never separated from its tool results,
+// and the last message (or tool call with its results) is always kept, even if it exceeds the budget.
+func TokenBudgetWindow(maxTokens int) HistoryPolicy {
+	return tokenBudgetPolicy{maxTokens: maxTokens}
+}
+
+type tokenBudgetPolicy struct {
+	maxTokens int
+}
+
+// Apply implements HistoryPolicy.
+func (policy tokenBudgetPolicy) Apply(agent *Agent, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	total := agent.EstimateTokens(messages)
+	if total <= policy.maxTokens {
+		return messages, nil
+	}
+
+	blocks := messageBlocks(messages)
+	dropped := make([]bool, len(blocks))
+	// The oldest blocks are removed first; the system messages and the last block are kept
+	for i := 0; i < len(blocks)-1 && total > policy.maxTokens; i++ {
+		if isSystemMessage(blocks[i][0]) {
+			continue
+		}
+		total -= agent.EstimateTokens(blocks[i])
+		dropped[i] = true
+	}
+
+	kept := []openai.ChatCompletionMessageParamUnion{}
+	for i, block := range blocks {
+		if !dropped[i] {
+			kept = append(kept, block...)
+		}
+	}
+	return kept, nil
+}
+
+// SummarizeOlderTurns returns a HistoryPolicy that, when the conversation exceeds maxTokens tokens
+// (estimated with the TokenEstimator of the Agent), asks the model of the Agent to summarize the turns older than the last keepTurns turns.
+// The summarized turns are replaced by a system message with the summary (it is summarized again with the next older turns).
+// The system messages and the last turn are kept (keepTurns <= 0 is the same as 1).
+// The prompt used to summarize is DefaultSummaryPrompt.
+func SummarizeOlderTurns(keepTurns int, maxTokens int) HistoryPolicy {
+	return summarizePolicy{keepTurns: keepTurns, maxTokens: maxTokens, prompt: DefaultSummaryPrompt}
+}
+
+type summarizePolicy struct {
+	keepTurns int
+	maxTokens int
+	prompt    string
+}
+
+// Apply implements HistoryPolicy.
+func (policy summarizePolicy) Apply(agent *Agent, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	if agent.EstimateTokens(messages) <= policy.maxTokens {
+		return messages, nil
+	}
+	cut := turnsStart(messages, policy.keepTurns)
+
+	kept := []openai.ChatCompletionMessageParamUnion{}
+	transcript := strings.Builder{}
+	for _, message := range messages[:cut] {
+		text := messageText(message)
+		switch {
+		case isSystemMessage(message) && strings.HasPrefix(text, historySummaryPrefix):
+			// the previous summary is summarized again with the older turns
+			transcript.WriteString(text + "\n")
+		case isSystemMessage(message):
+			kept = append(kept, message)
+		default:
+			transcript.WriteString(messageRole(message) + ": " + text + "\n")
+		}
+	}
+	if transcript.Len() == 0 {
+		// nothing to summarize
+		return messages, nil
+	}
+
+	completion, err := agent.dmrClient.Chat.Completions.New(agent.context(), openai.ChatCompletionNewParams{
+		Model: agent.Params.Model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(policy.prompt),
+			openai.UserMessage(transcript.String()),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize the conversation: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("failed to summarize the conversation: no choices found")
+	}
+
+	kept = append(kept, openai.SystemMessage(historySummaryPrefix+completion.Choices[0].Message.Content))
+	return append(kept, messages[cut:]...), nil
+}
+
+// turnsStart returns the index of the first message of the last turns of the conversation
+// (a turn starts with a user message), or 0 if there are not more turns.
+// At least the last turn is kept (the question being asked), even if turns <= 0.
+func turnsStart(messages []openai.ChatCompletionMessageParamUnion, turns int) int {
+	turns = max(turns, 1)
+	count := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].OfUser != nil {
+			count++
+			if count == turns {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// messageBlocks splits the messages into blocks that must be kept or removed together:
+// an assistant message with tool calls and the following tool results, or a single message.
+func messageBlocks(messages []openai.ChatCompletionMessageParamUnion) [][]openai.ChatCompletionMessageParamUnion {
+	blocks := [][]openai.ChatCompletionMessageParamUnion{}
+	for i := 0; i < len(messages); {
+		end := i + 1
+		if messages[i].OfAssistant != nil && len(messages[i].OfAssistant.ToolCalls) > 0 {
+			for end < len(messages) && messages[end].OfTool != nil {
+				end++
+			}
+		}
+		blocks = append(blocks, messages[i:end])
+		i = end
+	}
+	return blocks
+}
+
+// systemMessages returns the system (and developer) messages.
+func systemMessages(messages []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	system := []openai.ChatCompletionMessageParamUnion{}
+	for _, message := range messages {
+		if isSystemMessage(message) {
+			system = append(system, message)
+		}
+	}
+	return system
+}
+
+// isSystemMessage returns true for the system and developer messages.
+func isSystemMessage(message openai.ChatCompletionMessageParamUnion) bool {
+	return message.OfSystem != nil || message.OfDeveloper != nil
+}
+
+// messageRole returns the role of the message ("system", "user", "assistant", "tool"...).
+func messageRole(message openai.ChatCompletionMessageParamUnion) string {
+	switch {
+	case message.OfSystem != nil:
+		return "system"
+	case message.OfDeveloper != nil:
+		return "developer"
+	case message.OfUser != nil:
+		return "user"
+	case message.OfAssistant != nil:
+		return "assistant"
+	case message.OfTool != nil:
+		return "tool"
+	case message.OfFunction != nil:
+		return "function"
+	}
+	return ""
+}
+
+// messageText returns the text of the message: its content (the text parts only),
+// and the names and arguments of its tool calls.
+func messageText(message openai.ChatCompletionMessageParamUnion) string {
+	parts := []string{}
+	switch content := message.GetContent().AsAny().(type) {
+	case *string:
+		parts = append(parts, *content)
+	case *[]openai.ChatCompletionContentPartTextParam:
+		for _, part := range *content {
+			parts = append(parts, part.Text)
+		}
+	case *[]openai.ChatCompletionContentPartUnionParam:
+		for _, part := range *content {
+			if part.OfText != nil {
+				parts = append(parts, part.OfText.Text)
+			}
+		}
+	case *[]openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion:
+		for _, part := range *content {
+			if part.OfText != nil {
+				parts = append(parts, part.OfText.Text)
+			}
+			if part.OfRefusal != nil {
+				parts = append(parts, part.OfRefusal.Refusal)
+			}
+		}
+	}
+	for _, toolCall := range message.GetToolCalls() {
+		parts = append(parts, toolCall.Function.Name+" "+toolCall.Function.Arguments)
+	}
+	return strings.Join(parts, "\n")
+}
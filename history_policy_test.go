@@ -0,0 +1,157 @@
+package robby
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// requestContents returns the contents of the messages of a recorded request.
+func requestContents(request map[string]any) []string {
+	contents := []string{}
+	for _, message := range request["messages"].([]any) {
+		content, _ := message.(map[string]any)["content"].(string)
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func TestKeepLastTurns(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "answer"}
+	})
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+		WithHistoryPolicy(KeepLastTurns(2)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.AddSystemMessage("You are a Star Trek expert")
+	for _, question := range []string{"q1", "q2", "q3"} {
+		if _, err := bob.Ask(question); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := "You are a Star Trek expert,q2,answer,q3"
+	if contents := strings.Join(requestContents(fake.Requests()[2]), ","); contents != expected {
+		t.Fatalf("expected %q, got %q", expected, contents)
+	}
+}
+
+func TestKeepLastTurnsKeepsTheQuestion(t *testing.T) {
+	for _, turns := range []int{0, -1} {
+		fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+			return map[string]any{"role": "assistant", "content": "answer"}
+		})
+		bob, err := NewAgent(
+			fake.Option(),
+			WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+			WithHistoryPolicy(KeepLastTurns(turns)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bob.AddSystemMessage("You are a Star Trek expert")
+		for _, question := range []string{"q1", "q2"} {
+			if _, err := bob.Ask(question); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := "You are a Star Trek expert,q2"
+		if contents := strings.Join(requestContents(fake.Requests()[1]), ","); contents != expected {
+			t.Errorf("KeepLastTurns(%d): expected %q, got %q", turns, expected, contents)
+		}
+	}
+}
+
+func TestTokenBudgetWindowKeepsToolCallsWithResults(t *testing.T) {
+	estimator := TokenEstimatorFunc(func(text string) int { return len(text) })
+	bob, err := NewAgent(WithTokenEstimator(estimator))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toolCall := openai.ChatCompletionMessage{
+		Role: "assistant",
+		ToolCalls: []openai.ChatCompletionMessageToolCall{
+			{ID: "1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "add", Arguments: `{"a":1,"b":2}`}},
+		},
+	}.ToParam()
+	messages := []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("system"),
+		openai.UserMessage("an old question"),
+		openai.AssistantMessage("an old answer"),
+		openai.UserMessage("add 1 and 2"),
+		toolCall,
+		openai.ToolMessage("3", "1"),
+		openai.UserMessage("thanks"),
+	}
+
+	// The budget is too small to keep the tool call with its result: both are removed
+	budget := bob.EstimateTokens(messages) - bob.EstimateTokens(messages[1:5])
+	kept, err := TokenBudgetWindow(budget).Apply(bob, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0].OfSystem == nil || kept[1].OfUser == nil {
+		t.Fatalf("unexpected messages: %+v", kept)
+	}
+
+	// The tool call and its result are kept together
+	budget = bob.EstimateTokens(messages) - bob.EstimateTokens(messages[1:4])
+	kept, _ = TokenBudgetWindow(budget).Apply(bob, messages)
+	if len(kept) != 4 || kept[1].OfAssistant == nil || kept[2].OfTool == nil {
+		t.Fatalf("unexpected messages: %+v", kept)
+	}
+
+	// The last message is always kept
+	kept, _ = TokenBudgetWindow(1).Apply(bob, messages)
+	if len(kept) != 2 || kept[1].OfUser == nil {
+		t.Fatalf("unexpected messages: %+v", kept)
+	}
+}
+
+func TestSummarizeOlderTurns(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		if strings.HasPrefix(requestContents(request)[0], DefaultSummaryPrompt) {
+			return map[string]any{"role": "assistant", "content": "Bob asked about Kirk"}
+		}
+		return map[string]any{"role": "assistant", "content": "answer"}
+	})
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+		WithHistoryPolicy(SummarizeOlderTurns(1, 30)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.AddSystemMessage("You are a Star Trek expert")
+	bob.AddUserMessage("Who is James Kirk?")
+	bob.AddAssistantMessage("James T. Kirk is the captain of the USS Enterprise")
+
+	if _, err := bob.Ask("Who is his best friend?"); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected a summary request and a chat request, got %d requests", len(requests))
+	}
+	transcript := requestContents(requests[0])[1]
+	if !strings.Contains(transcript, "user: Who is James Kirk?") || strings.Contains(transcript, "best friend") {
+		t.Fatalf("unexpected transcript: %q", transcript)
+	}
+	expected := []string{"You are a Star Trek expert", historySummaryPrefix + "Bob asked about Kirk", "Who is his best friend?"}
+	if contents := requestContents(requests[1]); strings.Join(contents, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected %q, got %q", expected, contents)
+	}
+	if len(bob.Messages()) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(bob.Messages()))
+	}
+}
@@ -0,0 +1,227 @@
+// Package fakedmr provides an in-process OpenAI compatible model runner (a fake Docker Model Runner)
+// to test the Agents, the servers and the CLI without a running model.
+package fakedmr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Reply returns the chat completion message to send back (role, content, tool_calls...)
+// for the decoded request body and the index of the request. A nil message is answered with an error.
+type Reply func(index int, request map[string]any) map[string]any
+
+// Chunks returns the choices of the chunks to stream (delta, finish_reason...)
+// for the decoded request body and the index of the request.
+type Chunks func(index int, request map[string]any) []map[string]any
+
+// Server is a fake model runner. Every chat completion request is recorded and answered by the Reply function,
+// as a completion or as a stream of chunks (one chunk per word, the tool calls in the first chunk)
+// when the request asks for a stream, or by the Chunks function (see NewStreaming).
+// The embeddings requests are answered by the Embed function.
+type Server struct {
+	*httptest.Server
+
+	// Embed returns the embedding of a text (the embeddings requests are not found if it is nil).
+	Embed func(text string) []float64
+	// CompletionStatus returns the HTTP status of every chat completion request (0: OK).
+	CompletionStatus func(index int, request map[string]any) int
+	// EmbeddingStatus returns the HTTP status of every embeddings request (0: OK).
+	EmbeddingStatus func(index int, inputs []string) int
+
+	reply             Reply
+	chunks            Chunks
+	mu                sync.Mutex
+	requests          []map[string]any
+	embeddingRequests []map[string]any
+}
+
+// New starts a fake model runner answering the chat completion requests with the reply function (if any).
+// It is closed at the end of the test.
+func New(t testing.TB, reply Reply) *Server {
+	t.Helper()
+	fake := &Server{reply: reply}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// NewStreaming starts a fake model runner answering every chat completion request
+// with the Server-Sent Events of the chunks function, even if the request does not ask for a stream.
+func NewStreaming(t testing.TB, chunks Chunks) *Server {
+	t.Helper()
+	fake := New(t, nil)
+	fake.chunks = chunks
+	return fake
+}
+
+// Answer returns a Reply answering every request with the content.
+func Answer(content string) Reply {
+	return func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": content}
+	}
+}
+
+// BaseURL returns the base URL of the fake model runner, to use with robby.WithDMRClient.
+func (fake *Server) BaseURL() string {
+	return fake.URL + "/v1/"
+}
+
+// Requests returns the chat completion request bodies received so far.
+func (fake *Server) Requests() []map[string]any {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]map[string]any(nil), fake.requests...)
+}
+
+// EmbeddingRequests returns the embeddings request bodies received so far.
+func (fake *Server) EmbeddingRequests() []map[string]any {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]map[string]any(nil), fake.embeddingRequests...)
+}
+
+func (fake *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var request map[string]any
+	_ = json.Unmarshal(body, &request)
+
+	if strings.HasSuffix(r.URL.Path, "/embeddings") {
+		fake.serveEmbeddings(w, request)
+		return
+	}
+
+	fake.mu.Lock()
+	index := len(fake.requests)
+	fake.requests = append(fake.requests, request)
+	fake.mu.Unlock()
+
+	if fake.CompletionStatus != nil {
+		if status := fake.CompletionStatus(index, request); status != 0 {
+			writeError(w, status, "fake error")
+			return
+		}
+	}
+	switch {
+	case fake.chunks != nil:
+		writeChunks(w, fake.chunks(index, request))
+	case fake.reply == nil:
+		writeError(w, http.StatusNotFound, "no chat model")
+	default:
+		fake.serveCompletion(w, request, fake.reply(index, request))
+	}
+}
+
+// serveCompletion answers a chat completion request with the message, as a completion or as a stream.
+func (fake *Server) serveCompletion(w http.ResponseWriter, request map[string]any, message map[string]any) {
+	if message == nil {
+		writeError(w, http.StatusInternalServerError, "model failure")
+		return
+	}
+	finishReason := "stop"
+	if _, ok := message["tool_calls"]; ok {
+		finishReason = "tool_calls"
+	}
+	if stream, _ := request["stream"].(bool); !stream {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion",
+			"created": 0,
+			"model":   "fake-model",
+			"choices": []map[string]any{{"index": 0, "message": message, "finish_reason": finishReason}},
+		})
+		return
+	}
+
+	content, _ := message["content"].(string)
+	words := strings.SplitAfter(content, " ")
+	chunks := []map[string]any{}
+	for i, word := range words {
+		delta := map[string]any{"content": word}
+		if i == 0 {
+			delta["role"] = "assistant"
+			if toolCalls, ok := message["tool_calls"].([]map[string]any); ok {
+				for index, toolCall := range toolCalls {
+					toolCall["index"] = index
+				}
+				delta["tool_calls"] = toolCalls
+			}
+		}
+		chunk := map[string]any{"delta": delta}
+		if i == len(words)-1 {
+			chunk["finish_reason"] = finishReason
+		}
+		chunks = append(chunks, chunk)
+	}
+	writeChunks(w, chunks)
+}
+
+// serveEmbeddings answers an embeddings request (the input is a string or an array of strings).
+func (fake *Server) serveEmbeddings(w http.ResponseWriter, request map[string]any) {
+	if fake.Embed == nil {
+		writeError(w, http.StatusNotFound, "no embedding model")
+		return
+	}
+	fake.mu.Lock()
+	index := len(fake.embeddingRequests)
+	fake.embeddingRequests = append(fake.embeddingRequests, request)
+	fake.mu.Unlock()
+
+	inputs := []string{}
+	switch input := request["input"].(type) {
+	case string:
+		inputs = append(inputs, input)
+	case []any:
+		for _, text := range input {
+			inputs = append(inputs, text.(string))
+		}
+	}
+	if fake.EmbeddingStatus != nil {
+		if status := fake.EmbeddingStatus(index, inputs); status != 0 {
+			writeError(w, status, "fake error")
+			return
+		}
+	}
+	data := []map[string]any{}
+	for i, text := range inputs {
+		data = append(data, map[string]any{"object": "embedding", "index": i, "embedding": fake.Embed(text)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"model":  request["model"],
+		"data":   data,
+		"usage":  map[string]any{"prompt_tokens": 0, "total_tokens": 0},
+	})
+}
+
+// writeChunks sends the choices as the Server-Sent Events of a chat completion stream.
+func writeChunks(w http.ResponseWriter, choices []map[string]any) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, choice := range choices {
+		choice["index"] = 0
+		data, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion.chunk",
+			"created": 0,
+			"model":   "fake-model",
+			"choices": []map[string]any{choice},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+// writeError sends an OpenAI error.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": message, "type": "fake"}})
+}
@@ -0,0 +1,364 @@
+package robby
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Document is the text of a loaded file, with its metadata: "source" (the path of the file), "type"
+// ("text", "markdown", "html", "code", "json") and, depending on the type, "title" or "language"
+// (see ChunkDocuments to split the documents in chunks for the RAG memory).
+type Document struct {
+	Text     string
+	Metadata map[string]any
+}
+
+// Loader loads the documents of a file.
+type Loader func(path string) ([]Document, error)
+
+// DefaultLoaders are the loaders of LoadDirectory, by file extension.
+// The JSON files are not loaded by default: most of them are not documents (package.json, vector stores, sessions...),
+// add JSONLoader or JSONLLoader for the files with a text field.
+var DefaultLoaders = map[string]Loader{
+	".txt":      LoadTextFile,
+	".text":     LoadTextFile,
+	".md":       LoadMarkdownFile,
+	".markdown": LoadMarkdownFile,
+	".html":     LoadHTMLFile,
+	".htm":      LoadHTMLFile,
+}
+
+// codeLanguages are the languages of the code files, by file extension.
+var codeLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript", ".java": "java", ".kt": "kotlin",
+	".rs": "rust", ".c": "c", ".h": "c", ".cpp": "cpp", ".cs": "csharp", ".rb": "ruby", ".php": "php",
+	".swift": "swift", ".sh": "shell", ".sql": "sql",
+}
+
+func init() {
+	for extension := range codeLanguages {
+		DefaultLoaders[extension] = LoadCodeFile
+	}
+}
+
+// LoadTextFile loads a text file (type "text").
+func LoadTextFile(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return []Document{{Text: string(data), Metadata: map[string]any{"source": path, "type": "text"}}}, nil
+}
+
+// LoadMarkdownFile loads a Markdown file (type "markdown"); its title is its first heading.
+func LoadMarkdownFile(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	document := Document{Text: string(data), Metadata: map[string]any{"source": path, "type": "markdown"}}
+	for _, line := range strings.Split(document.Text, "\n") {
+		if match := markdownHeading.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			document.Metadata["title"] = match[2]
+			break
+		}
+	}
+	return []Document{document}, nil
+}
+
+// LoadCodeFile loads a source code file (type "code"); its language is given by the file extension.
+func LoadCodeFile(path string) ([]Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]any{"source": path, "type": "code"}
+	if language, ok := codeLanguages[strings.ToLower(filepath.Ext(path))]; ok {
+		metadata["language"] = language
+	}
+	return []Document{{Text: string(data), Metadata: metadata}}, nil
+}
+
+// LoadHTMLFile loads the visible text of an HTML file (type "html"), without the scripts and the styles.
+// The headings are converted to Markdown headings (so MarkdownChunker keeps the heading path),
+// and the title is the <title> of the page.
+func LoadHTMLFile(path string) ([]Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	root, err := html.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTML file %s: %w", path, err)
+	}
+
+	document := Document{Metadata: map[string]any{"source": path, "type": "html"}}
+	if title := findHTMLElement(root, "title"); title != nil {
+		document.Metadata["title"] = strings.Join(strings.Fields(htmlText(title)), " ")
+	}
+	text := &htmlTextWriter{}
+	text.render(root)
+	document.Text = text.String()
+	return []Document{document}, nil
+}
+
+// JSONLoader returns a Loader of the JSON files that contain an object or an array of objects (type "json"):
+// every object is a document, its textField is the text and its other fields (strings, numbers, booleans)
+// are the metadata, with its "index" in the array. With an empty textField, the text is the whole object.
+func JSONLoader(textField string) Loader {
+	return func(path string) ([]Document, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var objects []map[string]any
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			objects = make([]map[string]any, 1)
+			err = json.Unmarshal(trimmed, &objects[0])
+		} else {
+			err = json.Unmarshal(trimmed, &objects)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON file %s: %w", path, err)
+		}
+		documents := make([]Document, 0, len(objects))
+		for i, object := range objects {
+			document, err := jsonDocument(object, textField)
+			if err != nil {
+				return nil, fmt.Errorf("%s: object %d: %w", path, i, err)
+			}
+			document.Metadata["source"], document.Metadata["index"] = path, i
+			documents = append(documents, document)
+		}
+		return documents, nil
+	}
+}
+
+// JSONLLoader returns a Loader of the JSON Lines files (one object per line, type "json"), like JSONLoader
+// (the metadata "line" is the line number of the object).
+func JSONLLoader(textField string) Loader {
+	return func(path string) ([]Document, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		var documents []Document
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			object := map[string]any{}
+			if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid JSON: %w", path, line, err)
+			}
+			document, err := jsonDocument(object, textField)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			document.Metadata["source"], document.Metadata["line"] = path, line
+			documents = append(documents, document)
+		}
+		return documents, scanner.Err()
+	}
+}
+
+// LoadDirectory loads the files of a directory and its sub-directories with the loaders of their extensions
+// (DefaultLoaders if loaders is nil). The other files, and the hidden files and directories, are skipped.
+func LoadDirectory(dir string, loaders map[string]Loader) ([]Document, error) {
+	if loaders == nil {
+		loaders = DefaultLoaders
+	}
+	var documents []Document
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		loader, ok := loaders[strings.ToLower(filepath.Ext(path))]
+		if entry.IsDir() || !ok {
+			return nil
+		}
+		loaded, err := loader(path)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, loaded...)
+		return nil
+	})
+	return documents, err
+}
+
+// jsonDocument returns the document of a JSON object.
+func jsonDocument(object map[string]any, textField string) (Document, error) {
+	document := Document{Metadata: map[string]any{"type": "json"}}
+	if textField == "" {
+		data, err := json.Marshal(object)
+		if err != nil {
+			return document, err
+		}
+		document.Text = string(data)
+		return document, nil
+	}
+	text, ok := object[textField].(string)
+	if !ok {
+		return document, fmt.Errorf("no text field %q", textField)
+	}
+	document.Text = text
+	for key, value := range object {
+		switch value.(type) {
+		case string, float64, bool:
+			if key != textField {
+				document.Metadata[key] = value
+			}
+		}
+	}
+	document.Metadata["type"] = "json"
+	return document, nil
+}
+
+// findHTMLElement returns the first element with the tag, or nil.
+func findHTMLElement(node *html.Node, tag string) *html.Node {
+	if node.Type == html.ElementNode && node.Data == tag {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findHTMLElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// htmlText returns the text of a node and its descendants.
+func htmlText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	text := strings.Builder{}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(htmlText(child))
+	}
+	return text.String()
+}
+
+// htmlSkippedElements are the elements without visible text.
+var htmlSkippedElements = map[string]bool{"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true}
+
+// htmlBlockElements are the elements separated by blank lines.
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "header": true, "footer": true, "nav": true,
+	"aside": true, "blockquote": true, "ul": true, "ol": true, "table": true, "form": true, "figure": true, "hr": true,
+}
+
+// htmlTextWriter renders the visible text of an HTML document.
+type htmlTextWriter struct {
+	strings.Builder
+	pre   int  // the depth of the <pre> elements
+	space bool // a space is written before the next text
+}
+
+// render writes the text of the node and its descendants.
+func (w *htmlTextWriter) render(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		if w.pre > 0 {
+			w.WriteString(node.Data)
+			return
+		}
+		// The spaces between the words of the text nodes are kept, but not at the start of the lines
+		text := strings.Join(strings.Fields(node.Data), " ")
+		if node.Data != "" && strings.TrimSpace(node.Data[:1]) == "" {
+			w.space = true
+		}
+		if text != "" {
+			if w.space && !w.lineStart() {
+				w.WriteString(" ")
+			}
+			w.WriteString(text)
+			w.space = strings.TrimSpace(node.Data[len(node.Data)-1:]) == ""
+		}
+		return
+	case html.ElementNode:
+		if htmlSkippedElements[node.Data] {
+			return
+		}
+		switch node.Data {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			w.paragraph()
+			w.WriteString(strings.Repeat("#", int(node.Data[1]-'0')) + " " + strings.Join(strings.Fields(htmlText(node)), " "))
+			w.paragraph()
+			return
+		case "br":
+			w.WriteString("\n")
+			w.space = false
+			return
+		case "li":
+			w.line()
+			w.WriteString("- ")
+		case "tr":
+			w.line()
+		case "td", "th":
+			if !w.lineStart() {
+				w.WriteString(" | ")
+			}
+		case "pre":
+			w.paragraph()
+			w.pre++
+			defer func() { w.pre--; w.paragraph() }()
+		default:
+			if htmlBlockElements[node.Data] {
+				w.paragraph()
+				defer w.paragraph()
+			}
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		w.render(child)
+	}
+}
+
+// String returns the text, without the spaces at the ends.
+func (w *htmlTextWriter) String() string {
+	return strings.TrimSpace(w.Builder.String())
+}
+
+// lineStart returns true at the start of a line.
+func (w *htmlTextWriter) lineStart() bool {
+	text := w.Builder.String()
+	return text == "" || strings.HasSuffix(text, "\n")
+}
+
+// line starts a new line.
+func (w *htmlTextWriter) line() {
+	w.space = false
+	if !w.lineStart() {
+		w.WriteString("\n")
+	}
+}
+
+// paragraph starts a new paragraph (a blank line).
+func (w *htmlTextWriter) paragraph() {
+	text := w.Builder.String()
+	if text != "" && !strings.HasSuffix(text, "\n\n") {
+		w.line()
+		w.WriteString("\n")
+	}
+}
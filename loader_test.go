@@ -0,0 +1,131 @@
+package robby
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// writeTestFiles writes the files (path: content) in the directory.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadHTMLFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"avengers.html": `<html><head><title>The  Avengers</title><style>p { color: red }</style></head>
+<body><h1>Emma <em>Peel</em></h1><p>A brilliant
+  scientist, and a <b>martial arts</b> expert.</p><script>alert("no")</script>
+<ul><li>Leather catsuits</li><li>Lotus Elan</li></ul><pre>  keep
+    indentation</pre></body></html>`})
+
+	documents, err := LoadHTMLFile(filepath.Join(dir, "avengers.html"))
+	if err != nil || len(documents) != 1 {
+		t.Fatalf("unexpected documents: %v, %v", documents, err)
+	}
+	expected := "# Emma Peel\n\nA brilliant scientist, and a martial arts expert.\n\n- Leather catsuits\n- Lotus Elan\n\n  keep\n    indentation"
+	if documents[0].Text != expected {
+		t.Fatalf("unexpected text:\n%q\nexpected:\n%q", documents[0].Text, expected)
+	}
+	if documents[0].Metadata["title"] != "The Avengers" || documents[0].Metadata["type"] != "html" {
+		t.Fatalf("unexpected metadata: %v", documents[0].Metadata)
+	}
+}
+
+func TestJSONLoaders(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"agents.json":  `[{"text": "Emma Peel", "season": 4, "tags": ["spy"]}, {"text": "John Steed", "season": 1}]`,
+		"agent.json":   `{"name": "Mother", "text": "The superior"}`,
+		"agents.jsonl": "{\"text\": \"Tara King\", \"actress\": \"Linda Thorson\"}\n\n{\"content\": \"no text\"}\n",
+	})
+
+	documents, err := JSONLoader("text")(filepath.Join(dir, "agents.json"))
+	if err != nil || len(documents) != 2 {
+		t.Fatalf("unexpected documents: %v, %v", documents, err)
+	}
+	if documents[1].Text != "John Steed" || documents[1].Metadata["season"] != 1.0 || documents[1].Metadata["index"] != 1 {
+		t.Fatalf("unexpected document: %+v", documents[1])
+	}
+	if _, ok := documents[0].Metadata["tags"]; ok {
+		t.Fatal("expected only the scalar fields in the metadata")
+	}
+
+	documents, err = JSONLoader("")(filepath.Join(dir, "agent.json"))
+	if err != nil || len(documents) != 1 || documents[0].Text != `{"name":"Mother","text":"The superior"}` {
+		t.Fatalf("unexpected documents: %v, %v", documents, err)
+	}
+
+	_, err = JSONLLoader("text")(filepath.Join(dir, "agents.jsonl"))
+	if err == nil || !strings.Contains(err.Error(), `agents.jsonl:3: no text field "text"`) {
+		t.Fatalf("expected an error on the line 3, got %v", err)
+	}
+	documents, err = JSONLLoader("")(filepath.Join(dir, "agents.jsonl"))
+	if err != nil || len(documents) != 2 || documents[1].Metadata["line"] != 3 {
+		t.Fatalf("unexpected documents: %v, %v", documents, err)
+	}
+}
+
+func TestLoadDirectoryAndWithRAGMemoryChunks(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"avengers.md":        "# The Avengers\n\n## Emma Peel\n\nEmma Peel is a scientist.\n\n## John Steed\n\nJohn Steed wears a bowler hat.",
+		"notes/mother.txt":   "Mother is the superior.",
+		"notes/steed.go":     "package steed\n\nfunc Steed() string {\n\treturn \"John Steed\"\n}\n",
+		"notes/image.png":    "not loaded",
+		"package.json":       `{"name": "avengers", "version": "1.0.0"}`,
+		"sessions/bob.jsonl": `{"role": "user", "content": "Hi"}`,
+		".hidden/secret.md":  "# Secret",
+	})
+
+	documents, err := LoadDirectory(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := []string{}
+	for _, document := range documents {
+		sources = append(sources, document.Metadata["source"].(string))
+	}
+	expected := []string{filepath.Join(dir, "avengers.md"), filepath.Join(dir, "notes/mother.txt"), filepath.Join(dir, "notes/steed.go")}
+	if !slices.Equal(sources, expected) {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+	if documents[0].Metadata["title"] != "The Avengers" || documents[2].Metadata["language"] != "go" {
+		t.Fatalf("unexpected metadata: %v, %v", documents[0].Metadata, documents[2].Metadata)
+	}
+
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	bob, err := NewAgent(
+		fake.Option(),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithRAGMemoryChunks(ChunkDocuments(documents, nil)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := bob.Store.GetAll()
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	similarities, err := bob.Store.SearchTopNSimilarities(VectorRecord{Embedding: fakeEmbedding("Steed")}, 0.9, 5, Contains("headings", "John Steed"))
+	if err != nil || len(similarities) != 1 || similarities[0].Prompt != "## John Steed\n\nJohn Steed wears a bowler hat." {
+		t.Fatalf("unexpected similarities: %+v, %v", similarities, err)
+	}
+	if similarities[0].Metadata["source"] != expected[0] || similarities[0].Metadata["chunk"] != 1 {
+		t.Fatalf("unexpected metadata: %v", similarities[0].Metadata)
+	}
+}
@@ -0,0 +1,285 @@
+package robby
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// DefaultMCPShutdownTimeout is the time given to an MCP server process to stop after its stdin is closed,
+// before it is killed (see Close and WithMCPShutdownTimeout).
+const DefaultMCPShutdownTimeout = 5 * time.Second
+
+// mcpPingTimeout is the maximum time to wait for the answer of an MCP server to a ping.
+const mcpPingTimeout = 5 * time.Second
+
+// mcpStderrTailSize is the number of bytes of the stderr of an MCP server process kept to explain its unexpected exit.
+const mcpStderrTailSize = 4096
+
+// WithMCPShutdownTimeout sets the time given to the MCP server processes to stop gracefully when the Agent is closed
+// (default: DefaultMCPShutdownTimeout). The processes still running after this timeout are killed.
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPShutdownTimeout(timeout time.Duration) AgentOption {
+	return func(agent *Agent) {
+		agent.mcpShutdownTimeout = timeout
+	}
+}
+
+// WithMCPStderr sets the writer that receives the stderr of the MCP server processes started with WithMCPClient
+// or WithNamedMCPClient (e.g. os.Stderr or a log file). Every line is prefixed with the name of the server ("[calc] ...").
+// It must be used before the MCP client options. By default, the stderr is not written anywhere,
+// but its last lines are kept to explain an unexpected exit of the server.
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPStderr(writer io.Writer) AgentOption {
+	return func(agent *Agent) {
+		agent.mcpStderr = writer
+	}
+}
+
+// mcpConnection is a connection to an MCP server: the MCP client, its transport and the server process (if any).
+type mcpConnection struct {
+	name      string
+	client    *mcp_golang.Client
+	transport transport.Transport
+
+	// the process of the MCP server (STDIO transport only)
+	cmd    *exec.Cmd
+	stdin  io.Closer
+	stderr *stderrWriter
+	exited chan struct{} // closed when the process has exited
+
+	lost     chan struct{} // closed when the connection is lost (the process exited, the server closed the connection)
+	lostOnce sync.Once
+	lostErr  error // set before lost is closed
+
+	closing atomic.Bool // close has been called: the end of the connection is expected
+}
+
+// Close releases the resources of the Agent: it ends the session of every MCP server,
+// closes the stdin of the MCP server processes and waits for them to stop
+// (they are killed after the shutdown timeout, see WithMCPShutdownTimeout).
+// The Agent cannot use MCP servers after Close. Close can be called several times.
+// It returns the errors of the servers that could not be stopped gracefully (a nil Agent is ignored).
+func (agent *Agent) Close() error {
+	if agent == nil {
+		return nil
+	}
+	timeout := agent.shutdownTimeout()
+
+	// The servers are stopped concurrently, so closing an Agent takes at most the shutdown timeout
+	errs := make([]error, len(agent.mcpServers))
+	var wg sync.WaitGroup
+	for i, server := range agent.mcpServers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = server.close(timeout)
+		}()
+	}
+	wg.Wait()
+
+	agent.mcpServers = nil
+	agent.mcpToolRoutes = nil
+	return errors.Join(errs...)
+}
+
+// shutdownTimeout returns the time given to the MCP server processes to stop (see WithMCPShutdownTimeout).
+func (agent *Agent) shutdownTimeout() time.Duration {
+	if agent.mcpShutdownTimeout <= 0 {
+		return DefaultMCPShutdownTimeout
+	}
+	return agent.mcpShutdownTimeout
+}
+
+// Ping checks that every MCP server attached to the Agent is alive and answers to a ping.
+// It returns nil if all the servers are healthy, or the errors of the unhealthy servers
+// (e.g. "MCP server calc exited unexpectedly: exit status 1: <end of stderr>").
+// Ping does not reconnect the servers (see WithMCPReconnect).
+func (agent *Agent) Ping() error {
+	if len(agent.mcpServers) == 0 {
+		return errors.New("no MCP client")
+	}
+
+	errs := []error{}
+	for _, server := range agent.mcpServers {
+		if err := server.ping(agent.context()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ping checks the connection to the server, then sends a ping to the server.
+func (server *mcpServer) ping(ctx context.Context) error {
+	conn, err := server.connection()
+	if err != nil {
+		return err
+	}
+	if err := conn.err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mcpPingTimeout)
+	defer cancel()
+	if err := conn.client.Ping(ctx); err != nil {
+		// The connection may have been lost during the ping
+		if lostErr := conn.err(); lostErr != nil {
+			return lostErr
+		}
+		return fmt.Errorf("MCP server %s: ping failed: %v", server.name, err)
+	}
+	return nil
+}
+
+// close closes the current connection to the server. The server cannot be used anymore.
+func (server *mcpServer) close(timeout time.Duration) error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !server.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return server.conn.close(timeout)
+}
+
+// err returns an error if the connection is lost (the process exited, or the server closed the connection).
+func (conn *mcpConnection) err() error {
+	select {
+	case <-conn.lost:
+		return conn.lostErr
+	default:
+		return nil
+	}
+}
+
+// markLost records that the connection is lost with the given error (only the first error is kept).
+// The end of a connection being closed is not an error.
+func (conn *mcpConnection) markLost(err error) {
+	if conn.closing.Load() {
+		err = fmt.Errorf("MCP server %s is closed", conn.name)
+	}
+	conn.lostOnce.Do(func() {
+		conn.lostErr = err
+		close(conn.lost)
+	})
+}
+
+// watch waits for the end of the server process.
+// If the process exits while the connection is not being closed, the exit error (with the end of stderr) is recorded
+// and the transport is closed, so the pending requests fail instead of waiting for their timeout.
+func (conn *mcpConnection) watch() {
+	conn.exited = make(chan struct{})
+	go func() {
+		waitErr := conn.cmd.Wait()
+		conn.stderr.flush()
+		if waitErr == nil {
+			waitErr = errors.New("exit status 0")
+		}
+		message := fmt.Sprintf("MCP server %s exited unexpectedly: %v", conn.name, waitErr)
+		if tail := conn.stderr.String(); tail != "" {
+			message += ": " + tail
+		}
+		conn.markLost(errors.New(message))
+		close(conn.exited)
+		if !conn.closing.Load() {
+			_ = conn.transport.Close()
+		}
+	}()
+}
+
+// close ends the MCP session and stops the server process (if any):
+// the stdin of the process is closed, then the process is killed if it is still running after the timeout.
+func (conn *mcpConnection) close(timeout time.Duration) error {
+	if !conn.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer conn.markLost(nil)
+
+	err := conn.transport.Close()
+	if conn.cmd == nil {
+		return err
+	}
+	if conn.stdin != nil {
+		_ = conn.stdin.Close()
+	}
+
+	select {
+	case <-conn.exited:
+		return nil
+	case <-time.After(timeout):
+		_ = conn.cmd.Process.Kill()
+		<-conn.exited
+		return fmt.Errorf("MCP server %s did not stop within %v: killed", conn.name, timeout)
+	}
+}
+
+// stderrWriter keeps the end of the stderr of an MCP server process,
+// and copies every line (prefixed with the server name) to the sink, if any.
+type stderrWriter struct {
+	mu      sync.Mutex
+	prefix  string
+	sink    io.Writer
+	tail    []byte
+	partial []byte // the current line, not yet written to the sink
+}
+
+// newStderrWriter creates the stderr writer of the MCP server with the given name.
+func newStderrWriter(name string, sink io.Writer) *stderrWriter {
+	return &stderrWriter{prefix: "[" + name + "] ", sink: sink}
+}
+
+// Write implements io.Writer.
+func (w *stderrWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.tail = append(w.tail, data...)
+	if len(w.tail) > mcpStderrTailSize {
+		w.tail = w.tail[len(w.tail)-mcpStderrTailSize:]
+	}
+
+	if w.sink != nil {
+		w.partial = append(w.partial, data...)
+		for {
+			end := bytes.IndexByte(w.partial, '\n')
+			if end < 0 {
+				break
+			}
+			// The errors of the sink must not break the MCP server process
+			_, _ = io.WriteString(w.sink, w.prefix+string(w.partial[:end+1]))
+			w.partial = w.partial[end+1:]
+		}
+	}
+	return len(data), nil
+}
+
+// flush writes the last line of stderr to the sink if it does not end with a new line.
+func (w *stderrWriter) flush() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sink != nil && len(w.partial) > 0 {
+		_, _ = io.WriteString(w.sink, w.prefix+string(w.partial)+"\n")
+		w.partial = nil
+	}
+}
+
+// String returns the end of stderr, trimmed.
+func (w *stderrWriter) String() string {
+	if w == nil {
+		return ""
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(string(w.tail))
+}
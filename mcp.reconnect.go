@@ -0,0 +1,224 @@
+package robby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/metoro-io/mcp-golang/transport"
+	"github.com/openai/openai-go"
+)
+
+// The notifications sent by the MCP servers when their lists of tools, resources or prompts change.
+const (
+	mcpToolsListChanged     = "notifications/tools/list_changed"
+	mcpResourcesListChanged = "notifications/resources/list_changed"
+	mcpPromptsListChanged   = "notifications/prompts/list_changed"
+)
+
+// MCPReconnectPolicy defines how an MCP server is reconnected when its connection is lost
+// (the process started by WithMCPClient exited, the SSE stream or the HTTP session was closed by the server).
+// The zero values are replaced by the values of DefaultMCPReconnectPolicy.
+type MCPReconnectPolicy struct {
+	MaxAttempts    int           // Maximum number of reconnection attempts
+	InitialBackoff time.Duration // Delay before the second attempt, doubled after every failed attempt
+	MaxBackoff     time.Duration // Maximum delay between two attempts
+}
+
+// DefaultMCPReconnectPolicy is the reconnect policy used by WithMCPReconnect for the fields that are not set.
+var DefaultMCPReconnectPolicy = MCPReconnectPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// WithMCPReconnect enables the automatic reconnection of the MCP servers.
+// When the connection to a server is lost, the next call to the server (ExecuteMCPToolCalls, RunToolLoop, ReadResource, GetPrompt...)
+// re-spawns the server process (or opens a new HTTP session), initializes a new MCP session and retries the call.
+// The reconnection attempts are spaced with an exponential backoff (see MCPReconnectPolicy).
+// After a reconnection, the lists of tools, resources and prompts are refreshed (see RefreshMCP).
+// Without this option, the calls to a server whose connection is lost fail.
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPReconnect(policy MCPReconnectPolicy) AgentOption {
+	return func(agent *Agent) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = DefaultMCPReconnectPolicy.MaxAttempts
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = DefaultMCPReconnectPolicy.InitialBackoff
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = DefaultMCPReconnectPolicy.MaxBackoff
+		}
+		agent.mcpReconnect = &policy
+	}
+}
+
+// reconnect replaces the lost connection to the server by a new one, following the reconnect policy of the Agent.
+// If the connection has already been replaced (by a concurrent call), the current connection is returned.
+func (server *mcpServer) reconnect(ctx context.Context, agent *Agent, lost *mcpConnection) (*mcpConnection, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.closed.Load() {
+		return nil, fmt.Errorf("MCP server %s is closed", server.name)
+	}
+	if server.conn != lost {
+		return server.conn, nil
+	}
+
+	// Release the lost connection (e.g. kill a process that does not answer anymore)
+	_ = lost.close(agent.shutdownTimeout())
+
+	policy := agent.mcpReconnect
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, policy.MaxBackoff)
+		}
+
+		var conn *mcpConnection
+		if conn, err = server.open(agent); err == nil {
+			server.conn = conn
+			// The server may have changed its lists while it was unavailable
+			agent.mcpLists.toolsChanged.Store(true)
+			agent.mcpLists.resourcesChanged.Store(true)
+			agent.mcpLists.promptsChanged.Store(true)
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("reconnection to MCP server %s failed after %d attempts: %v", server.name, policy.MaxAttempts, err)
+}
+
+// mcpListsState records how the lists of MCP tools, resources and prompts were loaded (the filters given to
+// WithMCPTools, WithMCPResources and WithMCPPrompts), and which lists must be refreshed.
+type mcpListsState struct {
+	toolsFilters    [][]string
+	resourcesFilter []string
+	resourcesLoaded bool
+	promptsFilter   []string
+	promptsLoaded   bool
+
+	toolsChanged     atomic.Bool
+	resourcesChanged atomic.Bool
+	promptsChanged   atomic.Bool
+}
+
+// handleMCPNotification records the changes of the lists announced by the MCP servers.
+// The lists are refreshed by the next call of the Agent (see RefreshMCP).
+func (agent *Agent) handleMCPNotification(method string) {
+	switch method {
+	case mcpToolsListChanged:
+		agent.mcpLists.toolsChanged.Store(true)
+	case mcpResourcesListChanged:
+		agent.mcpLists.resourcesChanged.Store(true)
+	case mcpPromptsListChanged:
+		agent.mcpLists.promptsChanged.Store(true)
+	}
+}
+
+// RefreshMCP fetches again the tools, resources and prompts of the MCP servers
+// and updates Tools, Resources and Prompts in place, with the filters given to WithMCPTools, WithMCPResources and WithMCPPrompts
+// (the lists that were not loaded with these options are not changed, and the local tools are kept).
+// It is not needed to call it when the servers announce their changes (tools/list_changed, resources/list_changed
+// and prompts/list_changed notifications): the lists are then refreshed by the next completion or MCP call of the Agent.
+// If a list cannot be fetched, it is not changed and the error is returned.
+func (agent *Agent) RefreshMCP() error {
+	agent.mcpLists.toolsChanged.Store(true)
+	agent.mcpLists.resourcesChanged.Store(true)
+	agent.mcpLists.promptsChanged.Store(true)
+	return agent.refreshMCPIfChanged()
+}
+
+// refreshMCPIfChanged refreshes the lists of tools, resources and prompts that changed on the MCP servers.
+// A list that cannot be fetched is refreshed again by the next call.
+func (agent *Agent) refreshMCPIfChanged() error {
+	if len(agent.mcpServers) == 0 {
+		// no MCP server, or the Agent is closed
+		return nil
+	}
+	errs := []error{}
+	if agent.mcpLists.toolsChanged.Swap(false) {
+		if err := agent.refreshMCPTools(); err != nil {
+			agent.mcpLists.toolsChanged.Store(true)
+			errs = append(errs, err)
+		}
+	}
+	if agent.mcpLists.resourcesChanged.Swap(false) && agent.mcpLists.resourcesLoaded {
+		resources, err := agent.loadMCPResources(agent.mcpLists.resourcesFilter)
+		if err != nil {
+			agent.mcpLists.resourcesChanged.Store(true)
+			errs = append(errs, err)
+		} else {
+			agent.Resources = resources
+		}
+	}
+	if agent.mcpLists.promptsChanged.Swap(false) && agent.mcpLists.promptsLoaded {
+		prompts, err := agent.loadMCPPrompts(agent.mcpLists.promptsFilter)
+		if err != nil {
+			agent.mcpLists.promptsChanged.Store(true)
+			errs = append(errs, err)
+		} else {
+			agent.Prompts = prompts
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refreshMCPTools replaces the MCP tools of the Agent by the current tools of the MCP servers.
+// The local tools are kept (first), and the Agent's tools are not changed if the tools cannot be fetched.
+func (agent *Agent) refreshMCPTools() error {
+	if len(agent.mcpLists.toolsFilters) == 0 {
+		return nil
+	}
+	tools, routes := agent.Tools, agent.mcpToolRoutes
+
+	localTools := []openai.ChatCompletionToolParam{}
+	for _, tool := range tools {
+		if _, isMCPTool := routes[tool.Function.Name]; !isMCPTool {
+			localTools = append(localTools, tool)
+		}
+	}
+	agent.Tools, agent.mcpToolRoutes = localTools, nil
+	for _, filter := range agent.mcpLists.toolsFilters {
+		if err := agent.loadMCPTools(filter); err != nil {
+			agent.Tools, agent.mcpToolRoutes = tools, routes
+			return err
+		}
+	}
+	return nil
+}
+
+// mcpTransport wraps the transport of an MCP connection to detect the end of the connection
+// and the notifications of the server about its lists of tools, resources and prompts.
+type mcpTransport struct {
+	transport.Transport
+	onClose        func()
+	onNotification func(method string)
+}
+
+// SetCloseHandler implements transport.Transport.
+func (t *mcpTransport) SetCloseHandler(handler func()) {
+	t.Transport.SetCloseHandler(func() {
+		// The connection is marked as lost before the pending requests fail
+		t.onClose()
+		handler()
+	})
+}
+
+// SetMessageHandler implements transport.Transport.
+func (t *mcpTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.Transport.SetMessageHandler(func(ctx context.Context, message *transport.BaseJsonRpcMessage) {
+		if message.Type == transport.BaseMessageTypeJSONRPCNotificationType && message.JsonRpcNotification != nil {
+			t.onNotification(message.JsonRpcNotification.Method)
+		}
+		handler(ctx, message)
+	})
+}
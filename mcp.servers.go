@@ -0,0 +1,227 @@
+package robby
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// DefaultMCPServerName is the name of the MCP server attached with WithMCPClient, WithMCPHTTPClient or WithMCPSSEClient.
+const DefaultMCPServerName = "default"
+
+// MCPToolNameSeparator separates the server name and the tool name of the prefixed MCP tools
+// (e.g. "calc__add", see WithMCPToolsPrefix).
+const MCPToolNameSeparator = "__"
+
+// mcpServer is an MCP server attached to the Agent.
+// The connection is replaced when the server is reconnected (see WithMCPReconnect).
+type mcpServer struct {
+	name    string
+	connect func() (*mcpConnection, error) // creates a new (not initialized) connection to the server
+
+	mu     sync.Mutex
+	conn   *mcpConnection
+	closed atomic.Bool
+}
+
+// mcpToolRoute gives the MCP server that advertised a tool, and the name of the tool on this server.
+type mcpToolRoute struct {
+	server *mcpServer
+	tool   string
+}
+
+// checkMCPServerName returns an error if the name is invalid or already used by another MCP server of the Agent.
+func (agent *Agent) checkMCPServerName(name string) error {
+	if err := validateMCPServerName(name); err != nil {
+		return err
+	}
+	if agent.mcpServer(name) != nil {
+		return fmt.Errorf("MCP server %q already attached: use a WithNamedMCP*Client option with another name", name)
+	}
+	return nil
+}
+
+// attachMCPServer connects to an MCP server with the connect function, initializes the MCP session
+// and attaches the server to the Agent with the given name.
+func (agent *Agent) attachMCPServer(name string, connect func() (*mcpConnection, error)) error {
+	if err := agent.checkMCPServerName(name); err != nil {
+		return err
+	}
+	server := &mcpServer{name: name, connect: connect}
+	conn, err := server.open(agent)
+	if err != nil {
+		return err
+	}
+	server.conn = conn
+	agent.mcpServers = append(agent.mcpServers, server)
+	return nil
+}
+
+// open creates a new connection to the server and initializes the MCP session.
+// If the connection has a process, its exit is watched (see Close and Ping), and it is stopped if the initialization fails.
+// The notifications of the server about its lists of tools, resources and prompts are sent to the Agent (see RefreshMCP).
+func (server *mcpServer) open(agent *Agent) (*mcpConnection, error) {
+	conn, err := server.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn.name = server.name
+	conn.lost = make(chan struct{})
+	conn.transport = &mcpTransport{
+		Transport: conn.transport,
+		onClose: func() {
+			conn.markLost(fmt.Errorf("MCP server %s closed the connection", server.name))
+		},
+		onNotification: agent.handleMCPNotification,
+	}
+	if conn.cmd != nil {
+		conn.watch()
+	}
+
+	conn.client = mcp_golang.NewClient(conn.transport)
+
+	if _, err := conn.client.Initialize(agent.context()); err != nil {
+		if lostErr := conn.err(); lostErr != nil {
+			err = lostErr
+		}
+		_ = conn.close(agent.shutdownTimeout())
+		return nil, fmt.Errorf("failed to initialize client of MCP server %s: %v", server.name, err)
+	}
+	return conn, nil
+}
+
+// connection returns the current connection to the server, or an error if the server is closed.
+func (server *mcpServer) connection() (*mcpConnection, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.closed.Load() {
+		return nil, fmt.Errorf("MCP server %s is closed", server.name)
+	}
+	return server.conn, nil
+}
+
+// call calls fn with the client of the current connection to the server.
+// If the connection is lost (the process exited, the stream or the session was closed by the server),
+// the server is reconnected and fn is called again, if a reconnect policy is set (see WithMCPReconnect).
+func (server *mcpServer) call(ctx context.Context, agent *Agent, fn func(client *mcp_golang.Client) error) error {
+	conn, err := server.connection()
+	if err != nil {
+		return err
+	}
+	if err = conn.err(); err == nil {
+		err = fn(conn.client)
+		if err == nil || conn.err() == nil {
+			// a successful call, or an error returned by the server
+			return err
+		}
+		// the connection was lost during the call
+		err = conn.err()
+	}
+	if agent.mcpReconnect == nil {
+		return err
+	}
+	conn, reconnectErr := server.reconnect(ctx, agent, conn)
+	if reconnectErr != nil {
+		return fmt.Errorf("%v (%v)", err, reconnectErr)
+	}
+	return fn(conn.client)
+}
+
+// validateMCPServerName checks that the server name can be used as a tool name prefix
+// (the OpenAI function names only allow letters, digits, "_" and "-").
+func validateMCPServerName(name string) error {
+	if name == "" {
+		return fmt.Errorf("the MCP server name cannot be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return fmt.Errorf("invalid MCP server name %q: only letters, digits, '_' and '-' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// mcpServer returns the attached MCP server with the given name, or nil.
+func (agent *Agent) mcpServer(name string) *mcpServer {
+	for _, server := range agent.mcpServers {
+		if server.name == name {
+			return server
+		}
+	}
+	return nil
+}
+
+// MCPServers returns the names of the MCP servers attached to the Agent, in the order they were attached.
+func (agent *Agent) MCPServers() []string {
+	names := make([]string, len(agent.mcpServers))
+	for i, server := range agent.mcpServers {
+		names[i] = server.name
+	}
+	return names
+}
+
+// mcpToolName returns the name of an MCP tool as seen by the model:
+// "<server>__<tool>" if the tools are prefixed (see WithMCPToolsPrefix), the tool name otherwise.
+func (agent *Agent) mcpToolName(server *mcpServer, tool string) string {
+	if agent.mcpToolsPrefix {
+		return server.name + MCPToolNameSeparator + tool
+	}
+	return tool
+}
+
+// routeMCPTool returns the MCP server to call for a tool name given by the model, and the name of the tool on this server.
+// The tools added with WithMCPTools are routed to the server that advertised them;
+// any other tool name is sent as is to the MCP server if there is only one.
+func (agent *Agent) routeMCPTool(name string) (mcpToolRoute, error) {
+	if route, ok := agent.mcpToolRoutes[name]; ok {
+		return route, nil
+	}
+	switch len(agent.mcpServers) {
+	case 0:
+		return mcpToolRoute{}, fmt.Errorf("tool %s not implemented: no MCP client", name)
+	case 1:
+		return mcpToolRoute{server: agent.mcpServers[0], tool: name}, nil
+	}
+	// A prefixed name of a tool not added with WithMCPTools
+	if serverName, tool, found := strings.Cut(name, MCPToolNameSeparator); found {
+		if server := agent.mcpServer(serverName); server != nil {
+			return mcpToolRoute{server: server, tool: tool}, nil
+		}
+	}
+	return mcpToolRoute{}, fmt.Errorf("tool %s not found on the MCP servers %v", name, agent.MCPServers())
+}
+
+// serverOf returns the MCP server named serverName, or the first attached MCP server if serverName is empty.
+func (agent *Agent) serverOf(serverName string) (*mcpServer, error) {
+	if serverName == "" {
+		if len(agent.mcpServers) == 0 {
+			return nil, fmt.Errorf("no MCP client")
+		}
+		return agent.mcpServers[0], nil
+	}
+	server := agent.mcpServer(serverName)
+	if server == nil {
+		return nil, fmt.Errorf("unknown MCP server %s", serverName)
+	}
+	return server, nil
+}
+
+// context returns the context of the Agent (context.Background if the Agent has no DMR client).
+func (agent *Agent) context() context.Context {
+	if agent.ctx == nil {
+		return context.Background()
+	}
+	return agent.ctx
+}
+
+// stringValue returns the value of an optional string field of the MCP responses ("" if nil).
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
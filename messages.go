@@ -0,0 +1,83 @@
+package robby
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/openai/openai-go"
+)
+
+// AddUserMessage appends a user message to the conversation history (the Agent's messages).
+func (agent *Agent) AddUserMessage(content string) {
+	agent.Params.Messages = append(agent.Params.Messages, openai.UserMessage(content))
+}
+
+// AddAssistantMessage appends an assistant message to the conversation history (the Agent's messages).
+func (agent *Agent) AddAssistantMessage(content string) {
+	agent.Params.Messages = append(agent.Params.Messages, openai.AssistantMessage(content))
+}
+
+// AddSystemMessage appends a system message to the conversation history (the Agent's messages).
+func (agent *Agent) AddSystemMessage(content string) {
+	agent.Params.Messages = append(agent.Params.Messages, openai.SystemMessage(content))
+}
+
+// ResetMessages removes all the messages of the conversation history (the system messages included).
+func (agent *Agent) ResetMessages() {
+	agent.Params.Messages = []openai.ChatCompletionMessageParamUnion{}
+}
+
+// ResetConversation removes the messages of the conversation history, except the system messages at its beginning
+// (the instructions of the Agent), e.g. to start a new conversation with the same Agent.
+func (agent *Agent) ResetConversation() {
+	count := 0
+	for count < len(agent.Params.Messages) && agent.Params.Messages[count].OfSystem != nil {
+		count++
+	}
+	// Clipped: the next messages do not overwrite the removed ones (in a copy of the history)
+	agent.Params.Messages = slices.Clip(agent.Params.Messages[:count])
+}
+
+// LastMessage returns the last message of the conversation history, or an error if there are no messages.
+func (agent *Agent) LastMessage() (openai.ChatCompletionMessageParamUnion, error) {
+	if len(agent.Params.Messages) == 0 {
+		return openai.ChatCompletionMessageParamUnion{}, errors.New("no messages found")
+	}
+	return agent.Params.Messages[len(agent.Params.Messages)-1], nil
+}
+
+// Messages returns a copy of the conversation history (the Agent's messages).
+func (agent *Agent) Messages() []openai.ChatCompletionMessageParamUnion {
+	return slices.Clone(agent.Params.Messages)
+}
+
+// Ask appends the question as a user message, runs ChatCompletion and appends the answer as an assistant message.
+// It returns the answer, or an error (the question is then removed from the conversation history, so Ask can be called again).
+func (agent *Agent) Ask(question string) (string, error) {
+	agent.AddUserMessage(question)
+
+	answer, err := agent.ChatCompletion()
+	if err != nil {
+		// the history policy may have removed older messages, but the question is still the last message
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return "", err
+	}
+	agent.AddAssistantMessage(answer)
+	return answer, agent.persistSession()
+}
+
+// AskStream appends the question as a user message, runs ChatCompletionStream with the callback
+// and appends the answer as an assistant message.
+// It returns the answer, or an error (the question is then removed from the conversation history, so AskStream can be called again).
+func (agent *Agent) AskStream(question string, callBack func(self *Agent, content string, err error) error) (string, error) {
+	agent.AddUserMessage(question)
+
+	answer, err := agent.ChatCompletionStream(callBack)
+	if err != nil {
+		// the history policy may have removed older messages, but the question is still the last message
+		agent.Params.Messages = agent.Params.Messages[:len(agent.Params.Messages)-1]
+		return answer, err
+	}
+	agent.AddAssistantMessage(answer)
+	return answer, agent.persistSession()
+}
@@ -0,0 +1,118 @@
+package robby
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestAsk(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": []string{"James T. Kirk is a captain", "Spock"}[index]}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.AddSystemMessage("You are a Star Trek expert")
+
+	if answer, err := bob.Ask("Who is James Kirk?"); err != nil || answer != "James T. Kirk is a captain" {
+		t.Fatalf("unexpected answer: %q, %v", answer, err)
+	}
+	if answer, err := bob.Ask("Who is his best friend?"); err != nil || answer != "Spock" {
+		t.Fatalf("unexpected answer: %q, %v", answer, err)
+	}
+
+	// The second request contains the whole conversation
+	messages := fake.Requests()[1]["messages"].([]any)
+	if len(messages) != 4 || messages[2].(map[string]any)["content"] != "James T. Kirk is a captain" {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+	if len(bob.Messages()) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(bob.Messages()))
+	}
+	last, err := bob.LastMessage()
+	if err != nil || last.OfAssistant == nil || last.OfAssistant.Content.OfString.Value != "Spock" {
+		t.Fatalf("unexpected last message: %+v, %v", last, err)
+	}
+
+	bob.ResetMessages()
+	if _, err := bob.LastMessage(); err == nil {
+		t.Fatal("expected an error when there are no messages")
+	}
+}
+
+func TestAskStream(t *testing.T) {
+	fake := newFakeStreamingDMR(t, func(index int, request map[string]any) []map[string]any {
+		return []map[string]any{
+			{"delta": map[string]any{"role": "assistant", "content": "Hello "}},
+			{"delta": map[string]any{"content": "Bob"}},
+			{"delta": map[string]any{}, "finish_reason": "stop"},
+		}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := ""
+	answer, err := bob.AskStream("Hello", func(self *Agent, content string, err error) error {
+		streamed += content
+		return nil
+	})
+	if err != nil || answer != "Hello Bob" || streamed != answer {
+		t.Fatalf("unexpected answer: %q (streamed: %q), %v", answer, streamed, err)
+	}
+	if len(bob.Messages()) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(bob.Messages()))
+	}
+
+	// The question is removed from the history when the completion fails
+	stop := errors.New("stop")
+	if _, err := bob.AskStream("Again", func(self *Agent, content string, err error) error { return stop }); err != stop {
+		t.Fatalf("expected the callback error, got %v", err)
+	}
+	if len(bob.Messages()) != 2 {
+		t.Fatalf("the question must be removed after a failure, got %d messages", len(bob.Messages()))
+	}
+}
+
+func TestResetConversation(t *testing.T) {
+	bob, err := NewAgent(WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.AddSystemMessage("You are a Star Trek expert")
+	bob.AddSystemMessage("Answer in one sentence")
+	bob.AddUserMessage("Who is James Kirk?")
+	bob.AddAssistantMessage("James T. Kirk is a captain")
+	bob.AddSystemMessage("Be polite")
+
+	saved := bob.Messages()
+	bob.Params.Messages = saved
+	bob.ResetConversation()
+	messages := bob.Messages()
+	if len(messages) != 2 || messages[0].OfSystem == nil || messages[1].OfSystem == nil {
+		t.Fatalf("expected the 2 leading system messages, got %+v", messages)
+	}
+	// The removed messages are not overwritten by the next ones
+	bob.AddUserMessage("Who is Spock?")
+	if saved[2].OfUser == nil || saved[2].OfUser.Content.OfString.Value != "Who is James Kirk?" {
+		t.Fatalf("the saved conversation was changed: %+v", saved[2])
+	}
+
+	bob.ResetMessages()
+	bob.ResetConversation()
+	if len(bob.Messages()) != 0 {
+		t.Fatalf("expected no messages, got %d", len(bob.Messages()))
+	}
+}
@@ -2,6 +2,7 @@ package robby
 
 import (
 	"context"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -39,3 +40,40 @@ func WithTools(tools []openai.ChatCompletionToolParam) AgentOption {
 		agent.Tools = tools
 	}
 }
+
+// WithToolRegistry adds the tools of the registry to the Agent's tools.
+// The registered implementations are used by ExecuteToolCalls and RunToolLoop
+// for the tool calls that are not found in the implementations given to these methods.
+func WithToolRegistry(registry *ToolRegistry) AgentOption {
+	return func(agent *Agent) {
+		agent.Tools = append(agent.Tools, registry.Tools()...)
+		agent.toolRegistry = registry
+	}
+}
+
+// WithMaxToolLoopIterations sets the maximum number of model round trips of RunToolLoop.
+// If not set (or set to zero), DefaultMaxToolLoopIterations is used.
+func WithMaxToolLoopIterations(max int) AgentOption {
+	return func(agent *Agent) {
+		agent.maxToolLoopIterations = max
+	}
+}
+
+// WithToolCallsConcurrency runs the tool calls of ExecuteToolCalls, ExecuteMCPToolCalls and RunToolLoop concurrently.
+// workers is the maximum number of tool calls running at the same time,
+// and timeout (if greater than zero) is the maximum duration of each tool call.
+// The tool results are always appended to the messages in the original tool calls order.
+func WithToolCallsConcurrency(workers int, timeout time.Duration) AgentOption {
+	return func(agent *Agent) {
+		agent.toolCallsWorkers = workers
+		agent.toolCallTimeout = timeout
+	}
+}
+
+// WithToolErrorFormatter sets how the errors of the tool calls are sent back to the model
+// (see DefaultToolErrorFormatter and JSONToolErrorFormatter).
+func WithToolErrorFormatter(formatter ToolErrorFormatter) AgentOption {
+	return func(agent *Agent) {
+		agent.toolErrorFormatter = formatter
+	}
+}
@@ -2,8 +2,12 @@ package robby
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"slices"
+	"time"
 
 	mcp_golang "github.com/metoro-io/mcp-golang"
 	"github.com/metoro-io/mcp-golang/transport/stdio"
@@ -38,177 +42,316 @@ func WithSocatMCPToolkit() STDIOCommandOption {
 // WithMCPClient initializes the Agent with an MCP client using the provided command.
 // It runs the command to connect to the MCP server and sets up the client transport.
 // The command should be a valid command that can be executed in the environment where the agent runs.
+// The server is attached with the name DefaultMCPServerName (see WithNamedMCPClient to attach several servers).
 // It returns an AgentOption that can be used to configure the agent.
 func WithMCPClient(command STDIOCommandOption) AgentOption {
-	return func(agent *Agent) {
-
-		cmd := exec.Command(
-			command[0],
-			command[1:]...,
-		)
+	return WithNamedMCPClient(DefaultMCPServerName, command)
+}
 
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			agent.lastError = fmt.Errorf("failed to get stdin pipe: %v", err)
+// WithNamedMCPClient attaches an MCP server to the Agent with the given name, using the provided command (STDIO transport).
+// Several MCP servers can be attached to the same Agent (with different names):
+// WithMCPTools, WithMCPResources and WithMCPPrompts aggregate the tools, resources and prompts of all the servers,
+// and every tool call is sent to the server that advertised the tool.
+// The name must only contain letters, digits, "_" and "-" (it is used as tool name prefix, see WithMCPToolsPrefix).
+// It returns an AgentOption that can be used to configure the agent.
+func WithNamedMCPClient(name string, command STDIOCommandOption) AgentOption {
+	return func(agent *Agent) {
+		if len(command) == 0 {
+			agent.lastError = fmt.Errorf("no command to start the MCP server %s", name)
 			return
 		}
-
-		stdout, err := cmd.StdoutPipe()
+		// The process is started again if the server is reconnected (see WithMCPReconnect)
+		err := agent.attachMCPServer(name, func() (*mcpConnection, error) {
+			return startMCPServerProcess(name, command, agent.mcpStderr)
+		})
 		if err != nil {
-			agent.lastError = fmt.Errorf("failed to get stdout pipe: %v", err)
-			return
+			agent.lastError = err
 		}
+	}
+}
 
-		if err := cmd.Start(); err != nil {
-			agent.lastError = fmt.Errorf("failed to start server: %v", err)
-			return
-		}
+// startMCPServerProcess runs the command of an MCP server and returns a connection using its stdin and stdout.
+func startMCPServerProcess(name string, command STDIOCommandOption, stderrSink io.Writer) (*mcpConnection, error) {
+	cmd := exec.Command(
+		command[0],
+		command[1:]...,
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdin pipe: %v", err)
+	}
 
-		clientTransport := stdio.NewStdioServerTransportWithIO(stdout, stdin)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdout pipe: %v", err)
+	}
 
-		mcpClient := mcp_golang.NewClient(clientTransport)
+	// Keep the end of stderr to explain an unexpected exit (and copy it to the sink of WithMCPStderr)
+	stderr := newStderrWriter(name, stderrSink)
+	cmd.Stderr = stderr
+	// Do not wait forever for the stderr copy if a child process keeps it open
+	cmd.WaitDelay = time.Second
 
-		if _, err := mcpClient.Initialize(agent.ctx); err != nil {
-			agent.lastError = fmt.Errorf("failed to initialize client: %v", err)
-			return
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start server: %v", err)
+	}
+
+	return &mcpConnection{
+		transport: stdio.NewStdioServerTransportWithIO(stdout, stdin),
+		cmd:       cmd,
+		stdin:     stdin,
+		stderr:    stderr,
+	}, nil
+}
+
+// WithMCPHTTPClient initializes the Agent with an MCP client connected to an MCP server over HTTP
+// (Streamable HTTP transport: every message is posted to the URL, and the server answers with JSON or Server-Sent Events).
+// The headers are sent with every request (e.g. "Authorization").
+// The same options and methods as with WithMCPClient can be used (WithMCPTools, ExecuteMCPToolCalls, ReadResource...).
+// The server is attached with the name DefaultMCPServerName (see WithNamedMCPHTTPClient to attach several servers).
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPHTTPClient(url string, headers map[string]string) AgentOption {
+	return WithNamedMCPHTTPClient(DefaultMCPServerName, url, headers)
+}
+
+// WithNamedMCPHTTPClient attaches an MCP server to the Agent with the given name, using the Streamable HTTP transport
+// (see WithMCPHTTPClient and WithNamedMCPClient).
+// It returns an AgentOption that can be used to configure the agent.
+func WithNamedMCPHTTPClient(name string, url string, headers map[string]string) AgentOption {
+	return func(agent *Agent) {
+		err := agent.attachMCPServer(name, func() (*mcpConnection, error) {
+			return &mcpConnection{transport: newStreamableHTTPTransport(url, headers)}, nil
+		})
+		if err != nil {
+			agent.lastError = err
 		}
-		agent.mcpClient = mcpClient
-		agent.mcpCmd = cmd
 	}
 }
 
-// WithMCPTools fetches the tools from the MCP server and sets them in the agent.
+// WithMCPSSEClient initializes the Agent with an MCP client connected to an MCP server over HTTP with Server-Sent Events
+// (HTTP+SSE transport: the client listens to the SSE URL and posts its messages to the endpoint given by the server).
+// The headers are sent with every request (e.g. "Authorization").
+// The same options and methods as with WithMCPClient can be used (WithMCPTools, ExecuteMCPToolCalls, ReadResource...).
+// The server is attached with the name DefaultMCPServerName (see WithNamedMCPSSEClient to attach several servers).
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPSSEClient(url string, headers map[string]string) AgentOption {
+	return WithNamedMCPSSEClient(DefaultMCPServerName, url, headers)
+}
+
+// WithNamedMCPSSEClient attaches an MCP server to the Agent with the given name, using the HTTP+SSE transport
+// (see WithMCPSSEClient and WithNamedMCPClient).
+// It returns an AgentOption that can be used to configure the agent.
+func WithNamedMCPSSEClient(name string, url string, headers map[string]string) AgentOption {
+	return func(agent *Agent) {
+		err := agent.attachMCPServer(name, func() (*mcpConnection, error) {
+			return &mcpConnection{transport: newSSETransport(url, headers)}, nil
+		})
+		if err != nil {
+			agent.lastError = err
+		}
+	}
+}
+
+// checkMCPClient returns false (and sets the Agent's error) if the Agent has no MCP client.
+// It is used by the options that need a connected MCP client.
+func (agent *Agent) checkMCPClient() bool {
+	if len(agent.mcpServers) > 0 {
+		return true
+	}
+	if agent.lastError == nil {
+		agent.lastError = errors.New("no MCP client: use WithMCPClient, WithMCPHTTPClient or WithMCPSSEClient first")
+	}
+	return false
+}
+
+// WithMCPToolsPrefix prefixes the names of the MCP tools with the name of their server ("<server>__<tool>", e.g. "calc__add"),
+// to avoid collisions when several MCP servers advertise tools with the same name.
+// The tool calls of the model are routed to the right server and called with the original tool name.
+// It must be used before WithMCPTools.
+// It returns an AgentOption that can be used to configure the agent.
+func WithMCPToolsPrefix() AgentOption {
+	return func(agent *Agent) {
+		agent.mcpToolsPrefix = true
+	}
+}
+
+// WithMCPTools fetches the tools from the MCP servers and adds them to the agent's tools
+// (the tools set with WithTools or WithToolRegistry are kept, so local and MCP tools can be mixed).
 // It filters the tools based on the provided names and converts them to OpenAI format.
-// It requires the MCP server to be running and accessible at the specified address.
-// The tools are expected to be in the format defined by the MCP server.
+// A name can be the tool name or the prefixed name ("<server>__<tool>") to select the tool of a given server.
+// If the tools list is empty, all the tools of all the servers are used.
+// Every tool is routed to the server that advertised it (see ExecuteMCPToolCalls and RunToolLoop).
+// If two servers advertise the same tool name, it sets an error: use WithMCPToolsPrefix to avoid collisions.
+// It requires the MCP servers to be running and accessible.
 // It returns an AgentOption that can be used to configure the agent.
-// The tools are fetched using the MCP client and converted to OpenAI format.
 func WithMCPTools(tools []string) AgentOption {
 	return func(agent *Agent) {
+		if !agent.checkMCPClient() {
+			return
+		}
+		if err := agent.loadMCPTools(tools); err != nil {
+			agent.lastError = err
+			return
+		}
+		// Keep the filter to refresh the tools (see RefreshMCP)
+		agent.mcpLists.toolsFilters = append(agent.mcpLists.toolsFilters, tools)
+	}
+}
+
+// loadMCPTools fetches the tools of the MCP servers and adds the tools selected by the filter to the agent's tools
+// (see WithMCPTools).
+func (agent *Agent) loadMCPTools(tools []string) error {
+	if agent.mcpToolRoutes == nil {
+		agent.mcpToolRoutes = make(map[string]mcpToolRoute)
+	}
 
+	for _, server := range agent.mcpServers {
 		// Get the tools from the MCP client
-		mcpTools, err := agent.mcpClient.ListTools(agent.ctx, nil)
+		var mcpTools *mcp_golang.ToolsResponse
+		err := server.call(agent.context(), agent, func(client *mcp_golang.Client) (err error) {
+			mcpTools, err = client.ListTools(agent.context(), nil)
+			return err
+		})
 		if err != nil {
-			agent.lastError = err
-			return
+			return fmt.Errorf("failed to list the tools of MCP server %s: %v", server.name, err)
 		}
 
-		if len(tools) == 0 {
-			// If no tools are specified, use all available tools
-			// Convert the tools to OpenAI format
-			agent.Tools = convertToOpenAITools(mcpTools.Tools)
-		} else {
-			filteredTools := []mcp_golang.ToolRetType{}
-			for _, tool := range mcpTools.Tools {
-				for _, t := range tools {
-					if tool.Name == t {
-						filteredTools = append(filteredTools, tool)
-					}
+		for _, tool := range mcpTools.Tools {
+			name := agent.mcpToolName(server, tool.Name)
+			if len(tools) > 0 && !slices.Contains(tools, tool.Name) && !slices.Contains(tools, server.name+MCPToolNameSeparator+tool.Name) {
+				continue
+			}
+			if route, exists := agent.mcpToolRoutes[name]; exists {
+				if route.server == server {
+					// already added (WithMCPTools used several times)
+					continue
 				}
+				return fmt.Errorf("tool %s is advertised by the MCP servers %s and %s: use WithMCPToolsPrefix to avoid the collision", name, route.server.name, server.name)
 			}
-			// Convert the tools to OpenAI format
-			agent.Tools = convertToOpenAITools(filteredTools)
+			// Convert the tool to OpenAI format
+			openAITool := convertToOpenAITools([]mcp_golang.ToolRetType{tool})[0]
+			openAITool.Function.Name = name
+			agent.Tools = append(agent.Tools, openAITool)
+			agent.mcpToolRoutes[name] = mcpToolRoute{server: server, tool: tool.Name}
 		}
 	}
+	return nil
 }
 
-// WithMCPResources fetches the resources from the MCP server and sets them in the agent.
+// WithMCPResources fetches the resources from the MCP servers and sets them in the agent.
 // It filters the resources based on the provided names and converts them to a Resource format.
-// It requires the MCP server to be running and accessible at the specified address.
+// The Server field of every resource is the name of the server that advertised it (see ReadResource).
+// It requires the MCP servers to be running and accessible.
 // The resources are expected to be in the format defined by the MCP server.
 // It returns an AgentOption that can be used to configure the agent.
 func WithMCPResources(resources []string) AgentOption {
 	return func(agent *Agent) {
-		// Get the resources from the MCP client
-		mcpResources, err := agent.mcpClient.ListResources(agent.ctx, nil)
+		if !agent.checkMCPClient() {
+			return
+		}
+		resourcesList, err := agent.loadMCPResources(resources)
 		if err != nil {
 			agent.lastError = err
 			return
 		}
-		resourcesList := []Resource{}
-		if len(resources) == 0 {
-			// If no resources are specified, use all available resources
-			for _, resource := range mcpResources.Resources {
-				resourcesList = append(resourcesList, Resource{
-					URI:         resource.Uri,
-					Name:        resource.Name,
-					Description: *resource.Description,
-					MimeType:    *resource.MimeType,
-				})
-			}
-
-		} else {
-			for _, resource := range mcpResources.Resources {
-				for _, r := range resources {
-					if resource.Name == r {
-						resourcesList = append(resourcesList, Resource{
-							URI:         resource.Uri,
-							Name:        resource.Name,
-							Description: *resource.Description,
-							MimeType:    *resource.MimeType,
-						})
-					}
-				}
+		agent.Resources = resourcesList
+		// Keep the filter to refresh the resources (see RefreshMCP)
+		agent.mcpLists.resourcesFilter = resources
+		agent.mcpLists.resourcesLoaded = true
+	}
+}
 
+// loadMCPResources fetches the resources of the MCP servers and returns the resources selected by the filter
+// (see WithMCPResources).
+func (agent *Agent) loadMCPResources(resources []string) ([]Resource, error) {
+	resourcesList := []Resource{}
+	for _, server := range agent.mcpServers {
+		// Get the resources from the MCP client
+		var mcpResources *mcp_golang.ListResourcesResponse
+		err := server.call(agent.context(), agent, func(client *mcp_golang.Client) (err error) {
+			mcpResources, err = client.ListResources(agent.context(), nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the resources of MCP server %s: %v", server.name, err)
+		}
+		for _, resource := range mcpResources.Resources {
+			// If no resources are specified, use all available resources
+			if len(resources) > 0 && !slices.Contains(resources, resource.Name) {
+				continue
 			}
+			resourcesList = append(resourcesList, Resource{
+				URI:         resource.Uri,
+				Name:        resource.Name,
+				Description: stringValue(resource.Description),
+				MimeType:    stringValue(resource.MimeType),
+				Server:      server.name,
+			})
 		}
-		agent.Resources = resourcesList
 	}
+	return resourcesList, nil
 }
 
-
-// WithMCPPrompts fetches the prompts from the MCP server and sets them in the agent.
+// WithMCPPrompts fetches the prompts from the MCP servers and sets them in the agent.
 // It filters the prompts based on the provided names and converts them to a Prompt format.
-// It requires the MCP server to be running and accessible at the specified address.
+// The Server field of every prompt is the name of the server that advertised it (see GetPrompt).
+// It requires the MCP servers to be running and accessible.
 // The prompts are expected to be in the format defined by the MCP server.
 // It returns an AgentOption that can be used to configure the agent.
 func WithMCPPrompts(prompts []string) AgentOption {
-	// TODO: -> factorize the arguments conversion
 	return func(agent *Agent) {
-		mcpPrompts, err := agent.mcpClient.ListPrompts(agent.ctx, nil)
+		if !agent.checkMCPClient() {
+			return
+		}
+		promptsList, err := agent.loadMCPPrompts(prompts)
 		if err != nil {
 			agent.lastError = err
 			return
 		}
-		promptsList := []Prompt{}
-		if len(prompts) == 0 {
-			// If no prompts are specified, use all available prompts
-			for _, prompt := range mcpPrompts.Prompts {
-				// Convert []mcp_golang.PromptSchemaArgument to []map[string]any
-				args := make([]map[string]any, len(prompt.Arguments))
-				for i, arg := range prompt.Arguments {
-					// Marshal to JSON then unmarshal to map[string]any
-					b, _ := json.Marshal(arg)
-					_ = json.Unmarshal(b, &args[i])
-				}
-				promptsList = append(promptsList, Prompt{
-					Name:        prompt.Name,
-					Description: *prompt.Description,
-					Arguments:   args,
-				})
-			}
-
-		} else {
-			for _, prompt := range mcpPrompts.Prompts {
-				for _, p := range prompts {
-					if prompt.Name == p {
-						// Convert []mcp_golang.PromptSchemaArgument to []map[string]any
-						args := make([]map[string]any, len(prompt.Arguments))
-						for i, arg := range prompt.Arguments {
-							// Marshal to JSON then unmarshal to map[string]any
-							b, _ := json.Marshal(arg)
-							_ = json.Unmarshal(b, &args[i])
-						}
-						promptsList = append(promptsList, Prompt{
-							Name:        prompt.Name,
-							Description: *prompt.Description,
-							Arguments:   args,
-						})
-					}
-				}
+		agent.Prompts = promptsList
+		// Keep the filter to refresh the prompts (see RefreshMCP)
+		agent.mcpLists.promptsFilter = prompts
+		agent.mcpLists.promptsLoaded = true
+	}
+}
 
+// loadMCPPrompts fetches the prompts of the MCP servers and returns the prompts selected by the filter
+// (see WithMCPPrompts).
+func (agent *Agent) loadMCPPrompts(prompts []string) ([]Prompt, error) {
+	promptsList := []Prompt{}
+	for _, server := range agent.mcpServers {
+		var mcpPrompts *mcp_golang.ListPromptsResponse
+		err := server.call(agent.context(), agent, func(client *mcp_golang.Client) (err error) {
+			mcpPrompts, err = client.ListPrompts(agent.context(), nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the prompts of MCP server %s: %v", server.name, err)
+		}
+		for _, prompt := range mcpPrompts.Prompts {
+			// If no prompts are specified, use all available prompts
+			if len(prompts) > 0 && !slices.Contains(prompts, prompt.Name) {
+				continue
 			}
+			promptsList = append(promptsList, Prompt{
+				Name:        prompt.Name,
+				Description: stringValue(prompt.Description),
+				Arguments:   convertPromptArguments(prompt.Arguments),
+				Server:      server.name,
+			})
 		}
-		agent.Prompts = promptsList
 	}
+	return promptsList, nil
+}
+
+// convertPromptArguments converts the arguments of an MCP prompt to []map[string]any.
+func convertPromptArguments(arguments []mcp_golang.PromptSchemaArgument) []map[string]any {
+	args := make([]map[string]any, len(arguments))
+	for i, arg := range arguments {
+		// Marshal to JSON then unmarshal to map[string]any
+		b, _ := json.Marshal(arg)
+		_ = json.Unmarshal(b, &args[i])
+	}
+	return args
 }
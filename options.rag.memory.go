@@ -1,48 +1,25 @@
 package robby
 
-import (
-	"fmt"
-
-	"github.com/openai/openai-go"
-)
-
 // WithRAGMemory initializes the Agent with a RAG memory using the provided chunks.
-// It creates a MemoryVectorStore and saves the embeddings of the chunks into it.
-// The chunks should be pre-processed text data that will be used for retrieval-augmented generation (RAG).
+// It saves the embeddings of the chunks into the vector store set with WithVectorStore,
+// or into a new MemoryVectorStore if the Agent has no vector store.
+// The chunks should be pre-processed text data that will be used for retrieval-augmented generation (RAG)
+// (see ChunkDocuments and WithRAGMemoryChunks to keep their metadata).
+// The embeddings are created by batches, with retries (see WithEmbeddingPolicy);
+// if chunks fail, NewAgent returns an *EmbeddingError listing them (with the Agent with EmbeddingSkipAndReport).
 // It returns an AgentOption that can be used to configure the agent.
 func WithRAGMemory(chunks []string) AgentOption {
 	return func(agent *Agent) {
-		// -------------------------------------------------
-		// Create a vector store
-		// -------------------------------------------------
-		agent.Store = MemoryVectorStore{
-			Records: make(map[string]VectorRecord),
-		}
-
-		// -------------------------------------------------
-		// Create and save the embeddings from the chunks
-		// -------------------------------------------------
-		for _, chunk := range chunks {
-
-			agent.EmbeddingParams.Input = openai.EmbeddingNewParamsInputUnion{
-				OfString: openai.String(chunk),
-			}
-			embeddingsResponse, err := agent.dmrClient.Embeddings.New(agent.ctx, agent.EmbeddingParams)
+		agent.setEmbeddingError(agent.saveChunks(textChunks(chunks)))
+	}
+}
 
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				_, errSave := agent.Store.Save(VectorRecord{
-					Prompt:    chunk,
-					Embedding: embeddingsResponse.Data[0].Embedding,
-				})
-				if errSave != nil {
-					agent.lastError = errSave
-					// QUESTION: How to handle the error?
-					// TODO: do some samples to define what to do
-					// IMPORTANT! ...
-				}
-			}
-		}
+// WithRAGMemoryChunks initializes the Agent with a RAG memory using the provided chunks and their metadata
+// (e.g. the chunks of ChunkDocuments, with the source of every chunk), like WithRAGMemory.
+// The metadata are saved with the embeddings, so the searches can be filtered (see Filter).
+// It returns an AgentOption that can be used to configure the agent.
+func WithRAGMemoryChunks(chunks []Chunk) AgentOption {
+	return func(agent *Agent) {
+		agent.setEmbeddingError(agent.saveChunks(chunks))
 	}
 }
@@ -1,6 +1,10 @@
 package robby
 
-import "fmt"
+import (
+	"fmt"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
 
 // GetPrompt retrieves a prompt by its name and arguments from the MCP client.
 // It constructs a Prompt object with the name, description, and messages.
@@ -8,9 +12,32 @@ import "fmt"
 // If the prompt is not found or an error occurs, it returns an error.
 // If the prompt is found, it returns the Prompt object.
 // It requires the MCP server to be running and accessible at the specified address.
+// If several MCP servers are attached, the prompt is retrieved from the server that advertised it
+// (see WithMCPPrompts), or from the first attached server if the prompt is unknown.
 func (agent *Agent) GetPrompt(name string, args any) (Prompt, error) {
+	// Apply the changes of the MCP prompts lists (the errors are ignored: the current list is used)
+	_ = agent.refreshMCPIfChanged()
 
-	mcpPromptResponse, err := agent.mcpClient.GetPrompt(agent.ctx, name, args)
+	description, serverName := "", ""
+	for _, prompt := range agent.Prompts {
+		if prompt.Name == name {
+			description = prompt.Description
+			serverName = prompt.Server
+			break
+		}
+	}
+	//QUESTION: is there a better way to find the description in the list of prompts?
+
+	server, err := agent.serverOf(serverName)
+	if err != nil {
+		return Prompt{}, fmt.Errorf("failed to get prompt %s: %v", name, err)
+	}
+
+	var mcpPromptResponse *mcp_golang.PromptResponse
+	err = server.call(agent.context(), agent, func(client *mcp_golang.Client) (err error) {
+		mcpPromptResponse, err = client.GetPrompt(agent.context(), name, args)
+		return err
+	})
 	if err != nil {
 		return Prompt{}, fmt.Errorf("failed to get prompt %s: %v", name, err)
 	}
@@ -26,19 +53,11 @@ func (agent *Agent) GetPrompt(name string, args any) (Prompt, error) {
 		})
 	}
 
-	description := ""
-	for _, prompt := range agent.Prompts {
-		if prompt.Name == name {
-			description = prompt.Description
-			break
-		}
-	}
-	//QUESTION: is there a better way to find the description in the list of prompts?
-
 	mcpPrompt := Prompt{
 		Name:        name,
 		Description: description,
 		Messages:    messages,
+		Server:      server.name,
 	}
 
 	return mcpPrompt, nil
@@ -0,0 +1,177 @@
+package robby
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// The parameters of the BM25 scoring: the saturation of the term frequencies, and the normalization by the length of the chunks.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// KeywordSearcher is implemented by the vector stores with a keyword index (MemoryVectorStore).
+// The keyword searches of the other stores (see RAGMemoryKeywordSearch) index all their records at every search.
+type KeywordSearcher interface {
+	// SearchKeywords returns the max records matched by the filters with the best BM25 scores for the query
+	// (KeywordScore is set), sorted by score. The records without any term of the query are not returned.
+	SearchKeywords(query string, max int, filters ...Filter) ([]VectorRecord, error)
+}
+
+// SearchKeywords implements KeywordSearcher. The BM25 index of the prompts of the records is built at the first
+// keyword search, then it is updated by Save, Delete and DeleteWhere (like the HNSW index, the records must not
+// be changed in Records directly).
+func (mvs *MemoryVectorStore) SearchKeywords(query string, max int, filters ...Filter) ([]VectorRecord, error) {
+	if mvs.keywords == nil {
+		mvs.keywords = newBM25Index()
+		for id, record := range mvs.Records {
+			mvs.keywords.add(id, record.Prompt)
+		}
+	}
+	var records []VectorRecord
+	for _, result := range mvs.keywords.search(query) {
+		record := mvs.Records[result.id]
+		if !matchFilters(record, filters) {
+			continue
+		}
+		record.KeywordScore = result.score
+		records = append(records, mvs.withEmbedding(record))
+		if len(records) == max {
+			break
+		}
+	}
+	return records, nil
+}
+
+// searchKeywords runs a keyword search in the store: with its keyword index if it has one,
+// otherwise with a BM25 index of all its records.
+func searchKeywords(store VectorStore, query string, max int, filters []Filter) ([]VectorRecord, error) {
+	if searcher, ok := store.(KeywordSearcher); ok {
+		return searcher.SearchKeywords(query, max, filters...)
+	}
+	all, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	temporary := &MemoryVectorStore{Records: make(map[string]VectorRecord, len(all))}
+	for _, record := range all {
+		temporary.Records[record.Id] = record
+	}
+	return temporary.SearchKeywords(query, max, filters...)
+}
+
+// --- BM25 index ---
+
+// bm25Index is an inverted index of the terms of the records.
+type bm25Index struct {
+	postings    map[string]map[string]int // the frequency of every term in every record
+	records     map[string]bm25Record
+	totalLength int
+}
+
+// bm25Record is an indexed record: its number of terms, and its distinct terms.
+type bm25Record struct {
+	length int
+	terms  []string
+}
+
+// bm25Result is a record with its score.
+type bm25Result struct {
+	id    string
+	score float64
+}
+
+func newBM25Index() *bm25Index {
+	return &bm25Index{postings: map[string]map[string]int{}, records: map[string]bm25Record{}}
+}
+
+// add indexes the text of a record (the previous text of the record is removed).
+func (index *bm25Index) add(id string, text string) {
+	index.remove(id)
+	terms := tokenize(text)
+	distinct := []string{}
+	for _, term := range terms {
+		if index.postings[term] == nil {
+			index.postings[term] = map[string]int{}
+		}
+		if index.postings[term][id] == 0 {
+			distinct = append(distinct, term)
+		}
+		index.postings[term][id]++
+	}
+	index.records[id] = bm25Record{length: len(terms), terms: distinct}
+	index.totalLength += len(terms)
+}
+
+// remove removes a record from the index.
+func (index *bm25Index) remove(id string) {
+	record, ok := index.records[id]
+	if !ok {
+		return
+	}
+	for _, term := range record.terms {
+		delete(index.postings[term], id)
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+	delete(index.records, id)
+	index.totalLength -= record.length
+}
+
+// search returns the records with a term of the query, sorted by BM25 score.
+func (index *bm25Index) search(query string) []bm25Result {
+	if len(index.records) == 0 {
+		return nil
+	}
+	count := float64(len(index.records))
+	averageLength := max(float64(index.totalLength)/count, 1)
+	scores := map[string]float64{}
+	terms := tokenize(query)
+	slices.Sort(terms)
+	for _, term := range slices.Compact(terms) {
+		frequencies := index.postings[term]
+		idf := math.Log(1 + (count-float64(len(frequencies))+0.5)/(float64(len(frequencies))+0.5))
+		for id, frequency := range frequencies {
+			tf := float64(frequency)
+			norm := 1 - bm25B + bm25B*float64(index.records[id].length)/averageLength
+			scores[id] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	results := make([]bm25Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, bm25Result{id: id, score: score})
+	}
+	slices.SortFunc(results, func(a, b bm25Result) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.id, b.id)
+	})
+	return results
+}
+
+// tokenize returns the lower case terms of the text. The identifiers with separators (ERR-4012, node_modules, v1.2)
+// are kept as a term, with their parts, so the exact identifiers and their parts are both found.
+func tokenize(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.'
+	}) {
+		word = strings.Trim(word, "-_.")
+		if word == "" {
+			continue
+		}
+		parts := strings.FieldsFunc(word, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+		terms = append(terms, parts...)
+		if len(parts) > 1 {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
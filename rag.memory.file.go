@@ -0,0 +1,295 @@
+package robby
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// VectorStoreFormat is the format of a MemoryVectorStore file (see SaveToFile).
+type VectorStoreFormat int
+
+const (
+	// VectorStoreJSON is a readable JSON file.
+	VectorStoreJSON VectorStoreFormat = iota
+	// VectorStoreBinary is a compact binary file (the embeddings are stored as raw float64), for large stores.
+	VectorStoreBinary
+)
+
+// vectorStoreMagic starts the binary vector store files.
+const vectorStoreMagic = "ROBBYVS\x00"
+
+// vectorStoreFileVersion is the version of the vector store file formats.
+const vectorStoreFileVersion = 1
+
+// The limits of the sizes read from a binary vector store file (a corrupted file must not allocate gigabytes).
+const (
+	vectorStoreMaxStringSize = 1 << 28
+	vectorStoreMaxDimension  = 1 << 20
+)
+
+// vectorStoreFile is the content of a JSON vector store file.
+type vectorStoreFile struct {
+	Version   int                  `json:"version"`
+	Model     string               `json:"model"`
+	Dimension int                  `json:"dimension"`
+	Records   []storedVectorRecord `json:"records"`
+}
+
+// SaveToFile saves the records of the MemoryVectorStore in a file, with the embedding model (Model)
+// and the dimension of the embeddings, so the store can be reloaded with LoadFromFile or WithRAGMemoryFromFile
+// instead of computing the embeddings again.
+// All the embeddings must have the same dimension. The file is replaced atomically.
+func (mvs *MemoryVectorStore) SaveToFile(path string, format VectorStoreFormat) error {
+	dimension, err := mvs.dimension()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, func(w io.Writer) error {
+		switch format {
+		case VectorStoreJSON:
+			return mvs.writeJSON(w, dimension)
+		case VectorStoreBinary:
+			return mvs.writeBinary(w, dimension)
+		default:
+			return fmt.Errorf("unknown vector store format %d", format)
+		}
+	})
+}
+
+// LoadFromFile replaces the records and the Model of the MemoryVectorStore with the content of a file
+// saved by SaveToFile (the format is detected).
+func (mvs *MemoryVectorStore) LoadFromFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	header, _ := reader.Peek(len(vectorStoreMagic))
+	var loaded *MemoryVectorStore
+	if string(header) == vectorStoreMagic {
+		loaded, err = readVectorStoreBinary(reader)
+	} else {
+		loaded, err = readVectorStoreJSON(reader)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load vector store %s: %w", path, err)
+	}
+	mvs.Model, mvs.Records, mvs.keywords = loaded.Model, loaded.Records, nil
+	if mvs.index != nil {
+		mvs.EnableHNSWIndex(mvs.index.config)
+	}
+	return nil
+}
+
+// WithRAGMemoryFromFile initializes the Agent with a RAG memory loaded from a file saved by MemoryVectorStore.SaveToFile
+// (instead of computing the embeddings of the chunks again, see WithRAGMemory).
+// The embedding model of the file must be the model of the Agent's embedding parameters:
+// WithEmbeddingParams must be used before this option.
+// It returns an AgentOption that can be used to configure the agent.
+func WithRAGMemoryFromFile(path string) AgentOption {
+	return func(agent *Agent) {
+		store := &MemoryVectorStore{}
+		if err := store.LoadFromFile(path); err != nil {
+			agent.lastError = err
+			return
+		}
+		if store.Model != agent.EmbeddingParams.Model {
+			agent.lastError = fmt.Errorf("vector store %s was built with the embedding model %q, but the agent uses %q",
+				path, store.Model, agent.EmbeddingParams.Model)
+			return
+		}
+		agent.Store = store
+	}
+}
+
+// dimension returns the dimension of the embeddings of the store (0 if the store is empty),
+// or an error if the embeddings do not have the same dimension.
+func (mvs *MemoryVectorStore) dimension() (int, error) {
+	dimension := -1
+	for _, record := range mvs.all() {
+		if dimension == -1 {
+			dimension = len(record.Embedding)
+		} else if len(record.Embedding) != dimension {
+			return 0, fmt.Errorf("the embeddings of the vector store have different dimensions (%d and %d)", dimension, len(record.Embedding))
+		}
+	}
+	return max(dimension, 0), nil
+}
+
+// writeJSON writes the store in the JSON format.
+func (mvs *MemoryVectorStore) writeJSON(w io.Writer, dimension int) error {
+	content := vectorStoreFile{
+		Version:   vectorStoreFileVersion,
+		Model:     mvs.Model,
+		Dimension: dimension,
+		Records:   make([]storedVectorRecord, 0, len(mvs.Records)),
+	}
+	for _, record := range mvs.all() {
+		content.Records = append(content.Records, toStoredVectorRecord(record))
+	}
+	return json.NewEncoder(w).Encode(content)
+}
+
+// readVectorStoreJSON reads a store in the JSON format.
+func readVectorStoreJSON(r io.Reader) (*MemoryVectorStore, error) {
+	content := vectorStoreFile{}
+	if err := json.NewDecoder(r).Decode(&content); err != nil {
+		return nil, err
+	}
+	if content.Version != vectorStoreFileVersion {
+		return nil, fmt.Errorf("unsupported version %d", content.Version)
+	}
+	mvs := &MemoryVectorStore{Model: content.Model, Records: make(map[string]VectorRecord, len(content.Records))}
+	for _, record := range content.Records {
+		if len(record.Embedding) != content.Dimension {
+			return nil, fmt.Errorf("record %s: expected an embedding of dimension %d, got %d", record.Id, content.Dimension, len(record.Embedding))
+		}
+		mvs.Records[record.Id] = record.vectorRecord()
+	}
+	return mvs, nil
+}
+
+// writeBinary writes the store in the binary format (little endian):
+// the magic header, the version (uint32), the model (string), the dimension (uint32), the number of records (uint64),
+// then for every record: the id (string), the prompt (string), the metadata (string, in JSON, empty without metadata)
+// and the embedding (dimension float64).
+// A string is its length in bytes (uint32) followed by its bytes.
+func (mvs *MemoryVectorStore) writeBinary(w io.Writer, dimension int) error {
+	encoder := binaryEncoder{w: w}
+	encoder.bytes([]byte(vectorStoreMagic))
+	encoder.uint32(vectorStoreFileVersion)
+	encoder.string(mvs.Model)
+	encoder.uint32(uint32(dimension))
+	encoder.uint64(uint64(len(mvs.Records)))
+	for _, record := range mvs.all() {
+		encoder.string(record.Id)
+		encoder.string(record.Prompt)
+		metadata := []byte{}
+		if len(record.Metadata) > 0 {
+			var err error
+			if metadata, err = json.Marshal(record.Metadata); err != nil {
+				return fmt.Errorf("record %s: invalid metadata: %w", record.Id, err)
+			}
+		}
+		encoder.string(string(metadata))
+		for _, value := range record.Embedding {
+			encoder.uint64(math.Float64bits(value))
+		}
+	}
+	return encoder.err
+}
+
+// readVectorStoreBinary reads a store in the binary format (see writeBinary).
+func readVectorStoreBinary(r io.Reader) (*MemoryVectorStore, error) {
+	decoder := binaryDecoder{r: r}
+	if magic := decoder.bytes(len(vectorStoreMagic)); decoder.err == nil && string(magic) != vectorStoreMagic {
+		return nil, errors.New("not a vector store file")
+	}
+	version := decoder.uint32()
+	if decoder.err == nil && version != vectorStoreFileVersion {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+	mvs := &MemoryVectorStore{Model: decoder.string(), Records: map[string]VectorRecord{}}
+	dimension := int(decoder.uint32())
+	if dimension > vectorStoreMaxDimension {
+		return nil, fmt.Errorf("invalid dimension %d", dimension)
+	}
+	count := decoder.uint64()
+	for i := uint64(0); i < count && decoder.err == nil; i++ {
+		record := VectorRecord{Id: decoder.string(), Prompt: decoder.string(), Embedding: make([]float64, dimension)}
+		if metadata := decoder.string(); metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &record.Metadata); err != nil {
+				return nil, fmt.Errorf("record %s: invalid metadata: %w", record.Id, err)
+			}
+		}
+		// The embedding is read at once
+		if data := decoder.bytes(8 * dimension); data != nil {
+			for j := range record.Embedding {
+				record.Embedding[j] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*j:]))
+			}
+		}
+		mvs.Records[record.Id] = record
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+	return mvs, nil
+}
+
+// binaryEncoder writes little endian values, and keeps the first error.
+type binaryEncoder struct {
+	w   io.Writer
+	err error
+	buf [8]byte
+}
+
+func (e *binaryEncoder) bytes(data []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(data)
+	}
+}
+
+func (e *binaryEncoder) uint32(value uint32) {
+	binary.LittleEndian.PutUint32(e.buf[:4], value)
+	e.bytes(e.buf[:4])
+}
+
+func (e *binaryEncoder) uint64(value uint64) {
+	binary.LittleEndian.PutUint64(e.buf[:], value)
+	e.bytes(e.buf[:])
+}
+
+func (e *binaryEncoder) string(value string) {
+	e.uint32(uint32(len(value)))
+	e.bytes([]byte(value))
+}
+
+// binaryDecoder reads little endian values, and keeps the first error (the values read after an error are zero).
+type binaryDecoder struct {
+	r   io.Reader
+	err error
+}
+
+func (d *binaryDecoder) bytes(size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		d.err = fmt.Errorf("truncated file: %w", err)
+		return nil
+	}
+	return data
+}
+
+func (d *binaryDecoder) uint32() uint32 {
+	if data := d.bytes(4); data != nil {
+		return binary.LittleEndian.Uint32(data)
+	}
+	return 0
+}
+
+func (d *binaryDecoder) uint64() uint64 {
+	if data := d.bytes(8); data != nil {
+		return binary.LittleEndian.Uint64(data)
+	}
+	return 0
+}
+
+func (d *binaryDecoder) string() string {
+	size := d.uint32()
+	if size > vectorStoreMaxStringSize {
+		d.err = fmt.Errorf("invalid string size %d", size)
+		return ""
+	}
+	return string(d.bytes(int(size)))
+}
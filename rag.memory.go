@@ -9,17 +9,29 @@ import (
 )
 
 type VectorRecord struct {
-	Id               string    `json:"id"`
-	Prompt           string    `json:"prompt"`
-	Embedding        []float64 `json:"embedding"`
+	Id               string         `json:"id"`
+	Prompt           string         `json:"prompt"`
+	Embedding        []float64      `json:"embedding"`
+	Metadata         map[string]any `json:"metadata,omitempty"` // e.g. source, title, chunk index, tags (see Filter)
 	CosineSimilarity float64
+	KeywordScore     float64 // the BM25 score of the keyword searches (see KeywordSearcher)
+	Score            float64 // the fused score of the hybrid searches (see RAGMemoryHybridSearch)
 }
 
+// MemoryVectorStore is the in-memory VectorStore (see SaveToFile to persist it).
+// The searches compare the question with every record, unless the HNSW index is enabled (see EnableHNSWIndex).
 type MemoryVectorStore struct {
 	Records map[string]VectorRecord
+	Model   string // the embedding model of the records, recorded by SaveToFile
+
+	index    *hnswIndex
+	keywords *bm25Index // built by the first keyword search (see SearchKeywords)
 }
 
 func (mvs *MemoryVectorStore) GetAll() ([]VectorRecord, error) {
+	if mvs.index != nil {
+		return mvs.all(), nil
+	}
 	var records []VectorRecord
 	for _, record := range mvs.Records {
 		records = append(records, record)
@@ -35,24 +47,59 @@ func (mvs *MemoryVectorStore) Save(vectorRecord VectorRecord) (VectorRecord, err
 	if vectorRecord.Id == "" {
 		vectorRecord.Id = uuid.New().String()
 	}
+	if mvs.Records == nil {
+		mvs.Records = make(map[string]VectorRecord)
+	}
+	if mvs.keywords != nil {
+		mvs.keywords.add(vectorRecord.Id, vectorRecord.Prompt)
+	}
+	if mvs.index != nil {
+		mvs.indexRecord(vectorRecord)
+		return vectorRecord, nil
+	}
 	mvs.Records[vectorRecord.Id] = vectorRecord
 	return vectorRecord, nil
 }
 
+// Delete removes the vector record with the given ID from the MemoryVectorStore.
+// It returns ErrVectorRecordNotFound if the record does not exist.
+func (mvs *MemoryVectorStore) Delete(id string) error {
+	if _, ok := mvs.Records[id]; !ok {
+		return ErrVectorRecordNotFound
+	}
+	delete(mvs.Records, id)
+	if mvs.index != nil {
+		mvs.index.remove(id)
+	}
+	if mvs.keywords != nil {
+		mvs.keywords.remove(id)
+	}
+	return nil
+}
+
 // SearchSimilarities searches for vector records in the MemoryVectorStore that have a cosine distance similarity greater than or equal to the given limit.
 //
 // Parameters:
 //   - embeddingFromQuestion: the vector record to compare similarities with.
 //   - limit: the minimum cosine distance similarity threshold.
+//   - filters: the filters on the metadata of the records (see Filter).
 //
 // Returns:
 //   - []llm.VectorRecord: a slice of vector records that have a cosine distance similarity greater than or equal to the limit.
 //   - error: an error if any occurred during the search.
-func (mvs *MemoryVectorStore) SearchSimilarities(embeddingFromQuestion VectorRecord, limit float64) ([]VectorRecord, error) {
+//
+// With the HNSW index, the results are approximate.
+func (mvs *MemoryVectorStore) SearchSimilarities(embeddingFromQuestion VectorRecord, limit float64, filters ...Filter) ([]VectorRecord, error) {
+	if mvs.index != nil {
+		return mvs.searchIndex(embeddingFromQuestion, limit, 0, filters), nil
+	}
 
 	var records []VectorRecord
 
 	for _, v := range mvs.Records {
+		if !matchFilters(v, filters) {
+			continue
+		}
 		distance := cosineSimilarity(embeddingFromQuestion.Embedding, v.Embedding)
 		if distance >= limit {
 			v.CosineSimilarity = distance
@@ -66,14 +113,57 @@ func (mvs *MemoryVectorStore) SearchSimilarities(embeddingFromQuestion VectorRec
 // It returns a slice of vector records and an error if any.
 // The limit parameter specifies the minimum similarity score for a record to be considered similar.
 // The max parameter specifies the maximum number of vector records to return.
-func (mvs *MemoryVectorStore) SearchTopNSimilarities(embeddingFromQuestion VectorRecord, limit float64, max int) ([]VectorRecord, error) {
-	records, err := mvs.SearchSimilarities(embeddingFromQuestion, limit)
+// The filters select the records by their metadata (see Filter).
+func (mvs *MemoryVectorStore) SearchTopNSimilarities(embeddingFromQuestion VectorRecord, limit float64, max int, filters ...Filter) ([]VectorRecord, error) {
+	if mvs.index != nil && max > 0 {
+		return mvs.searchIndex(embeddingFromQuestion, limit, max, filters), nil
+	}
+	records, err := mvs.SearchSimilarities(embeddingFromQuestion, limit, filters...)
 	if err != nil {
 		return nil, err
 	}
 	return getTopNVectorRecords(records, max), nil
 }
 
+// DeleteWhere removes the vector records matched by the filter from the MemoryVectorStore.
+// It returns the number of removed records.
+func (mvs *MemoryVectorStore) DeleteWhere(filter Filter) (int, error) {
+	if filter == nil {
+		return 0, errNoFilter
+	}
+	deleted := 0
+	for id, record := range mvs.Records {
+		if filter.Match(record.Metadata) {
+			delete(mvs.Records, id)
+			if mvs.index != nil {
+				mvs.index.remove(id)
+			}
+			if mvs.keywords != nil {
+				mvs.keywords.remove(id)
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// UpdateWhere sets the metadata values of the vector records matched by the filter (a nil value removes the key).
+// It returns the number of updated records.
+func (mvs *MemoryVectorStore) UpdateWhere(filter Filter, metadata map[string]any) (int, error) {
+	if filter == nil {
+		return 0, errNoFilter
+	}
+	updated := 0
+	for id, record := range mvs.Records {
+		if filter.Match(record.Metadata) {
+			record.Metadata = updateMetadata(record.Metadata, metadata)
+			mvs.Records[id] = record
+			updated++
+		}
+	}
+	return updated, nil
+}
+
 // getTopNVectorRecords returns the top N vector records based on their cosine similarity.
 func getTopNVectorRecords(records []VectorRecord, max int) []VectorRecord {
 	// Sort the records slice in descending order based on CosineDistance
@@ -121,8 +211,12 @@ func cosineSimilarity(v1, v2 []float64) float64 {
 // If no similar records are found, it returns an empty slice.
 // It requires the DMR client to be initialized and the embedding parameters to be set in the Agent.
 // The limit parameter specifies the minimum cosine similarity score for a record to be considered similar.
+// The filters select the records by their metadata (see Filter).
 // It returns an error if the embedding creation fails or if the search operation fails.
-func (agent *Agent) RAGMemorySearchSimilaritiesWithText(text string, limit float64) ([]string, error) {
+func (agent *Agent) RAGMemorySearchSimilaritiesWithText(text string, limit float64, filters ...Filter) ([]string, error) {
+	if _, err := agent.vectorStore(); err != nil {
+		return nil, err
+	}
 	// Create the embedding from the question
 	agent.EmbeddingParams.Input = openai.EmbeddingNewParamsInputUnion{
 		OfString: openai.String(text),
@@ -138,7 +232,10 @@ func (agent *Agent) RAGMemorySearchSimilaritiesWithText(text string, limit float
 		Embedding: embeddingResponse.Data[0].Embedding,
 	}
 
-	similarities, _ := agent.Store.SearchSimilarities(embeddingFromText, limit)
+	similarities, err := agent.Store.SearchSimilarities(embeddingFromText, limit, filters...)
+	if err != nil {
+		return nil, err
+	}
 	var results []string
 	for _, similarity := range similarities {
 		results = append(results, similarity.Prompt)
@@ -153,8 +250,12 @@ func (agent *Agent) RAGMemorySearchSimilaritiesWithText(text string, limit float
 // If no similar records are found, it returns an empty slice.
 // It requires the DMR client to be initialized and the embedding parameters to be set in the Agent.
 // The limit parameter specifies the minimum cosine similarity score for a record to be considered similar.
+// The filters select the records by their metadata (see Filter).
 // It returns an error if the embedding creation fails or if the search operation fails.
-func (agent *Agent) RAGMemorySearchSimilaritiesWith(embedding openai.EmbeddingNewParamsInputUnion, limit float64) ([]string, error) {
+func (agent *Agent) RAGMemorySearchSimilaritiesWith(embedding openai.EmbeddingNewParamsInputUnion, limit float64, filters ...Filter) ([]string, error) {
+	if _, err := agent.vectorStore(); err != nil {
+		return nil, err
+	}
 		// Create the embedding from the question
 	agent.EmbeddingParams.Input = embedding
 	embeddingResponse, err := agent.dmrClient.Embeddings.New(agent.ctx, agent.EmbeddingParams)
@@ -168,7 +269,10 @@ func (agent *Agent) RAGMemorySearchSimilaritiesWith(embedding openai.EmbeddingNe
 		Embedding: embeddingResponse.Data[0].Embedding,
 	}
 
-	similarities, _ := agent.Store.SearchSimilarities(embeddingFromText, limit)
+	similarities, err := agent.Store.SearchSimilarities(embeddingFromText, limit, filters...)
+	if err != nil {
+		return nil, err
+	}
 	var results []string
 	for _, similarity := range similarities {
 		results = append(results, similarity.Prompt)
@@ -0,0 +1,394 @@
+package robby
+
+import (
+	"cmp"
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// HNSWConfig configures the HNSW index of a MemoryVectorStore (see EnableHNSWIndex).
+// The zero fields are replaced by the values of DefaultHNSWConfig.
+type HNSWConfig struct {
+	M              int  // Maximum number of neighbours of a node on every layer (2*M on the bottom layer): more is better recall, more memory
+	EfConstruction int  // Size of the candidates list when a record is inserted: more is a better index, slower insertions
+	EfSearch       int  // Minimum size of the candidates list of a search: more is better recall, slower searches
+	KeepEmbeddings bool // Keep the float64 embeddings in Records as well as the float32 vectors of the index (more than twice the memory)
+}
+
+// DefaultHNSWConfig is the configuration used by EnableHNSWIndex for the fields that are not set.
+var DefaultHNSWConfig = HNSWConfig{
+	M:              16,
+	EfConstruction: 200,
+	EfSearch:       64,
+}
+
+// hnswMinRebuildSize is the number of deleted nodes from which the index is rebuilt,
+// when the deleted nodes are more than the live nodes.
+const hnswMinRebuildSize = 1024
+
+// EnableHNSWIndex builds an approximate nearest neighbour index (HNSW: Hierarchical Navigable Small World graphs)
+// of the records of the MemoryVectorStore, so the searches do not compare the question with every record.
+// The vectors of the index are normalized (the cosine similarity becomes a dot product) and stored as float32,
+// and the float64 embeddings are dropped from Records to halve the memory (unless config.KeepEmbeddings):
+// the embeddings of the records returned by the store are then the normalized vectors of the index.
+//
+// The results of the searches are approximate (see HNSWConfig.EfSearch). While the index is enabled,
+// the records must be changed with the methods of the store (Save, Delete, DeleteWhere...), not in Records.
+func (mvs *MemoryVectorStore) EnableHNSWIndex(config HNSWConfig) {
+	if config.M <= 0 {
+		config.M = DefaultHNSWConfig.M
+	}
+	if config.EfConstruction <= 0 {
+		config.EfConstruction = DefaultHNSWConfig.EfConstruction
+	}
+	if config.EfSearch <= 0 {
+		config.EfSearch = DefaultHNSWConfig.EfSearch
+	}
+
+	records := mvs.all()
+	mvs.index = newHNSWIndex(config)
+	for _, record := range records {
+		mvs.indexRecord(record)
+	}
+}
+
+// DisableHNSWIndex removes the index: the searches compare the question with every record (exact results).
+// The embeddings dropped by the index are restored from its normalized vectors.
+func (mvs *MemoryVectorStore) DisableHNSWIndex() {
+	if mvs.index == nil {
+		return
+	}
+	records := mvs.all()
+	mvs.index = nil
+	for _, record := range records {
+		mvs.Records[record.Id] = record
+	}
+}
+
+// indexRecord adds the record to the index (it replaces the previous version of the record),
+// and drops its float64 embedding (unless the index keeps the embeddings).
+func (mvs *MemoryVectorStore) indexRecord(record VectorRecord) {
+	mvs.index.add(record.Id, normalizeVector(record.Embedding))
+	if !mvs.index.config.KeepEmbeddings {
+		record.Embedding = nil
+	}
+	mvs.Records[record.Id] = record
+}
+
+// all returns the records of the store, with their embeddings (see EnableHNSWIndex).
+func (mvs *MemoryVectorStore) all() []VectorRecord {
+	records := make([]VectorRecord, 0, len(mvs.Records))
+	for _, record := range mvs.Records {
+		records = append(records, mvs.withEmbedding(record))
+	}
+	return records
+}
+
+// withEmbedding returns the record with its embedding: the index keeps only the normalized vector by default.
+func (mvs *MemoryVectorStore) withEmbedding(record VectorRecord) VectorRecord {
+	if record.Embedding == nil && mvs.index != nil {
+		if vector := mvs.index.vector(record.Id); vector != nil {
+			record.Embedding = make([]float64, len(vector))
+			for i, value := range vector {
+				record.Embedding[i] = float64(value)
+			}
+		}
+	}
+	return record
+}
+
+// searchIndex returns the records matched by the filters with a similarity greater than or equal to limit,
+// found with the index (at most max records if max > 0), sorted by similarity.
+// The number of candidates of the search is doubled until enough records are found,
+// the last candidate is under the limit, or the whole graph is visited.
+func (mvs *MemoryVectorStore) searchIndex(embeddingFromQuestion VectorRecord, limit float64, max int, filters []Filter) []VectorRecord {
+	query := normalizeVector(embeddingFromQuestion.Embedding)
+	k := max
+	if k <= 0 {
+		k = mvs.index.config.EfSearch
+	}
+	for {
+		candidates, exhausted := mvs.index.search(query, k, k)
+		records := []VectorRecord{}
+		for _, candidate := range candidates {
+			similarity := float64(candidate.similarity)
+			record := mvs.Records[mvs.index.nodes[candidate.node].id]
+			if similarity >= limit && matchFilters(record, filters) {
+				record.CosineSimilarity = similarity
+				records = append(records, mvs.withEmbedding(record))
+			}
+		}
+		complete := exhausted ||
+			(len(candidates) > 0 && float64(candidates[len(candidates)-1].similarity) < limit)
+		if complete || (max > 0 && len(records) >= max) {
+			if max > 0 && len(records) > max {
+				records = records[:max]
+			}
+			return records
+		}
+		k *= 2
+	}
+}
+
+// --- HNSW index ---
+
+// hnswIndex is a HNSW graph of normalized float32 vectors.
+// The deleted nodes stay in the graph (they are still traversed), and the graph is rebuilt
+// when they are more than the live nodes.
+type hnswIndex struct {
+	config    HNSWConfig
+	levelMult float64
+	rng       *rand.Rand
+
+	nodes    []hnswNode
+	ids      map[string]int32 // the live node of every record
+	entry    int32            // the entry point of the searches (-1 if the graph is empty)
+	maxLevel int
+	deleted  int
+}
+
+type hnswNode struct {
+	id        string
+	vector    []float32
+	neighbors [][]int32 // the neighbours on every layer of the node
+	deleted   bool
+}
+
+// hnswCandidate is a node with its similarity to the query.
+type hnswCandidate struct {
+	node       int32
+	similarity float32
+}
+
+func newHNSWIndex(config HNSWConfig) *hnswIndex {
+	return &hnswIndex{
+		config:    config,
+		levelMult: 1 / math.Log(float64(max(config.M, 2))),
+		// a fixed seed: the same records give the same graph
+		rng:   rand.New(rand.NewPCG(1, 2)),
+		ids:   map[string]int32{},
+		entry: -1,
+	}
+}
+
+// live returns the number of live nodes.
+func (index *hnswIndex) live() int {
+	return len(index.ids)
+}
+
+// vector returns the normalized vector of the record, or nil.
+func (index *hnswIndex) vector(id string) []float32 {
+	if node, ok := index.ids[id]; ok {
+		return index.nodes[node].vector
+	}
+	return nil
+}
+
+// maxNeighbors returns the maximum number of neighbours of a node on the layer.
+func (index *hnswIndex) maxNeighbors(layer int) int {
+	if layer == 0 {
+		return 2 * index.config.M
+	}
+	return index.config.M
+}
+
+// add inserts the vector of a record in the graph (the previous vector of the record is removed).
+func (index *hnswIndex) add(id string, vector []float32) {
+	index.remove(id)
+
+	level := int(-math.Log(1-index.rng.Float64()) * index.levelMult)
+	node := int32(len(index.nodes))
+	index.nodes = append(index.nodes, hnswNode{id: id, vector: vector, neighbors: make([][]int32, level+1)})
+	index.ids[id] = node
+	if index.entry == -1 {
+		index.entry, index.maxLevel = node, level
+		return
+	}
+
+	// Greedy descent to the level of the node, then the neighbours are searched on every layer of the node
+	entry := index.entry
+	for layer := index.maxLevel; layer > level; layer-- {
+		entry = index.searchLayer(vector, []int32{entry}, 1, layer)[0].node
+	}
+	entries := []int32{entry}
+	for layer := min(level, index.maxLevel); layer >= 0; layer-- {
+		candidates := index.searchLayer(vector, entries, index.config.EfConstruction, layer)
+		neighbors := make([]int32, 0, index.config.M)
+		for _, candidate := range candidates[:min(index.config.M, len(candidates))] {
+			neighbors = append(neighbors, candidate.node)
+		}
+		index.nodes[node].neighbors[layer] = neighbors
+
+		// The links are bidirectional: the neighbours with too many links keep the closest ones
+		for _, neighbor := range neighbors {
+			links := append(index.nodes[neighbor].neighbors[layer], node)
+			if len(links) > index.maxNeighbors(layer) {
+				links = index.closest(index.nodes[neighbor].vector, links, index.maxNeighbors(layer))
+			}
+			index.nodes[neighbor].neighbors[layer] = links
+		}
+
+		entries = entries[:0]
+		for _, candidate := range candidates {
+			entries = append(entries, candidate.node)
+		}
+	}
+	if level > index.maxLevel {
+		index.entry, index.maxLevel = node, level
+	}
+}
+
+// remove marks the node of the record as deleted, and rebuilds the graph if there are too many deleted nodes.
+func (index *hnswIndex) remove(id string) {
+	node, ok := index.ids[id]
+	if !ok {
+		return
+	}
+	index.nodes[node].deleted = true
+	delete(index.ids, id)
+	index.deleted++
+	if index.deleted >= hnswMinRebuildSize && index.deleted > index.live() {
+		index.rebuild()
+	}
+}
+
+// rebuild builds the graph again with the live nodes only.
+func (index *hnswIndex) rebuild() {
+	nodes := index.nodes
+	*index = *newHNSWIndex(index.config)
+	for _, node := range nodes {
+		if !node.deleted {
+			index.add(node.id, node.vector)
+		}
+	}
+}
+
+// search returns the k live nodes closest to the query, sorted by similarity,
+// with a candidates list of size max(ef, EfSearch, k).
+// exhausted is true if all the nodes reachable from the entry point were visited (a larger list would not find more nodes).
+func (index *hnswIndex) search(query []float32, k int, ef int) (results []hnswCandidate, exhausted bool) {
+	if index.entry == -1 {
+		return nil, true
+	}
+	entry := index.entry
+	for layer := index.maxLevel; layer > 0; layer-- {
+		entry = index.searchLayer(query, []int32{entry}, 1, layer)[0].node
+	}
+	ef = max(ef, index.config.EfSearch, k)
+	candidates := index.searchLayer(query, []int32{entry}, ef, 0)
+
+	results = make([]hnswCandidate, 0, k)
+	for _, candidate := range candidates {
+		if !index.nodes[candidate.node].deleted {
+			results = append(results, candidate)
+			if len(results) == k {
+				break
+			}
+		}
+	}
+	return results, len(candidates) < ef
+}
+
+// searchLayer returns the ef nodes closest to the query found from the entries on the layer, sorted by similarity.
+func (index *hnswIndex) searchLayer(query []float32, entries []int32, ef int, layer int) []hnswCandidate {
+	visited := make(map[int32]struct{}, ef*index.config.M)
+	candidates := &hnswHeap{closestFirst: true} // the nodes to visit, the closest first
+	results := &hnswHeap{}                      // the ef closest nodes, the farthest first
+	for _, entry := range entries {
+		visited[entry] = struct{}{}
+		candidate := hnswCandidate{node: entry, similarity: dotProduct32(query, index.nodes[entry].vector)}
+		heap.Push(candidates, candidate)
+		heap.Push(results, candidate)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && current.similarity < results.items[0].similarity {
+			break
+		}
+		for _, neighbor := range index.nodes[current.node].neighbors[layer] {
+			if _, seen := visited[neighbor]; seen {
+				continue
+			}
+			visited[neighbor] = struct{}{}
+			similarity := dotProduct32(query, index.nodes[neighbor].vector)
+			if results.Len() < ef || similarity > results.items[0].similarity {
+				candidate := hnswCandidate{node: neighbor, similarity: similarity}
+				heap.Push(candidates, candidate)
+				heap.Push(results, candidate)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := results.items
+	slices.SortFunc(sorted, func(a, b hnswCandidate) int { return cmp.Compare(b.similarity, a.similarity) })
+	return sorted
+}
+
+// closest returns the n nodes closest to the vector.
+func (index *hnswIndex) closest(vector []float32, nodes []int32, n int) []int32 {
+	candidates := make([]hnswCandidate, len(nodes))
+	for i, node := range nodes {
+		candidates[i] = hnswCandidate{node: node, similarity: dotProduct32(vector, index.nodes[node].vector)}
+	}
+	slices.SortFunc(candidates, func(a, b hnswCandidate) int { return cmp.Compare(b.similarity, a.similarity) })
+	closest := make([]int32, n)
+	for i := range closest {
+		closest[i] = candidates[i].node
+	}
+	return closest
+}
+
+// hnswHeap is a heap of candidates: the closest first, or the farthest first.
+type hnswHeap struct {
+	items        []hnswCandidate
+	closestFirst bool
+}
+
+func (h *hnswHeap) Len() int { return len(h.items) }
+func (h *hnswHeap) Less(i, j int) bool {
+	if h.closestFirst {
+		return h.items[i].similarity > h.items[j].similarity
+	}
+	return h.items[i].similarity < h.items[j].similarity
+}
+func (h *hnswHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *hnswHeap) Push(x any)    { h.items = append(h.items, x.(hnswCandidate)) }
+func (h *hnswHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// normalizeVector returns the vector divided by its norm, as float32 (a zero vector stays a zero vector).
+func normalizeVector(vector []float64) []float32 {
+	norm := math.Sqrt(dotProduct(vector, vector))
+	normalized := make([]float32, len(vector))
+	if norm > 0 {
+		for i, value := range vector {
+			normalized[i] = float32(value / norm)
+		}
+	}
+	return normalized
+}
+
+// dotProduct32 calculates the dot product of two float32 vectors (the cosine similarity of normalized vectors).
+// A vector of another dimension has no similarity.
+func dotProduct32(v1, v2 []float32) float32 {
+	if len(v1) != len(v2) {
+		return 0
+	}
+	sum := float32(0)
+	for i := range v1 {
+		sum += v1[i] * v2[i]
+	}
+	return sum
+}
@@ -0,0 +1,227 @@
+package robby
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// FusionMethod defines how RAGMemoryHybridSearch fuses the rankings of the vector and keyword searches.
+type FusionMethod int
+
+const (
+	// ReciprocalRankFusion scores every record with the sum of 1/(RRFK + rank) of its ranks in both rankings:
+	// only the ranks count, so the cosine similarities and the BM25 scores do not have to be comparable.
+	ReciprocalRankFusion FusionMethod = iota
+	// WeightedFusion scores every record with VectorWeight × its cosine similarity + (1 - VectorWeight) × its BM25 score,
+	// both normalized between 0 and 1 over the candidates (min-max normalization).
+	WeightedFusion
+)
+
+// HybridSearchConfig configures the hybrid searches of the RAG memory (see RAGMemoryHybridSearch).
+// The zero fields (and a nil VectorWeight) are replaced by the values of DefaultHybridSearchConfig.
+type HybridSearchConfig struct {
+	Fusion       FusionMethod // Reciprocal rank fusion (default), or weighted scores
+	VectorWeight *float64     // Weight of the cosine similarity with WeightedFusion, between 0 and 1 (the keyword weight is 1 - VectorWeight), e.g. openai.Ptr(0.3)
+	RRFK         int          // Constant of the reciprocal rank fusion: the higher, the less the first ranks dominate
+	Candidates   int          // Number of records of each ranking to fuse (at least the number of results)
+}
+
+// DefaultHybridSearchConfig is the hybrid search configuration used for the fields that are not set.
+var DefaultHybridSearchConfig = HybridSearchConfig{
+	Fusion:       ReciprocalRankFusion,
+	VectorWeight: openai.Ptr(0.5),
+	RRFK:         60,
+	Candidates:   50,
+}
+
+// WithHybridSearch sets how the hybrid searches of the RAG memory fuse the vector and keyword rankings.
+// It returns an AgentOption that can be used to configure the agent.
+func WithHybridSearch(config HybridSearchConfig) AgentOption {
+	return func(agent *Agent) {
+		if config.VectorWeight == nil {
+			config.VectorWeight = DefaultHybridSearchConfig.VectorWeight
+		}
+		// A copy: the weight cannot be changed after the option is applied
+		vectorWeight := *config.VectorWeight
+		if vectorWeight < 0 || vectorWeight > 1 {
+			agent.lastError = fmt.Errorf("invalid hybrid search vector weight %v: it must be between 0 and 1", vectorWeight)
+			return
+		}
+		config.VectorWeight = &vectorWeight
+		if config.RRFK <= 0 {
+			config.RRFK = DefaultHybridSearchConfig.RRFK
+		}
+		if config.Candidates <= 0 {
+			config.Candidates = DefaultHybridSearchConfig.Candidates
+		}
+		agent.hybridSearch = &config
+	}
+}
+
+// RAGMemoryKeywordSearch searches the RAG memory with the terms of the text only (BM25 scoring, no embedding):
+// it returns the max records matched by the filters with the best keyword scores (KeywordScore is set),
+// e.g. the chunks with a product code or an error identifier. A max <= 0 returns all the matching records.
+// It works with every VectorStore (see KeywordSearcher).
+func (agent *Agent) RAGMemoryKeywordSearch(text string, max int, filters ...Filter) ([]VectorRecord, error) {
+	store, err := agent.vectorStore()
+	if err != nil {
+		return nil, err
+	}
+	return searchKeywords(store, text, max, filters)
+}
+
+// RAGMemoryVectorSearch searches the RAG memory with the embedding of the text only (cosine similarity, no keywords):
+// it returns the max records matched by the filters with the best cosine similarities (CosineSimilarity is set).
+// A max <= 0 returns all the matching records.
+func (agent *Agent) RAGMemoryVectorSearch(text string, max int, filters ...Filter) ([]VectorRecord, error) {
+	store, err := agent.vectorStore()
+	if err != nil {
+		return nil, err
+	}
+	embeddings, err := agent.createEmbeddings(agent.context(), agent.embeddingPolicyOrDefault(), []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the embedding of the text: %w", err)
+	}
+	question := VectorRecord{Embedding: embeddings[0]}
+	if max > 0 {
+		return store.SearchTopNSimilarities(question, -1, max, filters...)
+	}
+	records, err := store.SearchSimilarities(question, -1, filters...)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(records, func(a, b VectorRecord) int { return cmp.Compare(b.CosineSimilarity, a.CosineSimilarity) })
+	return records, nil
+}
+
+// RAGMemoryHybridSearch searches the RAG memory with both the embedding of the text (cosine similarity)
+// and its terms (BM25 scoring), and fuses the two rankings (see WithHybridSearch):
+// the records similar to the text and the records with its exact terms (product codes, error identifiers...) are both found.
+// It returns the max records matched by the filters with the best fused scores, sorted by Score
+// (CosineSimilarity and KeywordScore are also set).
+func (agent *Agent) RAGMemoryHybridSearch(text string, max int, filters ...Filter) ([]VectorRecord, error) {
+	store, err := agent.vectorStore()
+	if err != nil {
+		return nil, err
+	}
+	config := agent.hybridSearch
+	if config == nil {
+		config = &DefaultHybridSearchConfig
+	}
+	candidates := config.Candidates
+	if max > candidates {
+		candidates = max
+	}
+
+	embeddings, err := agent.createEmbeddings(agent.context(), agent.embeddingPolicyOrDefault(), []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the embedding of the text: %w", err)
+	}
+	question := VectorRecord{Embedding: embeddings[0]}
+	vectorRecords, err := store.SearchTopNSimilarities(question, -1, candidates, filters...)
+	if err != nil {
+		return nil, err
+	}
+	keywordRecords, err := searchKeywords(store, text, candidates, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	records := fuseRankings(config, vectorRecords, keywordRecords)
+	for i := range records {
+		if records[i].CosineSimilarity == 0 && len(records[i].Embedding) == len(question.Embedding) {
+			// A record found by the keyword search only
+			records[i].CosineSimilarity = cosineSimilarity(question.Embedding, records[i].Embedding)
+		}
+	}
+	if max > 0 && len(records) > max {
+		records = records[:max]
+	}
+	return records, nil
+}
+
+// RAGMemoryHybridSearchWithText is RAGMemoryHybridSearch returning the prompts of the records.
+func (agent *Agent) RAGMemoryHybridSearchWithText(text string, max int, filters ...Filter) ([]string, error) {
+	records, err := agent.RAGMemoryHybridSearch(text, max, filters...)
+	if err != nil {
+		return nil, err
+	}
+	var results []string
+	for _, record := range records {
+		results = append(results, record.Prompt)
+	}
+	return results, nil
+}
+
+// fuseRankings merges the records of the vector and keyword rankings (sorted by score), sets their fused Score,
+// and sorts them by fused score.
+func fuseRankings(config *HybridSearchConfig, vectorRecords, keywordRecords []VectorRecord) []VectorRecord {
+	fused := map[string]*VectorRecord{}
+	var records []*VectorRecord
+	merge := func(record VectorRecord) *VectorRecord {
+		if existing, ok := fused[record.Id]; ok {
+			existing.KeywordScore = record.KeywordScore
+			return existing
+		}
+		fused[record.Id] = &record
+		records = append(records, &record)
+		return &record
+	}
+
+	switch config.Fusion {
+	case WeightedFusion:
+		vectorWeight := *config.VectorWeight
+		vectorScores := normalizeScores(vectorRecords, func(record VectorRecord) float64 { return record.CosineSimilarity })
+		keywordScores := normalizeScores(keywordRecords, func(record VectorRecord) float64 { return record.KeywordScore })
+		for i, record := range vectorRecords {
+			merge(record).Score += vectorWeight * vectorScores[i]
+		}
+		for i, record := range keywordRecords {
+			merge(record).Score += (1 - vectorWeight) * keywordScores[i]
+		}
+	default:
+		k := float64(config.RRFK)
+		for rank, record := range vectorRecords {
+			merge(record).Score += 1 / (k + float64(rank+1))
+		}
+		for rank, record := range keywordRecords {
+			merge(record).Score += 1 / (k + float64(rank+1))
+		}
+	}
+
+	results := make([]VectorRecord, len(records))
+	for i, record := range records {
+		results[i] = *record
+	}
+	slices.SortFunc(results, func(a, b VectorRecord) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+	return results
+}
+
+// normalizeScores returns the scores of the records scaled between 0 and 1 (the records with the same score all get 1).
+func normalizeScores(records []VectorRecord, score func(VectorRecord) float64) []float64 {
+	scores := make([]float64, len(records))
+	if len(records) == 0 {
+		return scores
+	}
+	low, high := score(records[0]), score(records[0])
+	for _, record := range records {
+		low, high = min(low, score(record)), max(high, score(record))
+	}
+	for i, record := range records {
+		if high == low {
+			scores[i] = 1
+		} else {
+			scores[i] = (score(record) - low) / (high - low)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,85 @@
+package robby
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func newTestVectorStore() MemoryVectorStore {
+	return MemoryVectorStore{
+		Model: "ai/mxbai-embed-large",
+		Records: map[string]VectorRecord{
+			"emma": {Id: "emma", Prompt: "Emma Peel is a secret agent", Embedding: []float64{0.1, -0.2, 0.3},
+				Metadata: map[string]any{"source": "avengers.md", "chunk": float64(0), "tags": []any{"agent"}}},
+			"steed": {Id: "steed", Prompt: "John Steed wears a bowler hat", Embedding: []float64{1e-300, 42, -7.5}},
+		},
+	}
+}
+
+func TestMemoryVectorStoreFile(t *testing.T) {
+	store := newTestVectorStore()
+	for name, format := range map[string]VectorStoreFormat{"store.json": VectorStoreJSON, "store.bin": VectorStoreBinary} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := store.SaveToFile(path, format); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := MemoryVectorStore{}
+		if err := loaded.LoadFromFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, store) {
+			t.Fatalf("%s: expected %+v, got %+v", name, store, loaded)
+		}
+	}
+
+	// The binary format is detected with its header, and a truncated file is refused
+	path := filepath.Join(t.TempDir(), "store.bin")
+	_ = store.SaveToFile(path, VectorStoreBinary)
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), vectorStoreMagic) {
+		t.Fatal("expected the magic header")
+	}
+	_ = os.WriteFile(path, data[:len(data)-4], 0o644)
+	if err := (&MemoryVectorStore{}).LoadFromFile(path); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("expected a truncated file error, got %v", err)
+	}
+
+	store.Records["short"] = VectorRecord{Id: "short", Embedding: []float64{1}}
+	if err := store.SaveToFile(path, VectorStoreJSON); err == nil {
+		t.Fatal("expected an error for embeddings of different dimensions")
+	}
+}
+
+func TestWithRAGMemoryFromFile(t *testing.T) {
+	store := newTestVectorStore()
+	path := filepath.Join(t.TempDir(), "store.bin")
+	if err := store.SaveToFile(path, VectorStoreBinary); err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := NewAgent(
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "ai/mxbai-embed-large"}),
+		WithRAGMemoryFromFile(path),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := bob.Store.GetAll()
+	if len(records) != 2 || bob.Store.(*MemoryVectorStore).Records["steed"].Prompt != "John Steed wears a bowler hat" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	_, err = NewAgent(
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "ai/all-minilm"}),
+		WithRAGMemoryFromFile(path),
+	)
+	if err == nil || !strings.Contains(err.Error(), "ai/mxbai-embed-large") {
+		t.Fatalf("expected a model mismatch error, got %v", err)
+	}
+}
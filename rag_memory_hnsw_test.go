@@ -0,0 +1,207 @@
+package robby
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// randomVectorStore returns a MemoryVectorStore of count random records of the given dimension.
+func randomVectorStore(rng *rand.Rand, count int, dimension int) *MemoryVectorStore {
+	store := &MemoryVectorStore{}
+	for i := range count {
+		store.Save(VectorRecord{Id: fmt.Sprintf("record-%d", i), Embedding: randomVector(rng, dimension), Metadata: map[string]any{"even": i%2 == 0}})
+	}
+	return store
+}
+
+func randomVector(rng *rand.Rand, dimension int) []float64 {
+	vector := make([]float64, dimension)
+	for i := range vector {
+		vector[i] = rng.NormFloat64()
+	}
+	return vector
+}
+
+// recall returns the fraction of the expected records found.
+func recall(expected, found []VectorRecord) float64 {
+	ids := map[string]bool{}
+	for _, record := range found {
+		ids[record.Id] = true
+	}
+	matched := 0
+	for _, record := range expected {
+		if ids[record.Id] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(expected))
+}
+
+func TestHNSWIndexRecall(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	exact := randomVectorStore(rng, 2000, 32)
+	indexed := &MemoryVectorStore{}
+	indexed.EnableHNSWIndex(HNSWConfig{})
+	for _, record := range exact.Records {
+		indexed.Save(record)
+	}
+
+	total := 0.0
+	for range 50 {
+		question := VectorRecord{Embedding: randomVector(rng, 32)}
+		expected, _ := exact.SearchTopNSimilarities(question, -1, 10)
+		found, _ := indexed.SearchTopNSimilarities(question, -1, 10)
+		if len(found) != 10 {
+			t.Fatalf("expected 10 records, got %d", len(found))
+		}
+		total += recall(expected, found)
+
+		// The filters and the limit are applied to the candidates of the index
+		found, _ = indexed.SearchTopNSimilarities(question, 0.3, 5, Eq("even", true))
+		for _, record := range found {
+			if record.Metadata["even"] != true || record.CosineSimilarity < 0.3 {
+				t.Fatalf("unexpected record %+v", record)
+			}
+		}
+	}
+	if total/50 < 0.9 {
+		t.Fatalf("expected a recall of at least 0.9, got %.2f", total/50)
+	}
+
+	// The searches with a limit only find the records over the limit
+	question := VectorRecord{Embedding: randomVector(rng, 32)}
+	expected, _ := exact.SearchSimilarities(question, 0.4)
+	found, _ := indexed.SearchSimilarities(question, 0.4)
+	if recall(expected, found) < 0.9 || len(found) > len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(found))
+	}
+
+	// The deleted records are not found, and the index is rebuilt when most records are deleted
+	deleted, _ := indexed.DeleteWhere(Eq("even", true))
+	for i := 1; i < 1000; i += 2 {
+		indexed.Delete(fmt.Sprintf("record-%d", i))
+	}
+	if deleted != 1000 || indexed.index.live() != 500 || indexed.index.deleted >= hnswMinRebuildSize {
+		t.Fatalf("unexpected index: %d live nodes, %d deleted nodes", indexed.index.live(), indexed.index.deleted)
+	}
+	found, _ = indexed.SearchTopNSimilarities(question, -1, 600)
+	if len(found) != 500 {
+		t.Fatalf("expected the 500 records, got %d", len(found))
+	}
+	for _, record := range found {
+		if _, ok := indexed.Records[record.Id]; !ok {
+			t.Fatalf("unexpected deleted record %s", record.Id)
+		}
+	}
+}
+
+func TestHNSWIndexVectorStore(t *testing.T) {
+	store := &MemoryVectorStore{}
+	store.EnableHNSWIndex(HNSWConfig{})
+	checkVectorStore(t, store)
+
+	// The index keeps only the normalized vectors
+	if store.Records["steed"].Embedding != nil {
+		t.Fatal("expected no float64 embedding in an indexed store")
+	}
+	records, _ := store.GetAll()
+	if len(records) != 1 || len(records[0].Embedding) != 3 {
+		t.Fatalf("expected the normalized embedding, got %+v", records)
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.bin")
+	if err := store.SaveToFile(path, VectorStoreBinary); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &MemoryVectorStore{}
+	loaded.EnableHNSWIndex(DefaultHNSWConfig)
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	top, _ := loaded.SearchTopNSimilarities(VectorRecord{Embedding: fakeEmbedding("Steed")}, 0.9, 1)
+	if len(top) != 1 || top[0].Id != "steed" {
+		t.Fatalf("unexpected similarities: %+v", top)
+	}
+}
+
+func TestDisableHNSWIndex(t *testing.T) {
+	rng := rand.New(rand.NewPCG(2, 2))
+	store := randomVectorStore(rng, 300, 16)
+	question := VectorRecord{Embedding: randomVector(rng, 16)}
+	expected, _ := store.SearchTopNSimilarities(question, 0, 20)
+
+	store.EnableHNSWIndex(HNSWConfig{})
+	store.DisableHNSWIndex()
+	if store.index != nil || store.Records["record-0"].Embedding == nil {
+		t.Fatal("expected the embeddings without the index")
+	}
+
+	// Without the index, the results are exact again (the embeddings were normalized by the index)
+	found, _ := store.SearchTopNSimilarities(question, 0, 20)
+	if len(found) != len(expected) || recall(expected, found) != 1 {
+		t.Fatalf("expected the exact results, got %d records", len(found))
+	}
+}
+
+// BenchmarkMemoryVectorStoreSearch compares the brute force searches with the HNSW index.
+func BenchmarkMemoryVectorStoreSearch(b *testing.B) {
+	rng := rand.New(rand.NewPCG(3, 3))
+	store := randomVectorStore(rng, 20000, 128)
+	questions := make([]VectorRecord, 100)
+	for i := range questions {
+		questions[i] = VectorRecord{Embedding: randomVector(rng, 128)}
+	}
+
+	b.Run("brute-force", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			store.SearchTopNSimilarities(questions[i%len(questions)], 0, 10)
+		}
+	})
+
+	indexed := &MemoryVectorStore{Records: maps.Clone(store.Records)}
+	indexed.EnableHNSWIndex(HNSWConfig{EfConstruction: 100})
+	for _, efSearch := range []int{16, 64, 256} {
+		b.Run(fmt.Sprintf("hnsw-ef-%d", efSearch), func(b *testing.B) {
+			indexed.index.config.EfSearch = efSearch
+			b.ReportAllocs()
+			for i := 0; b.Loop(); i++ {
+				indexed.SearchTopNSimilarities(questions[i%len(questions)], 0, 10)
+			}
+		})
+	}
+}
+
+// BenchmarkMemoryVectorStoreMemory reports the heap size of a store of 5,000 vectors of dimension 128,
+// without index, with the index (float32 vectors only), and with the index keeping the float64 embeddings.
+func BenchmarkMemoryVectorStoreMemory(b *testing.B) {
+	configs := map[string]*HNSWConfig{"no-index": nil, "hnsw": {EfConstruction: 100}, "hnsw-keep-embeddings": {EfConstruction: 100, KeepEmbeddings: true}}
+	for _, name := range []string{"no-index", "hnsw", "hnsw-keep-embeddings"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var heap uint64
+			for b.Loop() {
+				before := heapAlloc()
+				store := randomVectorStore(rand.New(rand.NewPCG(4, 4)), 5000, 128)
+				if config := configs[name]; config != nil {
+					store.EnableHNSWIndex(*config)
+				}
+				heap = heapAlloc() - before
+				runtime.KeepAlive(store)
+			}
+			b.ReportMetric(float64(heap)/(1<<20), "heap-MB")
+		})
+	}
+}
+
+// heapAlloc returns the size of the live heap objects, after a garbage collection.
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
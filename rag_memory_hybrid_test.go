@@ -0,0 +1,169 @@
+package robby
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// saveHybridRecords saves the records of the hybrid search tests: the vector search of "Emma and ERR-4012"
+// ranks the Emma records first, only the keyword search finds the error code.
+func saveHybridRecords(t *testing.T, store VectorStore) {
+	t.Helper()
+	for _, record := range []VectorRecord{
+		{Id: "emma-1", Prompt: "Emma Peel is a scientist", Embedding: fakeEmbedding("Emma")},
+		{Id: "emma-2", Prompt: "Emma Peel drives a Lotus", Embedding: []float64{1, 0.3, 0}},
+		{Id: "steed", Prompt: "John Steed handles ERR-4012", Embedding: fakeEmbedding("Steed"), Metadata: map[string]any{"source": "errors.md"}},
+		{Id: "mother", Prompt: "Mother is the superior", Embedding: fakeEmbedding("Mother")},
+	} {
+		if _, err := store.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func recordIds(records []VectorRecord) []string {
+	ids := []string{}
+	for _, record := range records {
+		ids = append(ids, record.Id)
+	}
+	return ids
+}
+
+func TestTokenize(t *testing.T) {
+	terms := tokenize("Error ERR-4012 in node_modules (v1.2).")
+	expected := []string{"error", "err", "4012", "err-4012", "in", "node", "modules", "node_modules", "v1", "2", "v1.2"}
+	if !slices.Equal(terms, expected) {
+		t.Fatalf("unexpected terms: %q", terms)
+	}
+}
+
+func TestRAGMemoryKeywordSearch(t *testing.T) {
+	store := &MemoryVectorStore{}
+	saveHybridRecords(t, store)
+	bob, err := NewAgent(WithVectorStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := bob.RAGMemoryKeywordSearch("err-4012", 0)
+	if err != nil || !slices.Equal(recordIds(records), []string{"steed"}) || records[0].KeywordScore <= 0 {
+		t.Fatalf("expected the error code record, got %+v, %v", records, err)
+	}
+	if records, _ := bob.RAGMemoryKeywordSearch("Emma", 1); !slices.Equal(recordIds(records), []string{"emma-1"}) {
+		t.Fatalf("expected 1 record, got %v", recordIds(records))
+	}
+	if records, _ := bob.RAGMemoryKeywordSearch("Emma 4012", 0, Eq("source", "errors.md")); !slices.Equal(recordIds(records), []string{"steed"}) {
+		t.Fatalf("expected the filtered record, got %v", recordIds(records))
+	}
+
+	// The keyword index follows the changes of the store
+	if err := store.Delete("steed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Save(VectorRecord{Id: "tara", Prompt: "Tara King fixes ERR-4012"}); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := bob.RAGMemoryKeywordSearch("ERR-4012", 0); !slices.Equal(recordIds(records), []string{"tara"}) {
+		t.Fatalf("expected the new record, got %v", recordIds(records))
+	}
+	if count, _ := store.DeleteWhere(Eq("source", "errors.md")); count != 0 {
+		t.Fatalf("expected no deleted records, got %d", count)
+	}
+	if records, _ := bob.RAGMemoryKeywordSearch("steed", 0); len(records) != 0 {
+		t.Fatalf("expected no records, got %v", recordIds(records))
+	}
+}
+
+func TestRAGMemoryHybridSearch(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	store := &MemoryVectorStore{}
+	saveHybridRecords(t, store)
+
+	// Reciprocal rank fusion: the error code record is ranked between the Emma records
+	bob, err := NewAgent(fake.Option(), WithVectorStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := bob.RAGMemoryHybridSearch("Emma and ERR-4012", 2)
+	if err != nil || !slices.Equal(recordIds(records), []string{"emma-1", "steed"}) {
+		t.Fatalf("unexpected records: %v, %v", recordIds(records), err)
+	}
+	if steed := records[1]; steed.KeywordScore <= 0 || steed.CosineSimilarity <= 0 || steed.Score <= 0 {
+		t.Fatalf("unexpected scores: %+v", steed)
+	}
+	if prompts, _ := bob.RAGMemoryHybridSearchWithText("Emma and ERR-4012", 1, Eq("source", "errors.md")); !slices.Equal(prompts, []string{"John Steed handles ERR-4012"}) {
+		t.Fatalf("unexpected prompts: %q", prompts)
+	}
+
+	// Weighted scores: the vector weight decides
+	bob, _ = NewAgent(fake.Option(), WithVectorStore(store), WithHybridSearch(HybridSearchConfig{Fusion: WeightedFusion}))
+	if records, _ := bob.RAGMemoryHybridSearch("Emma and ERR-4012", 2); !slices.Equal(recordIds(records), []string{"steed", "emma-1"}) {
+		t.Fatalf("unexpected records: %v", recordIds(records))
+	}
+	bob, _ = NewAgent(fake.Option(), WithVectorStore(store), WithHybridSearch(HybridSearchConfig{Fusion: WeightedFusion, VectorWeight: openai.Ptr(0.9)}))
+	if records, _ := bob.RAGMemoryHybridSearch("Emma and ERR-4012", 2); !slices.Equal(recordIds(records), []string{"emma-1", "emma-2"}) {
+		t.Fatalf("unexpected records: %v", recordIds(records))
+	}
+	// A zero vector weight is kept: only the keyword scores count
+	bob, _ = NewAgent(fake.Option(), WithVectorStore(store), WithHybridSearch(HybridSearchConfig{Fusion: WeightedFusion, VectorWeight: openai.Ptr(0.0)}))
+	records, _ = bob.RAGMemoryHybridSearch("Emma and ERR-4012", 0)
+	for _, record := range records {
+		if record.Id == "mother" && record.Score != 0 {
+			t.Fatalf("unexpected score of a record without keywords: %+v", record)
+		}
+	}
+	if len(records) == 0 || records[0].Id != "steed" || records[0].Score != 1 {
+		t.Fatalf("unexpected records: %v", recordIds(records))
+	}
+
+	if _, err := NewAgent(WithHybridSearch(HybridSearchConfig{VectorWeight: openai.Ptr(2.0)})); err == nil {
+		t.Fatal("expected an error with an invalid vector weight")
+	}
+}
+
+func TestRAGMemoryVectorSearch(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	store := &MemoryVectorStore{}
+	saveHybridRecords(t, store)
+
+	// Only the cosine similarities count: the error code record is not found
+	bob, err := NewAgent(fake.Option(), WithVectorStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := bob.RAGMemoryVectorSearch("Emma and ERR-4012", 2)
+	if err != nil || !slices.Equal(recordIds(records), []string{"emma-1", "emma-2"}) {
+		t.Fatalf("unexpected records: %v, %v", recordIds(records), err)
+	}
+	if records[0].CosineSimilarity < records[1].CosineSimilarity || records[0].KeywordScore != 0 {
+		t.Fatalf("unexpected scores: %+v", records)
+	}
+	if records, _ := bob.RAGMemoryVectorSearch("Emma and ERR-4012", 0); len(records) != 4 || records[0].Id != "emma-1" {
+		t.Fatalf("expected all the records, got %v", recordIds(records))
+	}
+	if records, _ := bob.RAGMemoryVectorSearch("Emma", 0, Eq("source", "errors.md")); !slices.Equal(recordIds(records), []string{"steed"}) {
+		t.Fatalf("unexpected filtered records: %v", recordIds(records))
+	}
+}
+
+func TestRAGMemoryHybridSearchWithBoltVectorStore(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	store, err := NewBoltVectorStore(filepath.Join(t.TempDir(), "vectors.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	saveHybridRecords(t, store)
+
+	bob, err := NewAgent(fake.Option(), WithVectorStore(store), WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := bob.RAGMemoryHybridSearch("Emma and ERR-4012", 2)
+	if err != nil || !slices.Equal(recordIds(records), []string{"emma-1", "steed"}) {
+		t.Fatalf("unexpected records: %v, %v", recordIds(records), err)
+	}
+}
@@ -17,16 +17,15 @@ func main() {
 		robby.WithParams(
 			openai.ChatCompletionNewParams{
 				Model: "ai/qwen2.5:0.5B-F16",
-				Messages: []openai.ChatCompletionMessageParamUnion{
-					openai.SystemMessage("You are a Star Trek expert"),
-					openai.UserMessage("Who is James Kirk?"),
-				},
 				Temperature: openai.Opt(0.9),
 			},
 		),
 	)
 
-	response, err := bob.ChatCompletionStream(func(self *robby.Agent, content string, err error) error {
+	bob.AddSystemMessage("You are a Star Trek expert")
+
+	// AskStream adds the question and the answer to the conversation memory
+	_, err := bob.AskStream("Who is James Kirk?", func(self *robby.Agent, content string, err error) error {
 		fmt.Print(content)
 		return nil
 	})
@@ -35,16 +34,10 @@ func main() {
 		panic(err)
 	}
 
-	// Add assistant response to memory
-	bob.Params.Messages = append(bob.Params.Messages, openai.AssistantMessage(response))
-
-	// Add new user question
-	bob.Params.Messages = append(bob.Params.Messages, openai.UserMessage("Who is his best friend?"))
-
 	fmt.Println("")
 	fmt.Println("")
 
-	_, err = bob.ChatCompletionStream(func(self *robby.Agent, content string, err error) error {
+	_, err = bob.AskStream("Who is his best friend?", func(self *robby.Agent, content string, err error) error {
 		fmt.Print(content)
 		return nil
 	})
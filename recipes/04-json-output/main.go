@@ -8,6 +8,13 @@ import (
 	"github.com/sea-monkeys/robby"
 )
 
+// SearchResult is notable information about a search result.
+type SearchResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
 func main() {
 
 	content := `
@@ -26,36 +33,8 @@ func main() {
 	URL: https://wasi.dev/
 	`
 
-	schema := map[string]any{
-		"type": "array",
-		"items": map[string]any{
-			"type": "object",
-			"properties": map[string]any{
-				"title": map[string]any{
-					"type": "string",
-				},
-				"url": map[string]any{
-					"type": "string",
-				},
-				"description": map[string]any{
-					"type": "string",
-				},
-			},
-			"required": []string{"title", "url", "description"},
-		},
-	}
-
-	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
-		Name:        "search_results",
-		Description: openai.String("Notable information about search results"),
-		Schema:      schema,
-		Strict:      openai.Bool(true),
-	}
-
-	// This agent will use the JSON schema to parse the results
-	// and return the results in a JSON format.
-	// The JSON schema is defined in the schemaParam variable
-	// and is passed to the agent as a parameter.
+	// The JSON schema of the results is generated from the SearchResult type,
+	// and the answer of the model is validated and decoded into a []SearchResult.
 	bob, _ := robby.NewAgent(
 		robby.WithDMRClient(
 			context.Background(),
@@ -69,19 +48,18 @@ func main() {
 					openai.UserMessage("give me the list of the results."),
 				},
 				Temperature: openai.Opt(0.0),
-				ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
-					OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
-						JSONSchema: schemaParam,
-					},
-				},
 			},
 		),
 	)
 
-	jsonResults, err := bob.ChatCompletion()
+	// If the answer does not match the schema, the model is asked again (2 times at most)
+	results, err := robby.StructuredCompletion[[]SearchResult](bob, 2)
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
 	}
-	fmt.Println("📝 JSON Results:\n", jsonResults)
+	fmt.Println("📝 JSON Results:")
+	for _, result := range results {
+		fmt.Printf("- %s (%s)\n  %s\n", result.Title, result.URL, result.Description)
+	}
 }
@@ -31,6 +31,8 @@ func main() {
 		robby.WithMCPClient(robby.WithDockerMCPToolkit()),
 		robby.WithMCPTools([]string{"fetch"}), // you must activate the fetch MCP server in Docker MCP Toolkit
 	)
+	// Stop the MCP server process when the program ends
+	defer bob.Close()
 
 	// Generate the tools detection completion
 	toolCalls, err := bob.ToolsCompletion()
@@ -50,6 +50,8 @@ func main() {
 		robby.WithMCPResources([]string{}),
 		robby.WithMCPPrompts([]string{}),
 	)
+	// Stop the MCP server process when the program ends
+	defer bob.Close()
 
 	// Generate the tools detection completion
 	toolCalls, err := bob.ToolsCompletion()
@@ -3,6 +3,8 @@ package robby
 import (
 	"errors"
 	"fmt"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
 )
 
 // ReadResource retrieves a resource by its URI from the MCP client.
@@ -12,28 +14,47 @@ import (
 // If the resource is found, it returns the Resource object.
 // It requires the MCP server to be running and accessible at the specified address.
 // The resources are expected to be in the format defined by the MCP server.
+// If several MCP servers are attached, the resource is read from the server that advertised it
+// (see WithMCPResources), or from the first attached server if the resource is unknown.
 func (agent *Agent) ReadResource(uri string) (Resource, error) {
-	// TODO: pagination, righ now, only resource text is returned
-	mcpResourceResponse, err := agent.mcpClient.ReadResource(agent.ctx, uri)
-	if err != nil {
-		return Resource{}, fmt.Errorf("failed to read resource %s: %v", uri, err)
-	}
-
-	mcpResource := mcpResourceResponse.Contents[0]
+	// Apply the changes of the MCP resources lists (the errors are ignored: the current list is used)
+	_ = agent.refreshMCPIfChanged()
 
+	// search for the name, description and server in the agent resources
 	resource := Resource{}
-	// search for the name and description in the agent resources
 	for _, rsrc := range agent.Resources {
-		if rsrc.URI == mcpResource.TextResourceContents.Uri {
+		if rsrc.URI == uri {
 			resource.Name = rsrc.Name
 			resource.Description = rsrc.Description
+			resource.Server = rsrc.Server
 			break
 		}
 	}
 	//? is there a better way to find the name and description in the list of resources?
 
+	server, err := agent.serverOf(resource.Server)
+	if err != nil {
+		return Resource{}, fmt.Errorf("failed to read resource %s: %v", uri, err)
+	}
+	resource.Server = server.name
+
+	// TODO: pagination, righ now, only resource text is returned
+	var mcpResourceResponse *mcp_golang.ResourceResponse
+	err = server.call(agent.context(), agent, func(client *mcp_golang.Client) (err error) {
+		mcpResourceResponse, err = client.ReadResource(agent.context(), uri)
+		return err
+	})
+	if err != nil {
+		return Resource{}, fmt.Errorf("failed to read resource %s: %v", uri, err)
+	}
+	if len(mcpResourceResponse.Contents) == 0 || mcpResourceResponse.Contents[0].TextResourceContents == nil {
+		return Resource{}, fmt.Errorf("failed to read resource %s: no text content", uri)
+	}
+
+	mcpResource := mcpResourceResponse.Contents[0]
+
 	resource.URI = mcpResource.TextResourceContents.Uri
-	resource.MimeType = *mcpResource.TextResourceContents.MimeType
+	resource.MimeType = stringValue(mcpResource.TextResourceContents.MimeType)
 	resource.Text = mcpResource.TextResourceContents.Text
 
 	return resource, nil
@@ -2,9 +2,8 @@ package robby
 
 import (
 	"context"
-	"os/exec"
-
-	mcp_golang "github.com/metoro-io/mcp-golang"
+	"io"
+	"time"
 
 	"github.com/openai/openai-go"
 )
@@ -16,6 +15,7 @@ type Resource struct {
 	MimeType    string `json:"mimeType,omitempty"`
 	Text        string `json:"text,omitempty"`
 	Blob        string `json:"blob,omitempty"`
+	Server      string `json:"server,omitempty"` // Name of the MCP server that advertised the resource
 }
 
 type Content struct {
@@ -33,6 +33,7 @@ type Prompt struct {
 	Description string           `json:"description,omitempty"`
 	Arguments   []map[string]any `json:"arguments"`
 	Messages    []Message        `json:"messages,omitempty"` // Optional, for storing messages related to the prompt
+	Server      string           `json:"server,omitempty"`   // Name of the MCP server that advertised the prompt
 }
 
 type Agent struct {
@@ -44,13 +45,33 @@ type Agent struct {
 	Tools     []openai.ChatCompletionToolParam
 	ToolCalls []openai.ChatCompletionMessageToolCall
 
+	toolRegistry          *ToolRegistry
+	maxToolLoopIterations int
+	toolCallsWorkers      int
+	toolCallTimeout       time.Duration
+	toolErrorFormatter    ToolErrorFormatter
+
 	Resources []Resource
 	Prompts   []Prompt
 
-	Store MemoryVectorStore
+	Store           VectorStore
+	embeddingPolicy *EmbeddingPolicy
+	skippedChunks   *EmbeddingError // the chunks skipped with EmbeddingSkipAndReport
+	ragSettings     *ragSettings
+	hybridSearch    *HybridSearchConfig
+
+	mcpServers     []*mcpServer
+	mcpToolRoutes  map[string]mcpToolRoute
+	mcpToolsPrefix bool
 
-	mcpClient *mcp_golang.Client
-	mcpCmd    *exec.Cmd
+	mcpShutdownTimeout time.Duration
+	mcpStderr          io.Writer
+	mcpReconnect       *MCPReconnectPolicy
+	mcpLists           mcpListsState
+
+	historyPolicy  HistoryPolicy
+	tokenEstimator TokenEstimator
+	session        *agentSession
 
 	lastError error
 }
@@ -59,7 +80,9 @@ type AgentOption func(*Agent)
 
 // NewAgent creates a new Agent instance with the provided options.
 // It applies all the options to the Agent and returns it.
-// If any option sets an error, it returns the error instead of the Agent.
+// If any option sets an error, it returns the error instead of the Agent, and the MCP servers already started are stopped,
+// except for the chunks skipped with EmbeddingSkipAndReport: the Agent is returned with an *EmbeddingError listing them,
+// and the caller can keep the Agent when the error is an *EmbeddingError (see errors.As and FailedChunks).
 // The Agent can be configured with various options such as DMR client, parameters, tools, and memory.
 func NewAgent(options ...AgentOption) (*Agent, error) {
 
@@ -69,7 +92,19 @@ func NewAgent(options ...AgentOption) (*Agent, error) {
 		option(agent)
 	}
 	if agent.lastError != nil {
+		agent.Close()
 		return nil, agent.lastError
 	}
+	// The session is loaded once all the options are applied (it replaces the messages given with WithParams)
+	if agent.session != nil {
+		if err := agent.loadSession(); err != nil {
+			agent.Close()
+			return nil, err
+		}
+	}
+	// The chunks skipped by the RAG memory are reported with the Agent
+	if agent.skippedChunks != nil {
+		return agent, agent.skippedChunks
+	}
 	return agent, nil
 }
@@ -0,0 +1,178 @@
+package robby
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/invopop/jsonschema"
+)
+
+// jsonSchemaOf generates the JSON Schema of the type of the given value.
+// The schema is generated from the struct fields and their tags:
+//   - the `json` tag gives the property name, and a field without `omitempty` is required,
+//   - the `jsonschema` tag adds constraints (e.g. `jsonschema:"description=The first number,enum=a,enum=b"`).
+//
+// The definitions are expanded (no $ref) so the schema can be used as is in tools and response formats.
+// It returns the schema as a map, ready to be used as openai.FunctionParameters.
+func jsonSchemaOf(v any) (map[string]any, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	reflector := jsonschema.Reflector{
+		Anonymous:      true,
+		DoNotReference: true,
+		// The reflector fails to expand the other types (slices, maps...)
+		ExpandedStruct: t != nil && t.Kind() == reflect.Struct,
+	}
+	schema := reflector.Reflect(v)
+
+	jsonSchema, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var schemaMap map[string]any
+	if err := json.Unmarshal(jsonSchema, &schemaMap); err != nil {
+		return nil, err
+	}
+	delete(schemaMap, "$schema")
+	delete(schemaMap, "$id")
+	return schemaMap, nil
+}
+
+// requiredProperties returns the names of the required properties of a JSON Schema.
+func requiredProperties(schema map[string]any) []string {
+	required := []string{}
+	switch values := schema["required"].(type) {
+	case []any:
+		for _, value := range values {
+			if name, ok := value.(string); ok {
+				required = append(required, name)
+			}
+		}
+	case []string:
+		required = append(required, values...)
+	}
+	return required
+}
+
+// validateJSONSchema validates a decoded JSON value (see json.Unmarshal into any) against a JSON Schema.
+// It checks the keywords of the schemas generated by jsonSchemaOf: type, enum, required, properties,
+// additionalProperties, items, and the bounds of the numbers, strings and arrays (the other keywords are ignored).
+// It returns the validation errors, prefixed by the path of the invalid value ($ is the root).
+func validateJSONSchema(schema map[string]any, value any) []string {
+	return validateJSONValue(schema, value, "$", nil)
+}
+
+func validateJSONValue(schema map[string]any, value any, path string, errs []string) []string {
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(name string) bool { return jsonTypeMatches(name, value) }) {
+		return append(errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(allowed any) bool { return reflect.DeepEqual(allowed, value) }) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range requiredProperties(schema) {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		names := slices.Sorted(maps.Keys(value))
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]any); ok {
+				errs = validateJSONValue(property, value[name], path+"."+name, errs)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Sprintf("%s: unexpected property %q", path, name))
+				}
+			case map[string]any:
+				errs = validateJSONValue(additional, value[name], path+"."+name, errs)
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(value)) < minItems {
+			errs = append(errs, fmt.Sprintf("%s: expected at least %v items, got %d", path, minItems, len(value)))
+		}
+		if maxItems, ok := schema["maxItems"].(float64); ok && float64(len(value)) > maxItems {
+			errs = append(errs, fmt.Sprintf("%s: expected at most %v items, got %d", path, maxItems, len(value)))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				errs = validateJSONValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if minLength, ok := schema["minLength"].(float64); ok && length < minLength {
+			errs = append(errs, fmt.Sprintf("%s: expected at least %v characters, got %v", path, minLength, length))
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && length > maxLength {
+			errs = append(errs, fmt.Sprintf("%s: expected at most %v characters, got %v", path, maxLength, length))
+		}
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && value < minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v is less than the minimum %v", path, value, minimum))
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && value > maximum {
+			errs = append(errs, fmt.Sprintf("%s: %v is greater than the maximum %v", path, value, maximum))
+		}
+	}
+	return errs
+}
+
+// schemaTypes returns the types of the type keyword of a schema ("string" or ["string", "null"]).
+func schemaTypes(keyword any) []string {
+	switch keyword := keyword.(type) {
+	case string:
+		return []string{keyword}
+	case []any:
+		types := []string{}
+		for _, name := range keyword {
+			if name, ok := name.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// jsonTypeMatches returns true if the decoded JSON value has the JSON Schema type.
+func jsonTypeMatches(name string, value any) bool {
+	if name == "integer" {
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	}
+	return name == jsonTypeName(value)
+}
+
+// jsonTypeName returns the JSON Schema type of a decoded JSON value.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
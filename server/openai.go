@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openai/openai-go"
+	"github.com/sea-monkeys/robby"
+)
+
+// WithOpenAIAPI adds an OpenAI compatible API to the Server, so any OpenAI client can talk to its Agents
+// (the model of the requests is the name of the Agent):
+//
+//	GET  /v1/models             the Agents, as models
+//	GET  /v1/models/{model}     an Agent, as a model
+//	POST /v1/chat/completions   a chat completion (streamed with "stream": true)
+//
+// The messages of a completion request are answered by the Agent with CompleteMessages: after the leading system
+// messages of the Agent (its instructions), with its RAG memory and its tools (tool registry and MCP servers).
+// The conversation history of the Agent is not changed. The tools of the request are ignored, and its sampling
+// parameters (temperature, top_p, max_tokens, max_completion_tokens, seed) replace the parameters of the Agent.
+func WithOpenAIAPI() Option {
+	return func(server *Server) {
+		server.openAI = true
+	}
+}
+
+// openAIModel is a model of GET /v1/models.
+type openAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// openAICompletion is a chat completion, or a chunk of a streamed chat completion.
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int            `json:"index"`
+	Message      *openAIMessage `json:"message,omitempty"`
+	Delta        *openAIMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+// openAIUsage is the usage of a completion: the Agent does not count the tokens.
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openAIError is the body of the errors of the OpenAI API.
+type openAIError struct {
+	Error openAIErrorDetail `json:"error"`
+}
+
+type openAIErrorDetail struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+func (server *Server) listModels(w http.ResponseWriter, r *http.Request) {
+	models := make([]openAIModel, 0, len(server.names))
+	for _, name := range server.names {
+		models = append(models, server.model(name))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+func (server *Server) getModel(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("model")
+	if _, ok := server.agents[name]; !ok {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Errorf("the model %s does not exist", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, server.model(name))
+}
+
+// model returns the model of the Agent.
+func (server *Server) model(name string) openAIModel {
+	return openAIModel{ID: name, Object: "model", Created: server.created.Unix(), OwnedBy: "robby"}
+}
+
+func (server *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", err)
+		return
+	}
+	// The stream field is not a field of the parameters
+	request := struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}{}
+	params := openai.ChatCompletionNewParams{}
+	if err := json.Unmarshal(body, &request); err == nil {
+		err = json.Unmarshal(body, &params)
+	}
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if len(params.Messages) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", errors.New("the messages cannot be empty"))
+		return
+	}
+	served, ok := server.agents[request.Model]
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", fmt.Errorf("the model %s does not exist", request.Model))
+		return
+	}
+
+	served.mu.Lock()
+	defer served.mu.Unlock()
+	agent := served.agent
+	saved := agent.Params
+	defer func() { agent.Params = saved }()
+	applySamplingParams(&agent.Params, params)
+	agent.ResetConversation() // the conversation is restored with the Params
+	messages := append(agent.Messages(), params.Messages...)
+
+	completion := openAICompletion{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Created: time.Now().Unix(),
+		Model:   served.name,
+	}
+	if request.Stream {
+		served.streamCompletion(w, r, completion, messages)
+		return
+	}
+
+	answer, err := agent.CompleteMessages(messages, nil)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "server_error", "", err)
+		return
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []openAIChoice{{Message: &openAIMessage{Role: "assistant", Content: answer}, FinishReason: &stop}}
+	completion.Usage = &openAIUsage{}
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// streamCompletion sends the answer of the Agent as the chunks of a streamed chat completion (Server-Sent Events).
+// A failed completion sends an error event, and no [DONE] event.
+func (served *servedAgent) streamCompletion(w http.ResponseWriter, r *http.Request, completion openAICompletion, messages []openai.ChatCompletionMessageParamUnion) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "", errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	completion.Object = "chat.completion.chunk"
+	sendChunk := func(delta openAIMessage, finishReason *string) error {
+		completion.Choices = []openAIChoice{{Delta: &delta, FinishReason: finishReason}}
+		if err := writeData(w, completion); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	role := "assistant"
+	_, err := served.agent.CompleteMessages(messages, func(self *robby.Agent, content string, err error) error {
+		// Stop the completion when the client is gone
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		delta := openAIMessage{Role: role, Content: content}
+		role = ""
+		return sendChunk(delta, nil)
+	})
+	if err != nil {
+		_ = writeData(w, openAIError{Error: openAIErrorDetail{Message: err.Error(), Type: "server_error"}})
+		flusher.Flush()
+		return
+	}
+	stop := "stop"
+	if sendChunk(openAIMessage{Role: role}, &stop) == nil {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}
+}
+
+// applySamplingParams sets the sampling parameters of the request in the parameters of the Agent.
+func applySamplingParams(params *openai.ChatCompletionNewParams, request openai.ChatCompletionNewParams) {
+	if request.Temperature.Valid() {
+		params.Temperature = request.Temperature
+	}
+	if request.TopP.Valid() {
+		params.TopP = request.TopP
+	}
+	if request.MaxTokens.Valid() {
+		params.MaxTokens = request.MaxTokens
+	}
+	if request.MaxCompletionTokens.Valid() {
+		params.MaxCompletionTokens = request.MaxCompletionTokens
+	}
+	if request.Seed.Valid() {
+		params.Seed = request.Seed
+	}
+}
+
+// writeData sends a Server-Sent Event without name, with the value in JSON (the format of the OpenAI streams).
+func writeData(w io.Writer, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", bytes.TrimSpace(data))
+	return err
+}
+
+// writeOpenAIError sends the error in the format of the OpenAI API.
+func writeOpenAIError(w http.ResponseWriter, status int, errorType string, code string, err error) {
+	detail := openAIErrorDetail{Message: err.Error(), Type: errorType}
+	if code != "" {
+		detail.Code = &code
+	}
+	writeJSON(w, status, openAIError{Error: detail})
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/sea-monkeys/robby"
+	"github.com/sea-monkeys/robby/internal/fakedmr"
+)
+
+type sayHelloArgs struct {
+	Name string `json:"name"`
+}
+
+// newOpenAITestServer starts a Server with the OpenAI API and the agent bob (with the say_hello tool),
+// and returns an OpenAI client of the Server.
+func newOpenAITestServer(t *testing.T, fake *fakedmr.Server) (openai.Client, *robby.Agent) {
+	t.Helper()
+	registry := robby.NewToolRegistry()
+	if err := robby.RegisterTool(registry, "say_hello", "Say hello", func(args sayHelloArgs) (any, error) {
+		return "Hello " + args.Name, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := robby.NewAgent(
+		robby.WithDMRClient(context.Background(), fake.BaseURL()),
+		robby.WithParams(openai.ChatCompletionNewParams{
+			Model:       "fake-model",
+			Temperature: openai.Opt(0.5),
+			Messages:    []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are Bob")},
+		}),
+		robby.WithToolRegistry(registry),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(WithAgent("bob", bob), WithOpenAIAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return openai.NewClient(option.WithBaseURL(httpServer.URL+"/v1/"), option.WithAPIKey("none"), option.WithMaxRetries(0)), bob
+}
+
+// sayHelloReply asks for the say_hello tool, then answers with its result.
+func sayHelloReply(index int, request map[string]any) map[string]any {
+	if index == 0 {
+		return map[string]any{"role": "assistant", "tool_calls": []map[string]any{{
+			"id": "call_1", "type": "function", "function": map[string]any{"name": "say_hello", "arguments": `{"name":"Spock"}`},
+		}}}
+	}
+	messages := request["messages"].([]any)
+	return map[string]any{"role": "assistant", "content": messages[len(messages)-1].(map[string]any)["content"].(string) + " from Bob"}
+}
+
+func TestOpenAIChatCompletions(t *testing.T) {
+	fake := fakedmr.New(t, sayHelloReply)
+	client, bob := newOpenAITestServer(t, fake)
+
+	completion, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:       "bob",
+		Temperature: openai.Opt(0.1),
+		Messages:    []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Say hello to Spock")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Model != "bob" || len(completion.Choices) != 1 || completion.Choices[0].Message.Content != "Hello Spock from Bob" || completion.Choices[0].FinishReason != "stop" {
+		t.Fatalf("unexpected completion: %+v", completion)
+	}
+
+	// The agent ran its tool, with its instructions and the parameters of the request
+	requests := fake.Requests()
+	if len(requests) != 2 || requests[0]["temperature"] != 0.1 || len(requests[0]["tools"].([]any)) != 1 {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+	if first := requests[0]["messages"].([]any)[0].(map[string]any); first["content"] != "You are Bob" {
+		t.Fatalf("expected the instructions of the agent, got %v", first)
+	}
+	if len(bob.Messages()) != 1 || bob.Params.Temperature.Value != 0.5 {
+		t.Fatalf("the agent was changed: %d messages, temperature %v", len(bob.Messages()), bob.Params.Temperature.Value)
+	}
+
+	_, err = client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "alice",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	})
+	var apiError *openai.Error
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusNotFound || apiError.Code != "model_not_found" {
+		t.Fatalf("expected a model not found error, got %v", err)
+	}
+}
+
+func TestOpenAIChatCompletionsStream(t *testing.T) {
+	fake := fakedmr.New(t, sayHelloReply)
+	client, _ := newOpenAITestServer(t, fake)
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "bob",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Say hello to Spock")},
+	})
+	accumulator := openai.ChatCompletionAccumulator{}
+	chunks := 0
+	for stream.Next() {
+		accumulator.AddChunk(stream.Current())
+		chunks++
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if chunks != 5 || accumulator.Choices[0].Message.Content != "Hello Spock from Bob" || accumulator.Choices[0].FinishReason != "stop" {
+		t.Fatalf("unexpected stream: %d chunks, %+v", chunks, accumulator.Choices)
+	}
+}
+
+func TestOpenAIModels(t *testing.T) {
+	client, _ := newOpenAITestServer(t, fakedmr.New(t, sayHelloReply))
+
+	models, err := client.Models.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Data) != 1 || models.Data[0].ID != "bob" || models.Data[0].OwnedBy != "robby" {
+		t.Fatalf("unexpected models: %+v", models.Data)
+	}
+	if model, err := client.Models.Get(context.Background(), "bob"); err != nil || model.ID != "bob" {
+		t.Fatalf("unexpected model: %+v, %v", model, err)
+	}
+	if _, err := client.Models.Get(context.Background(), "alice"); err == nil {
+		t.Fatal("expected an error with an unknown model")
+	}
+}
@@ -0,0 +1,301 @@
+// Package server exposes robby Agents through an HTTP REST API.
+//
+// Every Agent is registered with a name, and served under /agents/{name}:
+//
+//	GET    /agents                   the information of all the agents
+//	GET    /agents/{name}            the information of the agent (model, tools, MCP servers, resources and prompts)
+//	POST   /agents/{name}/messages   sends a message to the agent, and returns its answer (JSON, or Server-Sent Events)
+//	GET    /agents/{name}/messages   the conversation history of the agent
+//	DELETE /agents/{name}/messages   resets the conversation of the agent (the leading system messages are kept)
+//
+// The errors are returned as a JSON object: {"error": "message"}.
+// The Agents can also be served through an OpenAI compatible API (see WithOpenAIAPI).
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/sea-monkeys/robby"
+)
+
+// Server is an http.Handler serving the REST API of its Agents.
+// The requests to an Agent are run one at a time (an Agent is not safe for concurrent use),
+// the requests to different Agents run concurrently.
+type Server struct {
+	agents map[string]*servedAgent
+	names  []string
+	mux    *http.ServeMux
+
+	openAI  bool      // serve the OpenAI compatible API (see WithOpenAIAPI)
+	created time.Time // the creation date of the models of the OpenAI API
+
+	lastError error
+}
+
+// servedAgent is an Agent of the Server, with the lock of its requests.
+type servedAgent struct {
+	name  string
+	agent *robby.Agent
+	mu    sync.Mutex
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// agentName matches the valid names of the agents (they are used in the URLs).
+var agentName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// WithAgent registers an Agent under the name (letters, digits, "_", "-" and ".").
+// New returns an error if the name is invalid or already registered.
+func WithAgent(name string, agent *robby.Agent) Option {
+	return func(server *Server) {
+		switch {
+		case !agentName.MatchString(name):
+			server.lastError = fmt.Errorf("invalid agent name %q: only letters, digits, '_', '-' and '.' are allowed", name)
+		case agent == nil:
+			server.lastError = fmt.Errorf("agent %s is nil", name)
+		case server.agents[name] != nil:
+			server.lastError = fmt.Errorf("agent %s already registered", name)
+		default:
+			server.agents[name] = &servedAgent{name: name, agent: agent}
+			server.names = append(server.names, name)
+		}
+	}
+}
+
+// New creates a Server with the Agents registered by the options (see WithAgent).
+func New(options ...Option) (*Server, error) {
+	server := &Server{agents: map[string]*servedAgent{}, mux: http.NewServeMux(), created: time.Now()}
+	for _, option := range options {
+		option(server)
+	}
+	if server.lastError != nil {
+		return nil, server.lastError
+	}
+
+	server.mux.HandleFunc("GET /agents", server.listAgents)
+	server.mux.HandleFunc("GET /agents/{name}", server.withAgent(server.getAgent))
+	server.mux.HandleFunc("POST /agents/{name}/messages", server.withAgent(server.postMessage))
+	server.mux.HandleFunc("GET /agents/{name}/messages", server.withAgent(server.getMessages))
+	server.mux.HandleFunc("DELETE /agents/{name}/messages", server.withAgent(server.resetMessages))
+	if server.openAI {
+		server.mux.HandleFunc("GET /v1/models", server.listModels)
+		server.mux.HandleFunc("GET /v1/models/{model}", server.getModel)
+		server.mux.HandleFunc("POST /v1/chat/completions", server.chatCompletions)
+	}
+	return server, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// AgentInfo is the information of an Agent returned by GET /agents/{name}.
+type AgentInfo struct {
+	Name       string           `json:"name"`
+	Model      string           `json:"model"`
+	Embeddings string           `json:"embeddings_model,omitempty"`
+	Session    string           `json:"session,omitempty"`
+	Messages   int              `json:"messages"`
+	Tools      []ToolInfo       `json:"tools"`
+	MCPServers []string         `json:"mcp_servers"`
+	Resources  []robby.Resource `json:"resources"`
+	Prompts    []robby.Prompt   `json:"prompts"`
+}
+
+// ToolInfo is a tool of an Agent.
+type ToolInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// MessageRequest is the body of POST /agents/{name}/messages.
+// The answer is streamed with Server-Sent Events if Stream is true or if the request accepts text/event-stream.
+type MessageRequest struct {
+	Message string `json:"message"`
+	Stream  bool   `json:"stream,omitempty"`
+}
+
+// MessageResponse is the response of POST /agents/{name}/messages (and the data of its "done" event when streamed).
+type MessageResponse struct {
+	Agent  string `json:"agent"`
+	Answer string `json:"answer"`
+}
+
+// MessagesResponse is the response of GET /agents/{name}/messages: the messages in the OpenAI format.
+type MessagesResponse struct {
+	Agent    string                                   `json:"agent"`
+	Session  string                                   `json:"session,omitempty"`
+	Messages []openai.ChatCompletionMessageParamUnion `json:"messages"`
+}
+
+// ErrorResponse is the body of the errors.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// withAgent runs the handler with the Agent of the {name} of the request (locked), or answers 404.
+func (server *Server) withAgent(handler func(w http.ResponseWriter, r *http.Request, served *servedAgent)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		served, ok := server.agents[r.PathValue("name")]
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("agent %s not found", r.PathValue("name")))
+			return
+		}
+		served.mu.Lock()
+		defer served.mu.Unlock()
+		handler(w, r, served)
+	}
+}
+
+func (server *Server) listAgents(w http.ResponseWriter, r *http.Request) {
+	infos := make([]AgentInfo, 0, len(server.names))
+	for _, name := range server.names {
+		served := server.agents[name]
+		served.mu.Lock()
+		infos = append(infos, served.info())
+		served.mu.Unlock()
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (server *Server) getAgent(w http.ResponseWriter, r *http.Request, served *servedAgent) {
+	writeJSON(w, http.StatusOK, served.info())
+}
+
+func (server *Server) postMessage(w http.ResponseWriter, r *http.Request, served *servedAgent) {
+	request := MessageRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if strings.TrimSpace(request.Message) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the message cannot be empty"))
+		return
+	}
+	if request.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		served.stream(w, r, request.Message)
+		return
+	}
+
+	answer, err := served.agent.Ask(request.Message)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MessageResponse{Agent: served.name, Answer: answer})
+}
+
+// stream sends the answer of the Agent with Server-Sent Events:
+// a "chunk" event for every chunk of the answer ({"content": "..."}),
+// then a "done" event with the MessageResponse, or an "error" event with the ErrorResponse.
+func (served *servedAgent) stream(w http.ResponseWriter, r *http.Request, message string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	answer, err := served.agent.AskStream(message, func(self *robby.Agent, content string, err error) error {
+		// Stop the completion when the client is gone
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := writeEvent(w, "chunk", map[string]string{"content": content}); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil {
+		_ = writeEvent(w, "error", ErrorResponse{Error: err.Error()})
+	} else {
+		_ = writeEvent(w, "done", MessageResponse{Agent: served.name, Answer: answer})
+	}
+	flusher.Flush()
+}
+
+func (server *Server) getMessages(w http.ResponseWriter, r *http.Request, served *servedAgent) {
+	writeJSON(w, http.StatusOK, MessagesResponse{
+		Agent:    served.name,
+		Session:  served.agent.SessionID(),
+		Messages: served.agent.Messages(),
+	})
+}
+
+// resetMessages removes the messages of the conversation, except the leading system messages (the instructions
+// of the Agent), and saves the session of the Agent if it has one.
+func (server *Server) resetMessages(w http.ResponseWriter, r *http.Request, served *servedAgent) {
+	served.agent.ResetConversation()
+	if served.agent.SessionID() != "" {
+		if err := served.agent.SaveSession(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// info returns the information of the Agent.
+func (served *servedAgent) info() AgentInfo {
+	agent := served.agent
+	info := AgentInfo{
+		Name:       served.name,
+		Model:      agent.Params.Model,
+		Embeddings: agent.EmbeddingParams.Model,
+		Session:    agent.SessionID(),
+		Messages:   len(agent.Params.Messages),
+		Tools:      []ToolInfo{},
+		MCPServers: agent.MCPServers(),
+		Resources:  agent.Resources,
+		Prompts:    agent.Prompts,
+	}
+	for _, tool := range agent.Tools {
+		info.Tools = append(info.Tools, ToolInfo{Name: tool.Function.Name, Description: tool.Function.Description.Value})
+	}
+	if info.MCPServers == nil {
+		info.MCPServers = []string{}
+	}
+	if info.Resources == nil {
+		info.Resources = []robby.Resource{}
+	}
+	if info.Prompts == nil {
+		info.Prompts = []robby.Prompt{}
+	}
+	return info
+}
+
+// writeJSON sends the value as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError sends the error as a JSON response (see ErrorResponse).
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeEvent sends a Server-Sent Event with the value in JSON.
+func writeEvent(w http.ResponseWriter, event string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/sea-monkeys/robby"
+	"github.com/sea-monkeys/robby/internal/fakedmr"
+)
+
+// newTestServer starts a Server with the agent bob, answering with the reply function.
+func newTestServer(t *testing.T, reply fakedmr.Reply, options ...robby.AgentOption) (*httptest.Server, *robby.Agent, *fakedmr.Server) {
+	t.Helper()
+	fake := fakedmr.New(t, reply)
+	bob, err := robby.NewAgent(append([]robby.AgentOption{
+		robby.WithDMRClient(context.Background(), fake.BaseURL()),
+		robby.WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are Bob")},
+		}),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(WithAgent("bob", bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return httpServer, bob, fake
+}
+
+// doJSON sends a request and decodes the JSON response into value.
+func doJSON(t *testing.T, method, url, body string, value any) int {
+	t.Helper()
+	request, _ := http.NewRequest(method, url, strings.NewReader(body))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if value != nil {
+		if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return response.StatusCode
+}
+
+func TestServerMessages(t *testing.T) {
+	httpServer, bob, _ := newTestServer(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Hello, I am Bob"}
+	})
+
+	answer := MessageResponse{}
+	if status := doJSON(t, "POST", httpServer.URL+"/agents/bob/messages", `{"message": "Who are you?"}`, &answer); status != http.StatusOK || answer.Answer != "Hello, I am Bob" {
+		t.Fatalf("unexpected answer: %d %+v", status, answer)
+	}
+
+	history := map[string]any{}
+	doJSON(t, "GET", httpServer.URL+"/agents/bob/messages", "", &history)
+	messages := history["messages"].([]any)
+	if len(messages) != 3 || messages[1].(map[string]any)["content"] != "Who are you?" || messages[2].(map[string]any)["role"] != "assistant" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+
+	// The reset keeps the system message
+	if status := doJSON(t, "DELETE", httpServer.URL+"/agents/bob/messages", "", nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if messages := bob.Messages(); len(messages) != 1 || messages[0].OfSystem == nil {
+		t.Fatalf("expected the system message only, got %d messages", len(messages))
+	}
+
+	errorResponse := ErrorResponse{}
+	if status := doJSON(t, "POST", httpServer.URL+"/agents/bob/messages", `{"message": ""}`, &errorResponse); status != http.StatusBadRequest || errorResponse.Error == "" {
+		t.Fatalf("expected a bad request, got %d %+v", status, errorResponse)
+	}
+	if status := doJSON(t, "GET", httpServer.URL+"/agents/alice", "", &errorResponse); status != http.StatusNotFound || errorResponse.Error != "agent alice not found" {
+		t.Fatalf("expected a not found error, got %d %+v", status, errorResponse)
+	}
+}
+
+func TestServerModelError(t *testing.T) {
+	httpServer, bob, _ := newTestServer(t, func(index int, request map[string]any) map[string]any { return nil })
+
+	errorResponse := ErrorResponse{}
+	if status := doJSON(t, "POST", httpServer.URL+"/agents/bob/messages", `{"message": "Who are you?"}`, &errorResponse); status != http.StatusBadGateway || errorResponse.Error == "" {
+		t.Fatalf("expected a bad gateway error, got %d %+v", status, errorResponse)
+	}
+	if len(bob.Messages()) != 1 {
+		t.Fatalf("expected the question to be removed, got %d messages", len(bob.Messages()))
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	httpServer, bob, _ := newTestServer(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "Hello, I am Bob"}
+	})
+
+	request, _ := http.NewRequest("POST", httpServer.URL+"/agents/bob/messages", strings.NewReader(`{"message": "Who are you?"}`))
+	request.Header.Set("Accept", "text/event-stream")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", response.Header.Get("Content-Type"))
+	}
+
+	events, chunks := []string{}, ""
+	scanner := bufio.NewScanner(response.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+			events = append(events, event)
+		case strings.HasPrefix(line, "data: ") && event == "chunk":
+			chunk := map[string]string{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk)
+			chunks += chunk["content"]
+		case strings.HasPrefix(line, "data: ") && event == "done":
+			done := MessageResponse{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &done)
+			if done.Answer != "Hello, I am Bob" {
+				t.Fatalf("unexpected answer: %+v", done)
+			}
+		}
+	}
+	if chunks != "Hello, I am Bob" || len(events) != 5 || events[4] != "done" {
+		t.Fatalf("unexpected events: %v, %q", events, chunks)
+	}
+	if len(bob.Messages()) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(bob.Messages()))
+	}
+}
+
+func TestServerAgentInfo(t *testing.T) {
+	registry := robby.NewToolRegistry()
+	type addArgs struct {
+		A float64 `json:"a"`
+		B float64 `json:"b"`
+	}
+	if err := robby.RegisterTool(registry, "add", "Add two numbers", func(args addArgs) (any, error) { return args.A + args.B, nil }); err != nil {
+		t.Fatal(err)
+	}
+	httpServer, _, _ := newTestServer(t, nil,
+		robby.WithToolRegistry(registry),
+		robby.WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+	)
+
+	infos := []AgentInfo{}
+	doJSON(t, "GET", httpServer.URL+"/agents", "", &infos)
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 agent, got %+v", infos)
+	}
+	info := AgentInfo{}
+	doJSON(t, "GET", httpServer.URL+"/agents/bob", "", &info)
+	if info.Name != "bob" || info.Model != "fake-model" || info.Embeddings != "fake-embeddings" || info.Messages != 1 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if len(info.Tools) != 1 || info.Tools[0].Name != "add" || info.Tools[0].Description != "Add two numbers" {
+		t.Fatalf("unexpected tools: %+v", info.Tools)
+	}
+	if info.Resources == nil || info.Prompts == nil || info.MCPServers == nil {
+		t.Fatalf("expected empty lists, got %+v", info)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	bob, _ := robby.NewAgent()
+	if _, err := New(WithAgent("bob", bob), WithAgent("bob", bob)); err == nil {
+		t.Fatal("expected an error with a duplicated agent")
+	}
+	if _, err := New(WithAgent("bob/alice", bob)); err == nil {
+		t.Fatal("expected an error with an invalid name")
+	}
+}
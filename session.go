@@ -0,0 +1,160 @@
+package robby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/openai/openai-go"
+)
+
+// ErrSessionNotFound is returned by SessionStore.Load and SessionStore.Delete when the session does not exist.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists the conversations (the Agent's messages) by session ID (see WithSession).
+// The implementations must be safe for concurrent use: MemorySessionStore and FileSessionStore.
+type SessionStore interface {
+	// Load returns the messages of the session, or ErrSessionNotFound.
+	Load(id string) ([]openai.ChatCompletionMessageParamUnion, error)
+	// Save replaces the messages of the session (the session is created if needed).
+	Save(id string, messages []openai.ChatCompletionMessageParamUnion) error
+	// Append adds messages at the end of the session (the session is created if needed).
+	Append(id string, messages ...openai.ChatCompletionMessageParamUnion) error
+	// List returns the IDs of the sessions, sorted.
+	List() ([]string, error)
+	// Delete removes the session, or returns ErrSessionNotFound.
+	Delete(id string) error
+}
+
+// agentSession is the session bound to an Agent with WithSession.
+type agentSession struct {
+	store SessionStore
+	id    string
+	saved []openai.ChatCompletionMessageParamUnion // a copy of the messages persisted by the last save
+}
+
+// WithSession binds the Agent to the session id of the store: the conversation is persisted after every turn
+// (before every completion request, and when Ask, AskStream and RunToolLoop add the answer of the model),
+// so it contains the messages, the tool calls and the tool results.
+// When the Agent is created, the messages of the session are loaded and replace the messages given with WithParams;
+// a new session starts with the messages given with WithParams.
+// It returns an AgentOption that can be used to configure the agent.
+func WithSession(store SessionStore, id string) AgentOption {
+	return func(agent *Agent) {
+		if err := validateSessionID(id); err != nil {
+			agent.lastError = err
+			return
+		}
+		agent.session = &agentSession{store: store, id: id}
+	}
+}
+
+// SessionID returns the ID of the session bound to the Agent (see WithSession), or "".
+func (agent *Agent) SessionID() string {
+	if agent.session == nil {
+		return ""
+	}
+	return agent.session.id
+}
+
+// SaveSession persists the Agent's messages in its session (see WithSession).
+// It is only needed after changing the messages without a completion (e.g. AddSystemMessage, ResetMessages or ResetConversation).
+func (agent *Agent) SaveSession() error {
+	if agent.session == nil {
+		return errors.New("no session")
+	}
+	return agent.persistSession()
+}
+
+// loadSession loads the messages of the session bound to the Agent, or creates the session with the Agent's messages.
+func (agent *Agent) loadSession() error {
+	messages, err := agent.session.store.Load(agent.session.id)
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		if err := agent.session.store.Save(agent.session.id, agent.Params.Messages); err != nil {
+			return fmt.Errorf("failed to create session %s: %w", agent.session.id, err)
+		}
+		agent.session.saved = slices.Clone(agent.Params.Messages)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to load session %s: %w", agent.session.id, err)
+	}
+	agent.Params.Messages = messages
+	agent.session.saved = slices.Clone(messages)
+	return nil
+}
+
+// persistSession persists the Agent's messages in its session (if any).
+// When messages have only been added since the last save, they are appended to the session;
+// otherwise (e.g. the history policy removed messages) the session is replaced.
+func (agent *Agent) persistSession() error {
+	if agent.session == nil {
+		return nil
+	}
+	session, messages := agent.session, agent.Params.Messages
+
+	var err error
+	if appended(session.saved, messages) {
+		if len(messages) == len(session.saved) {
+			return nil
+		}
+		err = session.store.Append(session.id, messages[len(session.saved):]...)
+	} else {
+		err = session.store.Save(session.id, messages)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save session %s: %w", session.id, err)
+	}
+	// A copy: the next messages must not overwrite the saved ones (e.g. after a failed Ask removed the question)
+	session.saved = slices.Clone(messages)
+	return nil
+}
+
+// appended returns true if messages starts with the saved messages (the same message values, not copies).
+func appended(saved, messages []openai.ChatCompletionMessageParamUnion) bool {
+	return len(messages) >= len(saved) && slices.Equal(saved, messages[:len(saved)])
+}
+
+// prepareMessages applies the history policy to the Agent's messages, then persists them in the session (if any).
+// It is called before every completion request.
+func (agent *Agent) prepareMessages() error {
+	if err := agent.applyHistoryPolicy(); err != nil {
+		return err
+	}
+	return agent.persistSession()
+}
+
+// validateSessionID checks that the session ID can be used as a file name
+// (letters, digits, "_", "-" and "." are allowed, but not a leading ".").
+func validateSessionID(id string) error {
+	if id == "" {
+		return errors.New("the session ID cannot be empty")
+	}
+	if id[0] == '.' {
+		return fmt.Errorf("invalid session ID %q: it cannot start with '.'", id)
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return fmt.Errorf("invalid session ID %q: only letters, digits, '_', '-' and '.' are allowed", id)
+		}
+	}
+	return nil
+}
+
+// marshalMessage encodes a message in JSON (with its "role", so it can be decoded by unmarshalMessage).
+func marshalMessage(message openai.ChatCompletionMessageParamUnion) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+// unmarshalMessage decodes a message encoded by marshalMessage.
+func unmarshalMessage(data []byte) (openai.ChatCompletionMessageParamUnion, error) {
+	var message openai.ChatCompletionMessageParamUnion
+	if err := json.Unmarshal(data, &message); err != nil {
+		return message, err
+	}
+	if messageRole(message) == "" {
+		return message, fmt.Errorf("invalid message: %s", data)
+	}
+	return message, nil
+}
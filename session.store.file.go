@@ -0,0 +1,167 @@
+package robby
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+)
+
+// sessionFileExtension is the extension of the session files of a FileSessionStore.
+const sessionFileExtension = ".jsonl"
+
+// FileSessionStore is a SessionStore that keeps every session in a JSON Lines file of a directory
+// ("<directory>/<session id>.jsonl", one JSON message per line).
+// Append adds lines at the end of the file, Save replaces the file atomically.
+type FileSessionStore struct {
+	mu        sync.Mutex
+	directory string
+}
+
+// NewFileSessionStore creates a FileSessionStore in the directory (it is created if it does not exist).
+func NewFileSessionStore(directory string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &FileSessionStore{directory: directory}, nil
+}
+
+// path returns the path of the file of the session.
+func (store *FileSessionStore) path(id string) (string, error) {
+	if err := validateSessionID(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(store.directory, id+sessionFileExtension), nil
+}
+
+// Load implements SessionStore.
+func (store *FileSessionStore) Load(id string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	path, err := store.path(id)
+	if err != nil {
+		return nil, err
+	}
+	store.mu.Lock()
+	data, err := os.ReadFile(path)
+	store.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		message, err := unmarshalMessage(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, scanner.Err()
+}
+
+// Save implements SessionStore.
+func (store *FileSessionStore) Save(id string, messages []openai.ChatCompletionMessageParamUnion) error {
+	path, err := store.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := encodeMessageLines(messages)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// The file is replaced atomically, so a crash never leaves a truncated session
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Append implements SessionStore.
+func (store *FileSessionStore) Append(id string, messages ...openai.ChatCompletionMessageParamUnion) error {
+	path, err := store.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := encodeMessageLines(messages)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// List implements SessionStore.
+func (store *FileSessionStore) List() ([]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	entries, err := os.ReadDir(store.directory)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		id, isSession := strings.CutSuffix(entry.Name(), sessionFileExtension)
+		if isSession && !entry.IsDir() && validateSessionID(id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Delete implements SessionStore.
+func (store *FileSessionStore) Delete(id string) error {
+	path, err := store.path(id)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return ErrSessionNotFound
+	} else {
+		return err
+	}
+}
+
+// encodeMessageLines encodes the messages in JSON Lines.
+func encodeMessageLines(messages []openai.ChatCompletionMessageParamUnion) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	for _, message := range messages {
+		data, err := marshalMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(data)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,100 @@
+package robby
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/openai/openai-go"
+)
+
+// MemorySessionStore is a SessionStore that keeps the sessions in memory (e.g. for tests, or a single process).
+// The messages are stored encoded in JSON, so the loaded messages do not share memory with the saved ones.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string][]json.RawMessage
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string][]json.RawMessage{}}
+}
+
+// Load implements SessionStore.
+func (store *MemorySessionStore) Load(id string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	encoded, ok := store.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(encoded))
+	for _, data := range encoded {
+		message, err := unmarshalMessage(data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Save implements SessionStore.
+func (store *MemorySessionStore) Save(id string, messages []openai.ChatCompletionMessageParamUnion) error {
+	encoded, err := encodeMessages(messages)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.sessions[id] = encoded
+	return nil
+}
+
+// Append implements SessionStore.
+func (store *MemorySessionStore) Append(id string, messages ...openai.ChatCompletionMessageParamUnion) error {
+	encoded, err := encodeMessages(messages)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.sessions[id] = append(store.sessions[id], encoded...)
+	return nil
+}
+
+// List implements SessionStore.
+func (store *MemorySessionStore) List() ([]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	ids := make([]string, 0, len(store.sessions))
+	for id := range store.sessions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// Delete implements SessionStore.
+func (store *MemorySessionStore) Delete(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.sessions[id]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(store.sessions, id)
+	return nil
+}
+
+// encodeMessages encodes every message with marshalMessage.
+func encodeMessages(messages []openai.ChatCompletionMessageParamUnion) ([]json.RawMessage, error) {
+	encoded := make([]json.RawMessage, 0, len(messages))
+	for _, message := range messages {
+		data, err := marshalMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded, nil
+}
@@ -0,0 +1,204 @@
+package robby
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// allMessageVariants returns a message of every variant of openai.ChatCompletionMessageParamUnion.
+func allMessageVariants() []openai.ChatCompletionMessageParamUnion {
+	toolCall := openai.ChatCompletionMessage{
+		Role: "assistant",
+		ToolCalls: []openai.ChatCompletionMessageToolCall{
+			{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "add", Arguments: `{"a":2,"b":3}`}},
+		},
+	}.ToParam()
+	return []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage("You are a calculator"),
+		openai.DeveloperMessage("Be concise"),
+		openai.UserMessage("Add 2 and 3"),
+		openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+			openai.TextContentPart("What is in this image?"),
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "https://example.com/image.png"}),
+		}),
+		toolCall,
+		openai.ToolMessage("5", "call_1"),
+		{OfFunction: &openai.ChatCompletionFunctionMessageParam{Name: "add", Content: openai.String("5")}},
+		openai.AssistantMessage("2 + 3 = 5"),
+	}
+}
+
+// checkSessionStore checks the operations of a SessionStore and the round-trip of every message variant.
+func checkSessionStore(t *testing.T, store SessionStore) {
+	t.Helper()
+	if _, err := store.Load("bob"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	messages := allMessageVariants()
+	if err := store.Save("bob", messages[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append("bob", messages[3:]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("alice", messages[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := json.Marshal(messages)
+	if actual, _ := json.Marshal(loaded); string(actual) != string(expected) {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+	if loaded[4].OfAssistant == nil || loaded[4].OfAssistant.ToolCalls[0].Function.Name != "add" || loaded[5].OfTool == nil {
+		t.Fatalf("the tool call and its result are not decoded: %+v", loaded[4:6])
+	}
+
+	ids, err := store.List()
+	if err != nil || len(ids) != 2 || ids[0] != "alice" || ids[1] != "bob" {
+		t.Fatalf("unexpected sessions: %v, %v", ids, err)
+	}
+	if err := store.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("alice"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	checkSessionStore(t, NewMemorySessionStore())
+}
+
+func TestFileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSessionStore(t, store)
+
+	if err := store.Save("../bob", nil); err == nil {
+		t.Fatal("expected an error for an invalid session ID")
+	}
+}
+
+func TestAgentWithSessionAfterFailedAsk(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		contents := requestContents(request)
+		return map[string]any{"role": "assistant", "content": "Answer to " + contents[len(contents)-1]}
+	})
+	fake.CompletionStatus = func(index int, request map[string]any) int {
+		if index == 0 {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	store := NewMemorySessionStore()
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are Bob")},
+		}),
+		WithSession(store, "bob"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.Ask("Q1"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := bob.Ask("Q2"); err != nil {
+		t.Fatal(err)
+	}
+	// The session holds the messages of the Agent: the failed question is replaced, not kept
+	messages, err := store.Load("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := json.Marshal(bob.Params.Messages)
+	if actual, _ := json.Marshal(messages); string(actual) != string(expected) {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+	if len(messages) != 3 || messages[1].OfUser == nil || messages[1].OfUser.Content.OfString.Value != "Q2" {
+		t.Fatalf("unexpected session messages: %s", expected)
+	}
+}
+
+func TestAgentWithSession(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		switch index {
+		case 0:
+			return map[string]any{
+				"role":       "assistant",
+				"tool_calls": []map[string]any{fakeToolCall("call_1", "add", `{"a": 2, "b": 3}`)},
+			}
+		default:
+			return map[string]any{"role": "assistant", "content": "5"}
+		}
+	})
+	store, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newAgent := func() *Agent {
+		agent, err := NewAgent(
+			fake.Option(),
+			WithParams(openai.ChatCompletionNewParams{
+				Model:    "fake-model",
+				Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("You are a calculator")},
+			}),
+			WithTools([]openai.ChatCompletionToolParam{{Function: openai.FunctionDefinitionParam{Name: "add"}}}),
+			WithSession(store, "bob"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return agent
+	}
+
+	bob := newAgent()
+	bob.AddUserMessage("Add 2 and 3")
+	if _, _, err := bob.RunToolLoop(map[string]func(any) (any, error){
+		"add": func(args any) (any, error) {
+			return args.(map[string]any)["a"].(float64) + args.(map[string]any)["b"].(float64), nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new Agent bound to the same session restores the conversation: system, user, tool call, tool result, answer
+	restored := newAgent()
+	if restored.SessionID() != "bob" || len(restored.Params.Messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(restored.Params.Messages))
+	}
+	if restored.Params.Messages[2].OfAssistant == nil || restored.Params.Messages[3].OfTool == nil {
+		t.Fatalf("unexpected messages: %+v", restored.Params.Messages)
+	}
+	expected, _ := json.Marshal(bob.Params.Messages)
+	if actual, _ := json.Marshal(restored.Params.Messages); string(actual) != string(expected) {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+
+	// The session is replaced when messages are removed
+	restored.ResetMessages()
+	if err := restored.SaveSession(); err != nil {
+		t.Fatal(err)
+	}
+	if messages, err := store.Load("bob"); err != nil || len(messages) != 0 {
+		t.Fatalf("expected an empty session, got %d messages, %v", len(messages), err)
+	}
+
+	if _, err := NewAgent(WithSession(store, "")); err == nil {
+		t.Fatal("expected an error for an empty session ID")
+	}
+}
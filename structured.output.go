@@ -0,0 +1,166 @@
+package robby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// DefaultStructuredRetries is the number of re-prompts of StructuredCompletion used with a negative maxRetries.
+const DefaultStructuredRetries = 2
+
+// StructuredOutputError is the error of StructuredCompletion and DecodeJSON when the content
+// is not a valid JSON value of the expected type.
+type StructuredOutputError struct {
+	Content  string   // the last invalid content
+	Errors   []string // its JSON or schema validation errors
+	Attempts int      // the number of completions (0 for DecodeJSON)
+}
+
+func (e *StructuredOutputError) Error() string {
+	message := "invalid JSON output"
+	if e.Attempts > 0 {
+		message = fmt.Sprintf("invalid JSON output after %d attempts", e.Attempts)
+	}
+	return fmt.Sprintf("%s: %s", message, strings.Join(e.Errors, "; "))
+}
+
+// StructuredCompletion runs a chat completion whose answer is a JSON value of type T.
+// The JSON Schema of T is generated like the arguments of RegisterTool (json and jsonschema tags),
+// and it is set as the ResponseFormat of the request (agent.Params is not changed):
+//
+//	type Movie struct {
+//		Title string `json:"title"`
+//		Year  int    `json:"year" jsonschema:"minimum=1900"`
+//	}
+//	movies, err := robby.StructuredCompletion[[]Movie](agent, 2)
+//
+// The answer is validated against the schema and decoded into T. If it is invalid, the model is asked again
+// with the validation errors, up to maxRetries times (DefaultStructuredRetries if maxRetries is negative);
+// the invalid answers and the re-prompts are only sent with these requests, not kept in the conversation history.
+// It returns a *StructuredOutputError if the last answer is still invalid.
+func StructuredCompletion[T any](agent *Agent, maxRetries int) (T, error) {
+	var zero T
+	if maxRetries < 0 {
+		maxRetries = DefaultStructuredRetries
+	}
+	schema, err := jsonSchemaOf(zero)
+	if err != nil {
+		return zero, fmt.Errorf("failed to generate the JSON schema of %T: %w", zero, err)
+	}
+	if err := agent.prepareMessages(); err != nil {
+		return zero, err
+	}
+
+	params := agent.Params
+	params.Messages = slices.Clone(agent.Params.Messages)
+	params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+			JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   schemaName(reflect.TypeOf(zero)),
+				Schema: schema,
+			},
+		},
+	}
+
+	for attempt := 1; ; attempt++ {
+		completion, err := agent.dmrClient.Chat.Completions.New(agent.ctx, params)
+		if err != nil {
+			return zero, err
+		}
+		if len(completion.Choices) == 0 {
+			return zero, errors.New("no choices found")
+		}
+		content := completion.Choices[0].Message.Content
+		value, errs := decodeJSON[T](schema, content)
+		if len(errs) == 0 {
+			return value, nil
+		}
+		if attempt > maxRetries {
+			return zero, &StructuredOutputError{Content: content, Errors: errs, Attempts: attempt}
+		}
+		params.Messages = append(params.Messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage("Your answer is not valid:\n- "+strings.Join(errs, "\n- ")+"\nAnswer again with only a JSON value that matches the schema."),
+		)
+	}
+}
+
+// DecodeJSON decodes a JSON content (e.g. the result of ChatCompletion or the output of a tool call)
+// into a value of type T, after validating it against the JSON Schema of T (see StructuredCompletion).
+// The content can be wrapped in a Markdown code block. Use map[string]any to get a map of any JSON object.
+// It returns a *StructuredOutputError if the content is invalid.
+func DecodeJSON[T any](content string) (T, error) {
+	var zero T
+	schema, err := jsonSchemaOf(zero)
+	if err != nil {
+		return zero, fmt.Errorf("failed to generate the JSON schema of %T: %w", zero, err)
+	}
+	value, errs := decodeJSON[T](schema, content)
+	if len(errs) > 0 {
+		return zero, &StructuredOutputError{Content: content, Errors: errs}
+	}
+	return value, nil
+}
+
+// DecodeToolResult decodes the output of a tool call into a value of type T (see DecodeJSON).
+// It returns the error of the tool call if it failed.
+func DecodeToolResult[T any](result ToolCallResult) (T, error) {
+	if result.Error != nil {
+		var zero T
+		return zero, result.Error
+	}
+	return DecodeJSON[T](result.Output)
+}
+
+// jsonCodeBlock matches a JSON content wrapped in a Markdown code block.
+var jsonCodeBlock = regexp.MustCompile("(?s)^```[a-zA-Z]*\\s*\n(.*?)\\s*```$")
+
+// decodeJSON validates the content against the schema and decodes it into T.
+// It returns the JSON and validation errors.
+func decodeJSON[T any](schema map[string]any, content string) (T, []string) {
+	var value T
+	content = strings.TrimSpace(content)
+	if match := jsonCodeBlock.FindStringSubmatch(content); match != nil {
+		content = match[1]
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(content), &decoded); err != nil {
+		return value, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if errs := validateJSONSchema(schema, decoded); len(errs) > 0 {
+		return value, errs
+	}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return value, []string{err.Error()}
+	}
+	return value, nil
+}
+
+// schemaNameCharacters matches the characters that are not allowed in the name of a response format.
+var schemaNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// schemaName returns the name of the response format of a type: its name ([]Movie: "Movie_list").
+func schemaName(t reflect.Type) string {
+	suffix := ""
+	for t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Pointer) {
+		if t.Kind() != reflect.Pointer {
+			suffix += "_list"
+		}
+		t = t.Elem()
+	}
+	name := ""
+	if t != nil {
+		name = schemaNameCharacters.ReplaceAllString(t.Name(), "_")
+	}
+	if name == "" {
+		name = "response"
+	}
+	return name + suffix
+}
@@ -0,0 +1,108 @@
+package robby
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+type testMovie struct {
+	Title string   `json:"title" jsonschema:"minLength=1"`
+	Year  int      `json:"year" jsonschema:"minimum=1900"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func TestStructuredCompletion(t *testing.T) {
+	answers := []string{
+		`[{"title": "The Avengers", "year": 1861}, {"year": 1965}]`,
+		"```json\n[{\"title\": \"The Avengers\", \"year\": 1961, \"tags\": [\"spy\"]}]\n```",
+	}
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": answers[index]}
+	})
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("List the movies")},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movies, err := StructuredCompletion[[]testMovie](bob, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(movies) != 1 || movies[0].Title != "The Avengers" || movies[0].Year != 1961 || !slices.Equal(movies[0].Tags, []string{"spy"}) {
+		t.Fatalf("unexpected movies: %+v", movies)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	format := requests[0]["response_format"].(map[string]any)
+	if schema := format["json_schema"].(map[string]any); format["type"] != "json_schema" || schema["name"] != "testMovie_list" || schema["schema"].(map[string]any)["type"] != "array" {
+		t.Fatalf("unexpected response format: %v", format)
+	}
+	// The model is asked again with the validation errors
+	contents := requestContents(requests[1])
+	if len(contents) != 3 || contents[1] != answers[0] {
+		t.Fatalf("unexpected messages: %q", contents)
+	}
+	for _, expected := range []string{"$[0].year: 1861 is less than the minimum 1900", `$[1]: missing required property "title"`} {
+		if !strings.Contains(contents[2], expected) {
+			t.Fatalf("expected %q in the re-prompt, got %q", expected, contents[2])
+		}
+	}
+	// The Agent is not changed
+	if len(bob.Params.Messages) != 1 || bob.Params.ResponseFormat.OfJSONSchema != nil {
+		t.Fatalf("unexpected Agent params: %d messages", len(bob.Params.Messages))
+	}
+}
+
+func TestStructuredCompletionFailure(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{"role": "assistant", "content": "The Avengers (1961)"}
+	})
+	bob, _ := NewAgent(fake.Option(), WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}))
+
+	_, err := StructuredCompletion[testMovie](bob, 1)
+	outputError := &StructuredOutputError{}
+	if !errors.As(err, &outputError) || outputError.Attempts != 2 || outputError.Content != "The Avengers (1961)" {
+		t.Fatalf("expected a StructuredOutputError after 2 attempts, got %v", err)
+	}
+	if len(fake.Requests()) != 2 || !strings.HasPrefix(outputError.Errors[0], "invalid JSON") {
+		t.Fatalf("unexpected requests or errors: %d, %q", len(fake.Requests()), outputError.Errors)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	result, err := DecodeJSON[map[string]any](`{"city": "London", "temperature": 12.5}`)
+	if err != nil || result["city"] != "London" || result["temperature"] != 12.5 {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+	if _, err := DecodeJSON[map[string]any](`["London"]`); err == nil || !strings.Contains(err.Error(), "$: expected object, got array") {
+		t.Fatalf("expected a type error, got %v", err)
+	}
+	if _, err := DecodeJSON[testMovie](`{"title": "", "year": 1961.5, "director": "Sidney Newman"}`); err == nil ||
+		!strings.Contains(err.Error(), "$.title: expected at least 1 characters") ||
+		!strings.Contains(err.Error(), "$.year: expected integer, got number") ||
+		!strings.Contains(err.Error(), `unexpected property "director"`) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	movie, err := DecodeToolResult[testMovie](ToolCallResult{Name: "find_movie", Output: `{"title": "The Avengers", "year": 1961}`})
+	if err != nil || movie.Title != "The Avengers" {
+		t.Fatalf("unexpected movie: %+v, %v", movie, err)
+	}
+	toolError := errors.New("movie not found")
+	if _, err := DecodeToolResult[testMovie](ToolCallResult{Name: "find_movie", Error: toolError}); err != toolError {
+		t.Fatalf("expected the tool error, got %v", err)
+	}
+}
@@ -1,7 +1,7 @@
 package robby
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 
@@ -11,42 +11,64 @@ import (
 // ExecuteToolCalls executes the tool calls detected by the Agent.
 // It takes a map of tool implementations where the key is the tool name and the value is a function that implements the tool.
 // Each tool function should accept a map of arguments and return a response or an error.
-// The function returns a slice of responses from the executed tools or an error if any tool call fails.
-// It also appends the tool responses to the Agent's messages for further processing
+// The function returns a slice of responses from the executed tools (the error message for a failed tool call).
+// It also appends the tool responses to the Agent's messages for further processing,
+// including the errors (see ExecuteToolCallsWithResults).
 func (agent *Agent) ExecuteToolCalls(toolsImpl map[string]func(any) (any, error)) ([]string, error) {
-	responses := []string{}
-	for _, toolCall := range agent.ToolCalls {
+	results, err := agent.ExecuteToolCallsWithResults(toolsImpl)
+	if err != nil {
+		return nil, err
+	}
+	return toolCallResultsToStrings(results), nil
+}
+
+// ExecuteToolCallsWithResults executes the tool calls detected by the Agent with the given implementations
+// (and the Agent's tool registry, see WithToolRegistry).
+// It returns one ToolCallResult per tool call (ID, name, arguments, output or error, duration), in the tool calls order.
+// A tool message is appended to the Agent's messages for every tool call: the output if the tool succeeded,
+// or the error formatted by the ToolErrorFormatter (see WithToolErrorFormatter) if the tool is not implemented,
+// if its arguments cannot be decoded or if it failed.
+// It returns an error only if there are no tool calls to execute.
+func (agent *Agent) ExecuteToolCallsWithResults(toolsImpl map[string]func(any) (any, error)) ([]ToolCallResult, error) {
+	if len(agent.ToolCalls) == 0 {
+		return nil, errors.New("no tool responses found")
+	}
+
+	// Call the tools with the arguments (concurrently if WithToolCallsConcurrency is set)
+	results := agent.reportToolCalls(agent.ToolCalls, func(ctx context.Context, idx int, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
 		// Check if the tool is implemented
-		toolFunc, ok := toolsImpl[toolCall.Function.Name]
+		toolFunc, ok := agent.lookupToolImpl(toolCall.Function.Name, toolsImpl)
 		if !ok {
-			return nil, fmt.Errorf("tool %s not implemented", toolCall.Function.Name)
+			return "", fmt.Errorf("tool %s not implemented", toolCall.Function.Name)
 		}
-
-		var args map[string]any
-		err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+		args, err := decodeToolArguments(toolCall)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
+		return callWithContext(ctx, toolCall.Function.Name, toolFunc, args)
+	})
+	return results, nil
+}
 
-		// Call the tool with the arguments
-		toolResponse, err := toolFunc(args)
-		if err != nil {
-			responses = append(responses, fmt.Sprintf("%v", err))
-		} else {
-			responses = append(responses, fmt.Sprintf("%v", toolResponse))
-			agent.Params.Messages = append(
-				agent.Params.Messages,
-				openai.ToolMessage(
-					fmt.Sprintf("%v", toolResponse),
-					toolCall.ID,
-				),
-			)
-		}
+// lookupToolImpl returns the implementation of a tool.
+// It searches the given implementations first, then the Agent's tool registry (see WithToolRegistry).
+func (agent *Agent) lookupToolImpl(name string, toolsImpl map[string]func(any) (any, error)) (func(any) (any, error), bool) {
+	if toolFunc, ok := toolsImpl[name]; ok {
+		return toolFunc, true
 	}
-	if len(responses) == 0 {
-		return nil, errors.New("no tool responses found")
+	if agent.toolRegistry != nil {
+		return agent.toolRegistry.implementation(name)
+	}
+	return nil, false
+}
+
+// toolCallResultsToStrings converts the tool call results to strings (output or error message).
+func toolCallResultsToStrings(results []ToolCallResult) []string {
+	responses := []string{}
+	for _, result := range results {
+		responses = append(responses, result.String())
 	}
-	return responses, nil
+	return responses
 }
 
 // ToolCallsToJSON converts the Agent's tool calls to a JSON string.
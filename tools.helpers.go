@@ -50,11 +50,11 @@ func convertToOpenAITools(tools []mcp_golang.ToolRetType) []openai.ChatCompletio
 	openAITools := make([]openai.ChatCompletionToolParam, len(tools))
 
 	for i, tool := range tools {
-		schema := tool.InputSchema.(map[string]any)
+		schema, _ := tool.InputSchema.(map[string]any)
 		openAITools[i] = openai.ChatCompletionToolParam{
 			Function: openai.FunctionDefinitionParam{
 				Name:        tool.Name,
-				Description: openai.String(*tool.Description),
+				Description: openai.String(stringValue(tool.Description)),
 				Parameters: openai.FunctionParameters{
 					"type":       "object",
 					"properties": schema["properties"],
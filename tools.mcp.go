@@ -1,58 +1,78 @@
 package robby
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
 
+	mcp_golang "github.com/metoro-io/mcp-golang"
 	"github.com/openai/openai-go"
 )
 
 // ExecuteMCPToolCalls executes the tool calls detected by the Agent using the MCP client.
+// If several MCP servers are attached, every tool call is sent to the server that advertised the tool.
 // It takes no additional parameters as it uses the Agent's context and MCP client.
-// It returns a slice of responses from the executed tools or an error if any tool call fails.
-// It also appends the tool responses to the Agent's messages for further processing.
+// It returns a slice of responses from the executed tools (the error message for a failed tool call).
+// It also appends the tool responses to the Agent's messages for further processing,
+// including the errors (see ExecuteMCPToolCallsWithResults).
 // This function is specifically designed to work with the MCP toolkit, which allows for tool calls
 // to be executed in a remote environment using the MCP protocol.
 // It assumes that the Agent has been initialized with an MCP client and the necessary context.
-// The function iterates over the Agent's ToolCalls, unmarshals the arguments for each tool call,
-// and then calls the tool using the MCP client. The responses are collected and returned.
-// If no tool responses are found, it returns an error.
 // It is important to ensure that the MCP client is properly configured and connected to the MCP server
 // before calling this function, as it relies on the MCP protocol for executing tool calls.
 // It is a synchronous operation that waits for the completion of each tool call.
 func (agent *Agent) ExecuteMCPToolCalls() ([]string, error) {
+	results, err := agent.ExecuteMCPToolCallsWithResults()
+	if err != nil {
+		return nil, err
+	}
+	return toolCallResultsToStrings(results), nil
+}
 
-	responses := []string{}
-	for _, toolCall := range agent.ToolCalls {
-
-		var args map[string]any
-		err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-		if err != nil {
-			return nil, err
-		}
-
-		// Call the tool with the arguments thanks to the MCP client
-		toolResponse, err := agent.mcpClient.CallTool(agent.ctx, toolCall.Function.Name, args)
-		if err != nil {
-			responses = append(responses, fmt.Sprintf("%v", err))
-		} else {
-			if toolResponse != nil && len(toolResponse.Content) > 0 && toolResponse.Content[0].TextContent != nil {
+// ExecuteMCPToolCallsWithResults executes the tool calls detected by the Agent using the MCP client.
+// It returns one ToolCallResult per tool call (ID, name, arguments, output or error, duration), in the tool calls order.
+// Every content of the MCP tool response is kept: the text contents as is,
+// the images and embedded resources as JSON objects (one content per line).
+// A tool message is appended to the Agent's messages for every tool call: the output if the tool succeeded,
+// or the error formatted by the ToolErrorFormatter (see WithToolErrorFormatter) if it failed.
+// It returns an error only if there are no tool calls to execute.
+func (agent *Agent) ExecuteMCPToolCallsWithResults() ([]ToolCallResult, error) {
+	if len(agent.ToolCalls) == 0 {
+		return nil, errors.New("no tool responses found")
+	}
+	// Apply the changes of the MCP tools lists (the errors are ignored: the routes are kept)
+	_ = agent.refreshMCPIfChanged()
 
-				agent.Params.Messages = append(
-					agent.Params.Messages,
-					openai.ToolMessage(
-						toolResponse.Content[0].TextContent.Text,
-						toolCall.ID,
-					),
-				)
-				responses = append(responses, toolResponse.Content[0].TextContent.Text)
-			}
-		}
+	// Call the tools with the arguments thanks to the MCP client
+	// (concurrently if WithToolCallsConcurrency is set)
+	results := agent.reportToolCalls(agent.ToolCalls, func(ctx context.Context, idx int, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
+		return agent.callMCPTool(ctx, toolCall)
+	})
+	return results, nil
+}
 
+// callMCPTool calls a tool with the client of the MCP server that advertised it (see routeMCPTool)
+// and returns the content of the response as text.
+func (agent *Agent) callMCPTool(ctx context.Context, toolCall openai.ChatCompletionMessageToolCall) (string, error) {
+	route, err := agent.routeMCPTool(toolCall.Function.Name)
+	if err != nil {
+		return "", err
 	}
-	if len(responses) == 0 {
-		return nil, errors.New("no tool responses found")
+	args, err := decodeToolArguments(toolCall)
+	if err != nil {
+		return "", err
+	}
+	// The call fails fast if the connection is lost (instead of waiting for the request timeout),
+	// or is retried after a reconnection (see WithMCPReconnect)
+	var toolResponse *mcp_golang.ToolResponse
+	err = route.server.call(ctx, agent, func(client *mcp_golang.Client) (err error) {
+		toolResponse, err = client.CallTool(ctx, route.tool, args)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if toolResponse == nil {
+		return "", nil
 	}
-	return responses, nil
+	return mcpContentToText(toolResponse.Content), nil
 }
@@ -0,0 +1,100 @@
+package robby
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// toolCallOutcome is the result of one tool call run by runToolCalls.
+type toolCallOutcome struct {
+	output   string
+	err      error
+	duration time.Duration
+}
+
+// runToolCalls runs the tool calls with the given function and returns the outcomes in the tool calls order.
+// The function receives the index of the tool call in the slice.
+// By default the tool calls are run one after the other.
+// When the concurrency is set with WithToolCallsConcurrency, they are run by a pool of workers,
+// and each call gets its own context derived from the Agent's context with the per-call timeout.
+func (agent *Agent) runToolCalls(
+	toolCalls []openai.ChatCompletionMessageToolCall,
+	run func(ctx context.Context, index int, toolCall openai.ChatCompletionMessageToolCall) (string, error),
+) []toolCallOutcome {
+	outcomes := make([]toolCallOutcome, len(toolCalls))
+
+	parentCtx := agent.ctx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+
+	runOne := func(index int) {
+		ctx := parentCtx
+		if agent.toolCallTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(parentCtx, agent.toolCallTimeout)
+			defer cancel()
+		}
+		start := time.Now()
+		output, err := run(ctx, index, toolCalls[index])
+		outcomes[index] = toolCallOutcome{output: output, err: err, duration: time.Since(start)}
+	}
+
+	workers := agent.toolCallsWorkers
+	if workers <= 1 || len(toolCalls) <= 1 {
+		for index := range toolCalls {
+			runOne(index)
+		}
+		return outcomes
+	}
+
+	// Bounded pool of workers
+	semaphore := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for index := range toolCalls {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			runOne(index)
+		}(index)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// callWithContext calls a tool implementation that does not support contexts,
+// and stops waiting for it when the context is done (timeout or cancellation).
+// A panic of the tool function is returned as an error (it must not crash the agent).
+// NOTE: the tool function keeps running in the background until it returns.
+func callWithContext(ctx context.Context, name string, toolFunc func(any) (any, error), args any) (string, error) {
+	type response struct {
+		value any
+		err   error
+	}
+	done := make(chan response, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- response{err: fmt.Errorf("tool %s panicked: %v", name, r)}
+			}
+		}()
+		value, err := toolFunc(args)
+		done <- response{value: value, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return "", res.err
+		}
+		return fmt.Sprintf("%v", res.value), nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool %s: %v", name, ctx.Err())
+	}
+}
@@ -0,0 +1,191 @@
+package robby
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/openai/openai-go"
+)
+
+// ToolError is the structured error sent back to the model when a tool of a ToolRegistry
+// cannot be called (unknown tool, missing or mistyped arguments, panic in the tool...).
+// Its Error() method returns the JSON representation of the error, so the model can read it.
+type ToolError struct {
+	Tool          string   `json:"tool"`
+	Message       string   `json:"error"`
+	MissingFields []string `json:"missing_fields,omitempty"`
+}
+
+func (e *ToolError) Error() string {
+	jsonError, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("tool %s: %s", e.Tool, e.Message)
+	}
+	return string(jsonError)
+}
+
+// registeredTool is a tool of a ToolRegistry:
+// its OpenAI definition, its required arguments and the function that decodes the arguments and calls the tool.
+type registeredTool struct {
+	definition openai.ChatCompletionToolParam
+	required   []string
+	call       func(arguments []byte) (any, error)
+}
+
+// ToolRegistry holds tools implemented with Go functions.
+// Each tool is registered once (see RegisterTool) and the registry provides both
+// the tool definitions for the model (Tools) and the implementations for the executors (Implementations).
+type ToolRegistry struct {
+	tools map[string]registeredTool
+	names []string
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]registeredTool),
+	}
+}
+
+// RegisterTool registers a Go function as a tool of the registry.
+// The JSON Schema of the tool parameters is generated from the argument type T (a struct):
+// the `json` tags give the argument names, the fields without `omitempty` are required,
+// and the `jsonschema` tags add descriptions and constraints, for example:
+//
+//	type AddArgs struct {
+//		A float64 `json:"a" jsonschema:"description=The first number to add"`
+//		B float64 `json:"b" jsonschema:"description=The second number to add"`
+//	}
+//
+// When the tool is called, the arguments are validated (required fields) and decoded into T.
+// Missing or mistyped arguments, and panics in the function, are returned as a *ToolError.
+// It returns an error if the name is already registered, if T is not a struct (or a pointer to a struct),
+// or if the schema cannot be generated.
+func RegisterTool[T any](registry *ToolRegistry, name string, description string, fn func(args T) (any, error)) error {
+	if _, exists := registry.tools[name]; exists {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	argsType := reflect.TypeFor[T]()
+	if argsType.Kind() == reflect.Pointer {
+		argsType = argsType.Elem()
+	}
+	if argsType.Kind() != reflect.Struct {
+		return fmt.Errorf("the arguments of tool %s must be a struct or a pointer to a struct, not %s", name, reflect.TypeFor[T]())
+	}
+
+	var zero T
+	schema, err := jsonSchemaOf(zero)
+	if err != nil {
+		return fmt.Errorf("failed to generate the schema of tool %s: %v", name, err)
+	}
+	properties, ok := schema["properties"]
+	if !ok {
+		properties = map[string]any{}
+	}
+	required := requiredProperties(schema)
+
+	tool := registeredTool{
+		definition: openai.ChatCompletionToolParam{
+			Function: openai.FunctionDefinitionParam{
+				Name:        name,
+				Description: openai.String(description),
+				Parameters: openai.FunctionParameters{
+					"type":       "object",
+					"properties": properties,
+					"required":   required,
+				},
+			},
+		},
+		required: required,
+		call: func(arguments []byte) (result any, err error) {
+			// A panic in the tool must not crash the agent
+			defer func() {
+				if r := recover(); r != nil {
+					result, err = nil, &ToolError{Tool: name, Message: fmt.Sprintf("tool panicked: %v", r)}
+				}
+			}()
+
+			var args T
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, &ToolError{Tool: name, Message: fmt.Sprintf("invalid arguments: %v", err)}
+			}
+			return fn(args)
+		},
+	}
+
+	registry.tools[name] = tool
+	registry.names = append(registry.names, name)
+	return nil
+}
+
+// Tools returns the OpenAI definitions of the registered tools, in registration order.
+func (registry *ToolRegistry) Tools() []openai.ChatCompletionToolParam {
+	tools := []openai.ChatCompletionToolParam{}
+	for _, name := range registry.names {
+		tools = append(tools, registry.tools[name].definition)
+	}
+	return tools
+}
+
+// Names returns the names of the registered tools, in registration order.
+func (registry *ToolRegistry) Names() []string {
+	return append([]string{}, registry.names...)
+}
+
+// Call calls a registered tool with its JSON arguments (as sent by the model).
+// It returns a *ToolError if the tool is unknown, if required arguments are missing
+// or if the arguments cannot be decoded.
+func (registry *ToolRegistry) Call(name string, arguments string) (any, error) {
+	tool, ok := registry.tools[name]
+	if !ok {
+		return nil, &ToolError{Tool: name, Message: "tool not implemented"}
+	}
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	var args map[string]any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return nil, &ToolError{Tool: name, Message: fmt.Sprintf("arguments are not a JSON object: %v", err)}
+	}
+	missing := []string{}
+	for _, field := range tool.required {
+		if value, ok := args[field]; !ok || value == nil {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, &ToolError{Tool: name, Message: "missing required arguments", MissingFields: missing}
+	}
+	return tool.call([]byte(arguments))
+}
+
+// Implementations returns the registered tools in the format expected by ExecuteToolCalls and RunToolLoop.
+func (registry *ToolRegistry) Implementations() map[string]func(any) (any, error) {
+	toolsImpl := make(map[string]func(any) (any, error))
+	for _, name := range registry.names {
+		toolsImpl[name], _ = registry.implementation(name)
+	}
+	return toolsImpl
+}
+
+// implementation returns the registered tool in the format expected by ExecuteToolCalls.
+// The arguments (already decoded into a map by the executor) are encoded again to be decoded into the tool argument type.
+func (registry *ToolRegistry) implementation(name string) (func(any) (any, error), bool) {
+	if _, ok := registry.tools[name]; !ok {
+		return nil, false
+	}
+	return func(args any) (any, error) {
+		arguments, err := json.Marshal(args)
+		if err != nil {
+			return nil, &ToolError{Tool: name, Message: fmt.Sprintf("invalid arguments: %v", err)}
+		}
+		if string(arguments) == "null" {
+			arguments = []byte("{}")
+		}
+		return registry.Call(name, string(arguments))
+	}, true
+}
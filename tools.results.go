@@ -0,0 +1,142 @@
+package robby
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+	"github.com/openai/openai-go"
+)
+
+// ToolCallResult is the outcome of one tool call.
+// Output is set when the tool succeeded, Error when it failed.
+// Whatever the outcome, a tool message is appended to the Agent's messages for the tool call ID,
+// so the conversation never contains a tool call without its result.
+type ToolCallResult struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Arguments string        `json:"arguments"`
+	Output    string        `json:"output,omitempty"`
+	Error     error         `json:"-"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// String returns the output of the tool call, or the error message if it failed.
+func (result ToolCallResult) String() string {
+	if result.Error != nil {
+		return fmt.Sprintf("%v", result.Error)
+	}
+	return result.Output
+}
+
+// ToolErrorFormatter formats the error of a failed tool call into the content of the tool message sent to the model.
+type ToolErrorFormatter func(result ToolCallResult) string
+
+// DefaultToolErrorFormatter is the ToolErrorFormatter used when none is set with WithToolErrorFormatter.
+// It sends "error: " followed by the error message.
+func DefaultToolErrorFormatter(result ToolCallResult) string {
+	return fmt.Sprintf("error: %v", result.Error)
+}
+
+// JSONToolErrorFormatter is a ToolErrorFormatter that sends the error as a JSON object:
+// {"tool": "name", "error": "message"}
+func JSONToolErrorFormatter(result ToolCallResult) string {
+	jsonError, _ := json.Marshal(map[string]string{
+		"tool":  result.Name,
+		"error": fmt.Sprintf("%v", result.Error),
+	})
+	return string(jsonError)
+}
+
+// reportToolCalls runs the tool calls (see runToolCalls) and appends a tool message for every tool call
+// to the Agent's messages, in the tool calls order: the output if the tool succeeded,
+// the error formatted by the Agent's ToolErrorFormatter if it failed.
+// It returns the results of the tool calls in the same order.
+func (agent *Agent) reportToolCalls(
+	toolCalls []openai.ChatCompletionMessageToolCall,
+	run func(ctx context.Context, index int, toolCall openai.ChatCompletionMessageToolCall) (string, error),
+) []ToolCallResult {
+	formatError := agent.toolErrorFormatter
+	if formatError == nil {
+		formatError = DefaultToolErrorFormatter
+	}
+
+	outcomes := agent.runToolCalls(toolCalls, run)
+
+	results := make([]ToolCallResult, len(toolCalls))
+	for idx, outcome := range outcomes {
+		result := ToolCallResult{
+			ID:        toolCalls[idx].ID,
+			Name:      toolCalls[idx].Function.Name,
+			Arguments: toolCalls[idx].Function.Arguments,
+			Output:    outcome.output,
+			Error:     outcome.err,
+			Duration:  outcome.duration,
+		}
+		content := result.Output
+		if result.Error != nil {
+			content = formatError(result)
+		}
+		agent.Params.Messages = append(
+			agent.Params.Messages,
+			openai.ToolMessage(content, result.ID),
+		)
+		results[idx] = result
+	}
+	return results
+}
+
+// decodeToolArguments decodes the JSON arguments of a tool call into a map.
+// Empty arguments are decoded as an empty map.
+func decodeToolArguments(toolCall openai.ChatCompletionMessageToolCall) (map[string]any, error) {
+	args := map[string]any{}
+	if strings.TrimSpace(toolCall.Function.Arguments) == "" {
+		return args, nil
+	}
+	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %s: %v", toolCall.Function.Name, err)
+	}
+	return args, nil
+}
+
+// mcpContentToText converts the content of an MCP tool response to text.
+// The text contents are kept as is; the images and the embedded resources
+// are converted to JSON objects so that no content is lost.
+// The parts are separated by new lines.
+func mcpContentToText(contents []*mcp_golang.Content) string {
+	parts := []string{}
+	for _, content := range contents {
+		if content == nil {
+			continue
+		}
+		switch {
+		case content.TextContent != nil:
+			parts = append(parts, content.TextContent.Text)
+		case content.ImageContent != nil:
+			jsonImage, _ := json.Marshal(map[string]string{
+				"type":     string(mcp_golang.ContentTypeImage),
+				"mimeType": content.ImageContent.MimeType,
+				"data":     content.ImageContent.Data,
+			})
+			parts = append(parts, string(jsonImage))
+		case content.EmbeddedResource != nil:
+			resource := map[string]any{"type": string(mcp_golang.ContentTypeEmbeddedResource)}
+			if text := content.EmbeddedResource.TextResourceContents; text != nil {
+				resource["uri"] = text.Uri
+				resource["mimeType"] = text.MimeType
+				resource["text"] = text.Text
+			}
+			if blob := content.EmbeddedResource.BlobResourceContents; blob != nil {
+				resource["uri"] = blob.Uri
+				resource["mimeType"] = blob.MimeType
+				resource["blob"] = blob.Blob
+			}
+			jsonResource, _ := json.Marshal(resource)
+			parts = append(parts, string(jsonResource))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,226 @@
+package robby
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestRunToolLoop(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		switch index {
+		case 0:
+			return map[string]any{
+				"role": "assistant",
+				"tool_calls": []map[string]any{
+					fakeToolCall("call_1", "add", `{"a": 2, "b": 3}`),
+					fakeToolCall("call_2", "divide", `{"a": 1, "b": 0}`),
+				},
+			}
+		default:
+			return map[string]any{"role": "assistant", "content": "2 + 3 = 5"}
+		}
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model: "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.UserMessage("Add 2 and 3, then divide 1 by 0"),
+			},
+		}),
+		WithTools([]openai.ChatCompletionToolParam{
+			{Function: openai.FunctionDefinitionParam{Name: "add"}},
+			{Function: openai.FunctionDefinitionParam{Name: "divide"}},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	answer, steps, err := bob.RunToolLoop(map[string]func(any) (any, error){
+		"add": func(args any) (any, error) {
+			return args.(map[string]any)["a"].(float64) + args.(map[string]any)["b"].(float64), nil
+		},
+		"divide": func(args any) (any, error) {
+			return nil, errors.New("division by zero")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "2 + 3 = 5" {
+		t.Fatalf("unexpected answer: %q", answer)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+	if steps[0].Results[0].Output != "5" || steps[0].Results[1].Error == nil || steps[0].Results[1].Name != "divide" {
+		t.Fatalf("unexpected results: %v", steps[0].Results)
+	}
+
+	// user + assistant tool calls + 2 tool results + final answer
+	if len(bob.Params.Messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(bob.Params.Messages))
+	}
+	// The second request must contain the tool results linked to the tool calls
+	messages := fake.Requests()[1]["messages"].([]any)
+	toolMessage := messages[2].(map[string]any)
+	if toolMessage["role"] != "tool" || toolMessage["tool_call_id"] != "call_1" {
+		t.Fatalf("unexpected tool message: %v", toolMessage)
+	}
+	errorMessage := messages[3].(map[string]any)
+	if errorMessage["tool_call_id"] != "call_2" || errorMessage["content"] != "error: division by zero" {
+		t.Fatalf("unexpected error tool message: %v", errorMessage)
+	}
+}
+
+func TestRunToolLoopMaxIterations(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{
+			"role": "assistant",
+			"tool_calls": []map[string]any{
+				fakeToolCall(fmt.Sprintf("call_%d", index), "ping", `{}`),
+			},
+		}
+	})
+	store := NewMemorySessionStore()
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("ping")},
+		}),
+		WithMaxToolLoopIterations(3),
+		WithSession(store, "bob"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, steps, err := bob.RunToolLoop(map[string]func(any) (any, error){
+		"ping": func(args any) (any, error) { return "pong", nil },
+	})
+	if !errors.Is(err, ErrMaxToolLoopIterations) {
+		t.Fatalf("expected ErrMaxToolLoopIterations, got %v", err)
+	}
+	if len(steps) != 3 || len(fake.Requests()) != 3 {
+		t.Fatalf("expected 3 steps and 3 requests, got %d and %d", len(steps), len(fake.Requests()))
+	}
+	// The tool calls and results of the last iteration are saved in the session
+	checkSessionMessages(t, store, bob)
+}
+
+// failingHistoryPolicy fails from the given call (the previous calls keep the messages).
+type failingHistoryPolicy struct {
+	calls, failAt int
+}
+
+func (policy *failingHistoryPolicy) Apply(agent *Agent, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	policy.calls++
+	if policy.calls >= policy.failAt {
+		return nil, errors.New("no more room")
+	}
+	return messages, nil
+}
+
+func TestRunToolLoopErrorSavesSession(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		return map[string]any{
+			"role":       "assistant",
+			"tool_calls": []map[string]any{fakeToolCall(fmt.Sprintf("call_%d", index), "ping", `{}`)},
+		}
+	})
+	store := NewMemorySessionStore()
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("ping")},
+		}),
+		WithHistoryPolicy(&failingHistoryPolicy{failAt: 2}),
+		WithSession(store, "bob"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second iteration fails before its completion: the tool messages of the first one are kept
+	_, steps, err := bob.RunToolLoop(map[string]func(any) (any, error){
+		"ping": func(args any) (any, error) { return "pong", nil },
+	})
+	if err == nil || len(steps) != 1 || len(bob.Params.Messages) != 3 {
+		t.Fatalf("expected an error after 1 step with 3 messages, got %v, %d steps, %d messages", err, len(steps), len(bob.Params.Messages))
+	}
+	checkSessionMessages(t, store, bob)
+}
+
+// checkSessionMessages checks that the session of the agent holds its messages.
+func checkSessionMessages(t *testing.T, store SessionStore, agent *Agent) {
+	t.Helper()
+	messages, err := store.Load(agent.SessionID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := json.Marshal(agent.Params.Messages)
+	if actual, _ := json.Marshal(messages); string(actual) != string(expected) {
+		t.Fatalf("expected the session %s, got %s", expected, actual)
+	}
+}
+
+func TestExecuteToolCallsReportsFailures(t *testing.T) {
+	bob, err := NewAgent(
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+		WithToolErrorFormatter(JSONToolErrorFormatter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "hello", Arguments: `{"name": "Bob"}`}},
+		{ID: "call_2", Function: openai.ChatCompletionMessageToolCallFunction{Name: "unknown", Arguments: `{}`}},
+		{ID: "call_3", Function: openai.ChatCompletionMessageToolCallFunction{Name: "hello", Arguments: `{"name": `}},
+		{ID: "call_4", Function: openai.ChatCompletionMessageToolCallFunction{Name: "fail", Arguments: `{}`}},
+	}
+
+	results, err := bob.ExecuteToolCallsWithResults(map[string]func(any) (any, error){
+		"hello": func(args any) (any, error) {
+			return "Hello " + args.(map[string]any)["name"].(string), nil
+		},
+		"fail": func(args any) (any, error) {
+			return nil, errors.New("boom")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	if results[0].Output != "Hello Bob" || results[0].Error != nil || results[0].ID != "call_1" {
+		t.Fatalf("unexpected first result: %+v", results[0])
+	}
+	for _, result := range results[1:] {
+		if result.Error == nil {
+			t.Fatalf("expected an error for %s", result.ID)
+		}
+	}
+
+	// Every tool call has its tool message, errors included
+	if len(bob.Params.Messages) != 4 {
+		t.Fatalf("expected 4 tool messages, got %d", len(bob.Params.Messages))
+	}
+	for i, message := range bob.Params.Messages {
+		if *message.GetToolCallID() != results[i].ID {
+			t.Fatalf("message %d: unexpected tool call id %s", i, *message.GetToolCallID())
+		}
+	}
+	if content := bob.Params.Messages[3].OfTool.Content.OfString.Value; content != `{"error":"boom","tool":"fail"}` {
+		t.Fatalf("unexpected error content: %s", content)
+	}
+}
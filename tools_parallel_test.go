@@ -0,0 +1,106 @@
+package robby
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+func TestExecuteToolCallsConcurrently(t *testing.T) {
+	bob, err := NewAgent(
+		WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}),
+		WithToolCallsConcurrency(4, 200*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		bob.ToolCalls = append(bob.ToolCalls, openai.ChatCompletionMessageToolCall{
+			ID: fmt.Sprintf("call_%d", i),
+			Function: openai.ChatCompletionMessageToolCallFunction{
+				Name:      "add",
+				Arguments: fmt.Sprintf(`{"a": %d, "b": 1}`, i),
+			},
+		})
+	}
+	bob.ToolCalls = append(bob.ToolCalls, openai.ChatCompletionMessageToolCall{
+		ID:       "call_slow",
+		Function: openai.ChatCompletionMessageToolCallFunction{Name: "slow", Arguments: `{}`},
+	})
+
+	var running, maxRunning int32
+	start := time.Now()
+	results, err := bob.ExecuteToolCalls(map[string]func(any) (any, error){
+		"add": func(args any) (any, error) {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				previous := atomic.LoadInt32(&maxRunning)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+					break
+				}
+			}
+			// The first calls are the slowest ones, to check the ordering of the results
+			a := args.(map[string]any)["a"].(float64)
+			time.Sleep(time.Duration(100-20*a) * time.Millisecond)
+			return a + args.(map[string]any)["b"].(float64), nil
+		},
+		"slow": func(args any) (any, error) {
+			time.Sleep(time.Second)
+			return "too late", nil
+		},
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed > 600*time.Millisecond {
+		t.Fatalf("tool calls were not run concurrently (%v)", elapsed)
+	}
+	if maxRunning < 2 || maxRunning > 4 {
+		t.Fatalf("unexpected number of concurrent tool calls: %d", maxRunning)
+	}
+	if len(results) != 5 || results[0] != "2" || results[3] != "5" {
+		t.Fatalf("unexpected results (order must be kept): %v", results)
+	}
+	if !strings.Contains(results[4], "deadline exceeded") {
+		t.Fatalf("expected a timeout for the slow tool, got %q", results[4])
+	}
+	// The tool messages (the timeout included) are appended in the tool calls order
+	if len(bob.Params.Messages) != 5 {
+		t.Fatalf("expected 5 tool messages, got %d", len(bob.Params.Messages))
+	}
+	for i, message := range bob.Params.Messages {
+		if id := *message.GetToolCallID(); id != bob.ToolCalls[i].ID {
+			t.Fatalf("message %d: unexpected tool call id %s", i, id)
+		}
+	}
+}
+
+func TestExecuteToolCallsRecoversPanic(t *testing.T) {
+	bob, err := NewAgent(WithParams(openai.ChatCompletionNewParams{Model: "fake-model"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.ToolCalls = []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunction{Name: "crash", Arguments: `{}`}},
+		{ID: "call_2", Function: openai.ChatCompletionMessageToolCallFunction{Name: "hello", Arguments: `{}`}},
+	}
+
+	results, err := bob.ExecuteToolCalls(map[string]func(any) (any, error){
+		"crash": func(args any) (any, error) { panic("out of bananas") },
+		"hello": func(args any) (any, error) { return "Hello", nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The panic is reported to the model, and the other tool calls are executed
+	if len(results) != 2 || !strings.Contains(results[0], "tool crash panicked: out of bananas") || results[1] != "Hello" {
+		t.Fatalf("unexpected results: %q", results)
+	}
+}
@@ -0,0 +1,133 @@
+package robby
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+type addArgs struct {
+	A float64 `json:"a" jsonschema:"description=The first number to add"`
+	B float64 `json:"b" jsonschema:"description=The second number to add"`
+}
+
+type greetArgs struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty" jsonschema:"enum=en,enum=fr"`
+}
+
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	if err := RegisterTool(registry, "add", "add two numbers", func(args addArgs) (any, error) {
+		return args.A + args.B, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTool(registry, "greet", "greet somebody", func(args greetArgs) (any, error) {
+		if args.Language == "fr" {
+			return "Bonjour " + args.Name, nil
+		}
+		return "Hello " + args.Name, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTool(registry, "add", "again", func(args addArgs) (any, error) { return nil, nil }); err == nil {
+		t.Fatal("expected an error when registering the same tool twice")
+	}
+
+	tools := registry.Tools()
+	if len(tools) != 2 || tools[0].Function.Name != "add" || tools[1].Function.Name != "greet" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+	properties := tools[0].Function.Parameters["properties"].(map[string]any)
+	if properties["a"].(map[string]any)["type"] != "number" {
+		t.Fatalf("unexpected schema: %+v", properties)
+	}
+	if required := tools[1].Function.Parameters["required"].([]string); len(required) != 1 || required[0] != "name" {
+		t.Fatalf("unexpected required fields: %v", required)
+	}
+
+	result, err := registry.Call("add", `{"a": 2, "b": 40}`)
+	if err != nil || result.(float64) != 42 {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+
+	var toolError *ToolError
+	_, err = registry.Call("add", `{"a": 2}`)
+	if !errors.As(err, &toolError) || len(toolError.MissingFields) != 1 || toolError.MissingFields[0] != "b" {
+		t.Fatalf("expected a missing field error, got %v", err)
+	}
+	_, err = registry.Call("add", `{"a": "two", "b": 40}`)
+	if !errors.As(err, &toolError) {
+		t.Fatalf("expected a tool error for a mistyped argument, got %v", err)
+	}
+}
+
+func TestRegisterToolArgumentsType(t *testing.T) {
+	registry := NewToolRegistry()
+	if err := RegisterTool(registry, "greet", "greet somebody", func(args *greetArgs) (any, error) {
+		return "Hello " + args.Name, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := registry.Call("greet", `{"name": "Spock"}`); err != nil || result != "Hello Spock" {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+
+	if err := RegisterTool(registry, "any", "any arguments", func(args any) (any, error) { return nil, nil }); err == nil {
+		t.Fatal("expected an error with interface arguments")
+	}
+	if err := RegisterTool(registry, "names", "a list of names", func(args []string) (any, error) { return nil, nil }); err == nil {
+		t.Fatal("expected an error with slice arguments")
+	}
+	if err := RegisterTool(registry, "options", "options", func(args map[string]any) (any, error) { return nil, nil }); err == nil {
+		t.Fatal("expected an error with map arguments")
+	}
+}
+
+func TestToolRegistryWithAgent(t *testing.T) {
+	fake := newFakeDMR(t, func(index int, request map[string]any) map[string]any {
+		if index == 0 {
+			return map[string]any{
+				"role": "assistant",
+				"tool_calls": []map[string]any{
+					fakeToolCall("call_1", "greet", `{"name": "Bob", "language": "fr"}`),
+					fakeToolCall("call_2", "greet", `{"language": "en"}`),
+				},
+			}
+		}
+		return map[string]any{"role": "assistant", "content": "done"}
+	})
+
+	registry := NewToolRegistry()
+	_ = RegisterTool(registry, "greet", "greet somebody", func(args greetArgs) (any, error) {
+		return "Bonjour " + args.Name, nil
+	})
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithParams(openai.ChatCompletionNewParams{
+			Model:    "fake-model",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Greet Bob")},
+		}),
+		WithToolRegistry(registry),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bob.Tools) != 1 {
+		t.Fatalf("expected the registry tools in the agent, got %d", len(bob.Tools))
+	}
+
+	_, steps, err := bob.RunToolLoop(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[0].Results[0].Output != "Bonjour Bob" {
+		t.Fatalf("unexpected result: %q", steps[0].Results[0])
+	}
+	if steps[0].Results[1].String() != `{"tool":"greet","error":"missing required arguments","missing_fields":["name"]}` {
+		t.Fatalf("unexpected error result: %q", steps[0].Results[1])
+	}
+}
@@ -0,0 +1,307 @@
+package robby
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// mcpSessionIDHeader is the header used by the Streamable HTTP transport to keep the MCP session.
+const mcpSessionIDHeader = "Mcp-Session-Id"
+
+// streamableHTTPTransport is a client transport for the MCP Streamable HTTP protocol.
+// Every JSON-RPC message is sent with a POST request to the MCP endpoint,
+// and the server answers either with a JSON body or with a stream of Server-Sent Events.
+// The session ID given by the server is sent back with every request.
+type streamableHTTPTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu             sync.RWMutex
+	sessionID      string
+	messageHandler func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+	errorHandler   func(error)
+	closeHandler   func()
+}
+
+// newStreamableHTTPTransport creates a Streamable HTTP client transport for the given MCP endpoint URL.
+func newStreamableHTTPTransport(url string, headers map[string]string) *streamableHTTPTransport {
+	return &streamableHTTPTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+// Start implements transport.Transport.
+// There is nothing to start: the requests are sent on demand.
+func (t *streamableHTTPTransport) Start(ctx context.Context) error {
+	return nil
+}
+
+// Send implements transport.Transport.
+// It posts the message to the MCP endpoint and dispatches the messages of the response (JSON or SSE) to the message handler.
+func (t *streamableHTTPTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	jsonMessage, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(jsonMessage))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(request)
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer response.Body.Close()
+
+	// The server answers 404 to the requests of a session it has ended (e.g. after a restart):
+	// the connection is closed, a new session must be initialized
+	if response.StatusCode == http.StatusNotFound && request.Header.Get(mcpSessionIDHeader) != "" {
+		t.mu.Lock()
+		t.sessionID = ""
+		closeHandler := t.closeHandler
+		t.mu.Unlock()
+		if closeHandler != nil {
+			closeHandler()
+		}
+		return fmt.Errorf("MCP session expired (status: %d)", response.StatusCode)
+	}
+
+	if sessionID := response.Header.Get(mcpSessionIDHeader); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("server returned error: %s (status: %d)", strings.TrimSpace(string(body)), response.StatusCode)
+	}
+	// Notifications and responses are acknowledged without body
+	if response.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	if strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		return readSSEEvents(response.Body, func(event string, data string) error {
+			if event != "" && event != "message" {
+				return nil
+			}
+			t.dispatch(ctx, []byte(data))
+			return nil
+		})
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		t.dispatch(ctx, body)
+	}
+	return nil
+}
+
+// Close implements transport.Transport.
+// It ends the MCP session on the server (if any) and calls the close handler.
+func (t *streamableHTTPTransport) Close() error {
+	t.mu.RLock()
+	sessionID := t.sessionID
+	closeHandler := t.closeHandler
+	t.mu.RUnlock()
+
+	var err error
+	if sessionID != "" {
+		request, errRequest := http.NewRequest(http.MethodDelete, t.url, nil)
+		if errRequest == nil {
+			t.setHeaders(request)
+			response, errDelete := t.client.Do(request)
+			if errDelete != nil {
+				err = errDelete
+			} else {
+				response.Body.Close()
+			}
+		}
+	}
+	if closeHandler != nil {
+		closeHandler()
+	}
+	return err
+}
+
+// SetCloseHandler implements transport.Transport.
+func (t *streamableHTTPTransport) SetCloseHandler(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeHandler = handler
+}
+
+// SetErrorHandler implements transport.Transport.
+func (t *streamableHTTPTransport) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorHandler = handler
+}
+
+// SetMessageHandler implements transport.Transport.
+func (t *streamableHTTPTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messageHandler = handler
+}
+
+// setHeaders adds the custom headers and the session ID to the request.
+func (t *streamableHTTPTransport) setHeaders(request *http.Request) {
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.sessionID != "" {
+		request.Header.Set(mcpSessionIDHeader, t.sessionID)
+	}
+}
+
+// dispatch decodes the JSON-RPC message(s) and sends them to the message handler.
+func (t *streamableHTTPTransport) dispatch(ctx context.Context, data []byte) {
+	t.mu.RLock()
+	messageHandler := t.messageHandler
+	errorHandler := t.errorHandler
+	t.mu.RUnlock()
+	dispatchJSONRPCMessages(ctx, data, messageHandler, errorHandler)
+}
+
+// dispatchJSONRPCMessages decodes a JSON-RPC message (or a batch of messages)
+// and sends the result to the message handler, or the decoding error to the error handler.
+func dispatchJSONRPCMessages(
+	ctx context.Context,
+	data []byte,
+	messageHandler func(ctx context.Context, message *transport.BaseJsonRpcMessage),
+	errorHandler func(error),
+) {
+	data = bytes.TrimSpace(data)
+	rawMessages := []json.RawMessage{}
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &rawMessages); err != nil {
+			if errorHandler != nil {
+				errorHandler(fmt.Errorf("invalid JSON-RPC batch: %w", err))
+			}
+			return
+		}
+	} else {
+		rawMessages = append(rawMessages, data)
+	}
+
+	for _, rawMessage := range rawMessages {
+		message, err := parseJSONRPCMessage(rawMessage)
+		if err != nil {
+			if errorHandler != nil {
+				errorHandler(err)
+			}
+			continue
+		}
+		if messageHandler != nil {
+			messageHandler(ctx, message)
+		}
+	}
+}
+
+// parseJSONRPCMessage decodes a JSON-RPC message (request, notification, response or error).
+func parseJSONRPCMessage(data []byte) (*transport.BaseJsonRpcMessage, error) {
+	probe := struct {
+		Id     *json.RawMessage `json:"id"`
+		Method *string          `json:"method"`
+		Result *json.RawMessage `json:"result"`
+		Error  *json.RawMessage `json:"error"`
+	}{}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC message: %w", err)
+	}
+
+	switch {
+	case probe.Method != nil && probe.Id != nil:
+		var request transport.BaseJSONRPCRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			return nil, err
+		}
+		return transport.NewBaseMessageRequest(&request), nil
+	case probe.Method != nil:
+		var notification transport.BaseJSONRPCNotification
+		if err := json.Unmarshal(data, &notification); err != nil {
+			return nil, err
+		}
+		// The notification unmarshaller of mcp-golang does not keep the parameters
+		params := struct {
+			Params json.RawMessage `json:"params"`
+		}{}
+		_ = json.Unmarshal(data, &params)
+		notification.Params = params.Params
+		return transport.NewBaseMessageNotification(&notification), nil
+	case probe.Error != nil:
+		var errorResponse transport.BaseJSONRPCError
+		if err := json.Unmarshal(data, &errorResponse); err != nil {
+			return nil, err
+		}
+		return transport.NewBaseMessageError(&errorResponse), nil
+	case probe.Result != nil:
+		var response transport.BaseJSONRPCResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, err
+		}
+		return transport.NewBaseMessageResponse(&response), nil
+	}
+	return nil, errors.New("unknown JSON-RPC message: " + string(data))
+}
+
+// readSSEEvents reads a stream of Server-Sent Events and calls the handler for every event
+// with the event name (empty for the default event) and the data (multi-line data are joined with new lines).
+// It stops at the end of the stream or when the handler returns an error.
+func readSSEEvents(reader io.Reader, handler func(event string, data string) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	event := ""
+	data := []string{}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := handler(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data = []string{}
+		case strings.HasPrefix(line, ":"):
+			// comment (keep-alive)
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if len(data) > 0 {
+		if err := handler(event, strings.Join(data, "\n")); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,218 @@
+package robby
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// sseEndpointTimeout is the maximum time to wait for the "endpoint" event of an MCP SSE server.
+const sseEndpointTimeout = 30 * time.Second
+
+// sseTransport is a client transport for the MCP HTTP+SSE protocol.
+// The client opens a Server-Sent Events stream (GET) on the SSE URL;
+// the server first sends an "endpoint" event with the URL where the client posts its messages,
+// then it sends every response and notification as a "message" event on the stream.
+type sseTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu             sync.RWMutex
+	endpoint       string
+	cancel         context.CancelFunc
+	messageHandler func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+	errorHandler   func(error)
+	closeHandler   func()
+}
+
+// newSSETransport creates an HTTP+SSE client transport for the given SSE URL.
+func newSSETransport(url string, headers map[string]string) *sseTransport {
+	return &sseTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+// Start implements transport.Transport.
+// It opens the SSE stream and waits for the endpoint where the messages are posted.
+func (t *sseTransport) Start(ctx context.Context) error {
+	streamCtx, cancel := context.WithCancel(context.Background())
+
+	request, err := http.NewRequestWithContext(streamCtx, http.MethodGet, t.url, nil)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to connect to the SSE server: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		cancel()
+		return fmt.Errorf("server returned error: %s (status: %d)", strings.TrimSpace(string(body)), response.StatusCode)
+	}
+
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+
+	endpointReady := make(chan string, 1)
+	go func() {
+		defer response.Body.Close()
+		err := readSSEEvents(response.Body, func(event string, data string) error {
+			switch event {
+			case "endpoint":
+				endpoint, err := resolveSSEEndpoint(t.url, data)
+				if err != nil {
+					return err
+				}
+				t.mu.Lock()
+				t.endpoint = endpoint
+				t.mu.Unlock()
+				select {
+				case endpointReady <- endpoint:
+				default:
+				}
+			case "", "message":
+				t.mu.RLock()
+				messageHandler := t.messageHandler
+				errorHandler := t.errorHandler
+				t.mu.RUnlock()
+				dispatchJSONRPCMessages(streamCtx, []byte(data), messageHandler, errorHandler)
+			}
+			return nil
+		})
+
+		// The stream is closed: by Close() or by the server
+		if streamCtx.Err() == nil {
+			t.mu.RLock()
+			errorHandler := t.errorHandler
+			closeHandler := t.closeHandler
+			t.mu.RUnlock()
+			if err != nil && errorHandler != nil {
+				errorHandler(err)
+			}
+			if closeHandler != nil {
+				closeHandler()
+			}
+		}
+	}()
+
+	select {
+	case <-endpointReady:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	case <-time.After(sseEndpointTimeout):
+		cancel()
+		return errors.New("no endpoint event received from the SSE server")
+	}
+}
+
+// Send implements transport.Transport.
+// It posts the message to the endpoint given by the server; the answer comes back on the SSE stream.
+func (t *sseTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	t.mu.RLock()
+	endpoint := t.endpoint
+	t.mu.RUnlock()
+	if endpoint == "" {
+		return errors.New("SSE transport not started")
+	}
+
+	jsonMessage, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(jsonMessage))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("server returned error: %s (status: %d)", strings.TrimSpace(string(body)), response.StatusCode)
+	}
+	return nil
+}
+
+// Close implements transport.Transport.
+// It closes the SSE stream and calls the close handler.
+func (t *sseTransport) Close() error {
+	t.mu.Lock()
+	cancel := t.cancel
+	t.cancel = nil
+	closeHandler := t.closeHandler
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	if closeHandler != nil {
+		closeHandler()
+	}
+	return nil
+}
+
+// SetCloseHandler implements transport.Transport.
+func (t *sseTransport) SetCloseHandler(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeHandler = handler
+}
+
+// SetErrorHandler implements transport.Transport.
+func (t *sseTransport) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorHandler = handler
+}
+
+// SetMessageHandler implements transport.Transport.
+func (t *sseTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messageHandler = handler
+}
+
+// resolveSSEEndpoint resolves the endpoint sent by the server (often a relative path) against the SSE URL.
+func resolveSSEEndpoint(sseURL string, endpoint string) (string, error) {
+	base, err := url.Parse(sseURL)
+	if err != nil {
+		return "", err
+	}
+	reference, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	return base.ResolveReference(reference).String(), nil
+}
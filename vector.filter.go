@@ -0,0 +1,232 @@
+package robby
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Filter selects vector records by their metadata (see VectorStore.SearchSimilarities, DeleteWhere and UpdateWhere).
+// Build filters with Eq, Ne, In, Contains, Exists, Gt, Gte, Lt, Lte, And, Or and Not, or with a FilterFunc.
+//
+// The values are compared after normalization, so the metadata of the records read from a file or a database match
+// the values given to the filters: the numbers are compared as float64, and the times (time.Time, or strings
+// in the RFC 3339 format) as times.
+type Filter interface {
+	Match(metadata map[string]any) bool
+}
+
+// FilterFunc is a function that implements Filter.
+type FilterFunc func(metadata map[string]any) bool
+
+// Match implements Filter.
+func (f FilterFunc) Match(metadata map[string]any) bool {
+	return f(metadata)
+}
+
+// Eq matches the records whose metadata key equals value.
+func Eq(key string, value any) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		actual, ok := metadata[key]
+		return ok && equalValues(actual, value)
+	})
+}
+
+// Ne matches the records whose metadata key does not exist or is different from value.
+func Ne(key string, value any) Filter {
+	return Not(Eq(key, value))
+}
+
+// In matches the records whose metadata key equals one of the values.
+func In(key string, values ...any) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		actual, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if equalValues(actual, value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Contains matches the records whose metadata key is a list (e.g. tags) containing value.
+func Contains(key string, value any) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		list := reflect.ValueOf(metadata[key])
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return false
+		}
+		for i := range list.Len() {
+			if equalValues(list.Index(i).Interface(), value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Exists matches the records that have the metadata key.
+func Exists(key string) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		_, ok := metadata[key]
+		return ok
+	})
+}
+
+// Gt matches the records whose metadata key is greater than value (numbers, strings or times).
+func Gt(key string, value any) Filter {
+	return compareFilter(key, value, func(c int) bool { return c > 0 })
+}
+
+// Gte matches the records whose metadata key is greater than or equal to value (numbers, strings or times).
+func Gte(key string, value any) Filter {
+	return compareFilter(key, value, func(c int) bool { return c >= 0 })
+}
+
+// Lt matches the records whose metadata key is less than value (numbers, strings or times).
+func Lt(key string, value any) Filter {
+	return compareFilter(key, value, func(c int) bool { return c < 0 })
+}
+
+// Lte matches the records whose metadata key is less than or equal to value (numbers, strings or times).
+func Lte(key string, value any) Filter {
+	return compareFilter(key, value, func(c int) bool { return c <= 0 })
+}
+
+// And matches the records matched by all the filters (all the records if there are no filters).
+func And(filters ...Filter) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		for _, filter := range filters {
+			if !filter.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or matches the records matched by at least one of the filters.
+func Or(filters ...Filter) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		for _, filter := range filters {
+			if filter.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches the records not matched by the filter.
+func Not(filter Filter) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		return !filter.Match(metadata)
+	})
+}
+
+// errNoFilter is returned by DeleteWhere and UpdateWhere without a filter (use And() to select all the records).
+var errNoFilter = errors.New("a filter is required: use And() to select all the records")
+
+// matchFilters returns true if the record is matched by all the filters.
+func matchFilters(record VectorRecord, filters []Filter) bool {
+	for _, filter := range filters {
+		if filter != nil && !filter.Match(record.Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// updateMetadata returns the metadata with the values of update (a nil value removes the key).
+func updateMetadata(metadata map[string]any, update map[string]any) map[string]any {
+	updated := make(map[string]any, len(metadata)+len(update))
+	for key, value := range metadata {
+		updated[key] = value
+	}
+	for key, value := range update {
+		if value == nil {
+			delete(updated, key)
+		} else {
+			updated[key] = value
+		}
+	}
+	return updated
+}
+
+// compareFilter matches the records whose metadata key can be compared with value, when accept(comparison) is true.
+func compareFilter(key string, value any, accept func(comparison int) bool) Filter {
+	return FilterFunc(func(metadata map[string]any) bool {
+		actual, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		comparison, comparable := compareValues(actual, value)
+		return comparable && accept(comparison)
+	})
+}
+
+// equalValues returns true if the values are equal after normalization (see Filter).
+func equalValues(a, b any) bool {
+	if comparison, comparable := compareValues(a, b); comparable {
+		return comparison == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues compares two numbers, two times or two strings (see Filter).
+// It returns false if the values cannot be compared.
+func compareValues(a, b any) (int, bool) {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			return cmp.Compare(x, y), true
+		}
+		return 0, false
+	}
+	_, aIsTime := a.(time.Time)
+	_, bIsTime := b.(time.Time)
+	if aIsTime || bIsTime {
+		x, okX := toTime(a)
+		y, okY := toTime(b)
+		if !okX || !okY {
+			return 0, false
+		}
+		return x.Compare(y), true
+	}
+	x, okX := a.(string)
+	y, okY := b.(string)
+	if !okX || !okY {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// toFloat converts a number to float64.
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case json.Number:
+		f, err := number.Float64()
+		return f, err == nil
+	case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return reflect.ValueOf(number).Convert(reflect.TypeFor[float64]()).Float(), true
+	}
+	return 0, false
+}
+
+// toTime converts a time.Time, or a string in the RFC 3339 format, to a time.
+func toTime(value any) (time.Time, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,172 @@
+package robby
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBoltVectorStoreBucket is the bucket of the records of a BoltVectorStore.
+const DefaultBoltVectorStoreBucket = "vectors"
+
+// BoltVectorStore is a durable VectorStore in a bbolt database file (an embedded key/value store, no server needed).
+// Every record is stored in JSON in a bucket, with its ID as the key.
+// The searches read all the records of the bucket.
+type BoltVectorStore struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// NewBoltVectorStore opens (or creates) the bbolt database file and the bucket of the records.
+// The store must be closed with Close (the file is locked while it is open).
+func NewBoltVectorStore(path string, bucket string) (*BoltVectorStore, error) {
+	if bucket == "" {
+		bucket = DefaultBoltVectorStoreBucket
+	}
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the vector store %s: %w", path, err)
+	}
+	store := &BoltVectorStore{db: db, bucket: []byte(bucket)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(store.bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the bucket %s: %w", bucket, err)
+	}
+	return store, nil
+}
+
+// Close closes the database file.
+func (store *BoltVectorStore) Close() error {
+	return store.db.Close()
+}
+
+// Save implements VectorStore.
+func (store *BoltVectorStore) Save(vectorRecord VectorRecord) (VectorRecord, error) {
+	if vectorRecord.Id == "" {
+		vectorRecord.Id = uuid.New().String()
+	}
+	data, err := json.Marshal(toStoredVectorRecord(vectorRecord))
+	if err != nil {
+		return vectorRecord, err
+	}
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(store.bucket).Put([]byte(vectorRecord.Id), data)
+	})
+	return vectorRecord, err
+}
+
+// Delete implements VectorStore.
+func (store *BoltVectorStore) Delete(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrVectorRecordNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// GetAll implements VectorStore.
+func (store *BoltVectorStore) GetAll() ([]VectorRecord, error) {
+	var records []VectorRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(store.bucket).ForEach(func(key, value []byte) error {
+			stored := storedVectorRecord{}
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return fmt.Errorf("invalid vector record %s: %w", key, err)
+			}
+			records = append(records, stored.vectorRecord())
+			return nil
+		})
+	})
+	return records, err
+}
+
+// SearchSimilarities implements VectorStore.
+func (store *BoltVectorStore) SearchSimilarities(embeddingFromQuestion VectorRecord, limit float64, filters ...Filter) ([]VectorRecord, error) {
+	records, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return searchSimilarities(records, embeddingFromQuestion, limit, filters), nil
+}
+
+// SearchTopNSimilarities implements VectorStore.
+func (store *BoltVectorStore) SearchTopNSimilarities(embeddingFromQuestion VectorRecord, limit float64, max int, filters ...Filter) ([]VectorRecord, error) {
+	records, err := store.SearchSimilarities(embeddingFromQuestion, limit, filters...)
+	if err != nil {
+		return nil, err
+	}
+	return getTopNVectorRecords(records, max), nil
+}
+
+// DeleteWhere implements VectorStore. The records are removed in a single transaction.
+func (store *BoltVectorStore) DeleteWhere(filter Filter) (int, error) {
+	return store.updateWhere(filter, func(record VectorRecord) (VectorRecord, bool) {
+		return record, false
+	})
+}
+
+// UpdateWhere implements VectorStore. The records are updated in a single transaction.
+func (store *BoltVectorStore) UpdateWhere(filter Filter, metadata map[string]any) (int, error) {
+	return store.updateWhere(filter, func(record VectorRecord) (VectorRecord, bool) {
+		record.Metadata = updateMetadata(record.Metadata, metadata)
+		return record, true
+	})
+}
+
+// updateWhere replaces the records matched by the filter by the records returned by update, or removes them
+// if update returns false. It returns the number of matched records.
+func (store *BoltVectorStore) updateWhere(filter Filter, update func(record VectorRecord) (VectorRecord, bool)) (int, error) {
+	if filter == nil {
+		return 0, errNoFilter
+	}
+	matched := 0
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(store.bucket)
+		// The bucket cannot be changed while it is iterated: the changes are applied after the iteration
+		changes := map[string]*VectorRecord{}
+		err := bucket.ForEach(func(key, value []byte) error {
+			stored := storedVectorRecord{}
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return fmt.Errorf("invalid vector record %s: %w", key, err)
+			}
+			if filter.Match(stored.Metadata) {
+				if record, keep := update(stored.vectorRecord()); keep {
+					changes[string(key)] = &record
+				} else {
+					changes[string(key)] = nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, record := range changes {
+			if record == nil {
+				err = bucket.Delete([]byte(key))
+			} else {
+				var data []byte
+				if data, err = json.Marshal(toStoredVectorRecord(*record)); err == nil {
+					err = bucket.Put([]byte(key), data)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		matched = len(changes)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return matched, nil
+}
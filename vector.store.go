@@ -0,0 +1,85 @@
+package robby
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVectorRecordNotFound is returned by VectorStore.Delete when the record does not exist.
+var ErrVectorRecordNotFound = errors.New("vector record not found")
+
+// VectorStore stores the vector records (the embeddings of the chunks) of the RAG memory of an Agent
+// (see WithVectorStore and WithRAGMemory).
+// The implementations: MemoryVectorStore, BoltVectorStore (a durable embedded database) and RedisVectorStore.
+type VectorStore interface {
+	// Save saves a vector record (a new ID is generated if the record has no ID); an existing record is overwritten.
+	Save(vectorRecord VectorRecord) (VectorRecord, error)
+	// Delete removes the record with the given ID, or returns ErrVectorRecordNotFound.
+	Delete(id string) error
+	// GetAll returns all the records.
+	GetAll() ([]VectorRecord, error)
+	// SearchSimilarities returns the records with a cosine similarity greater than or equal to limit (CosineSimilarity is set),
+	// and matched by the filters.
+	SearchSimilarities(embeddingFromQuestion VectorRecord, limit float64, filters ...Filter) ([]VectorRecord, error)
+	// SearchTopNSimilarities returns the max most similar records with a cosine similarity greater than or equal to limit,
+	// and matched by the filters.
+	SearchTopNSimilarities(embeddingFromQuestion VectorRecord, limit float64, max int, filters ...Filter) ([]VectorRecord, error)
+	// DeleteWhere removes the records matched by the filter, and returns the number of removed records.
+	DeleteWhere(filter Filter) (int, error)
+	// UpdateWhere sets the metadata values of the records matched by the filter (a nil value removes the key),
+	// and returns the number of updated records.
+	UpdateWhere(filter Filter, metadata map[string]any) (int, error)
+}
+
+// WithVectorStore sets the vector store of the Agent's RAG memory (default: a MemoryVectorStore created by WithRAGMemory).
+// It must be used before WithRAGMemory, which saves the embeddings of the chunks in this store.
+// The Agent does not close the store.
+// It returns an AgentOption that can be used to configure the agent.
+func WithVectorStore(store VectorStore) AgentOption {
+	return func(agent *Agent) {
+		agent.Store = store
+	}
+}
+
+// storedVectorRecord is a vector record as stored in the files and the databases (without its similarity).
+type storedVectorRecord struct {
+	Id        string         `json:"id"`
+	Prompt    string         `json:"prompt"`
+	Embedding []float64      `json:"embedding"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// toStoredVectorRecord returns the stored form of a vector record.
+func toStoredVectorRecord(record VectorRecord) storedVectorRecord {
+	return storedVectorRecord{Id: record.Id, Prompt: record.Prompt, Embedding: record.Embedding, Metadata: record.Metadata}
+}
+
+// vectorRecord returns the vector record of a stored record.
+func (stored storedVectorRecord) vectorRecord() VectorRecord {
+	return VectorRecord{Id: stored.Id, Prompt: stored.Prompt, Embedding: stored.Embedding, Metadata: stored.Metadata}
+}
+
+// searchSimilarities returns the records matched by the filters with a cosine similarity greater than or equal to limit,
+// with their similarity. It is used by the vector stores that cannot compute the similarities themselves.
+func searchSimilarities(records []VectorRecord, embeddingFromQuestion VectorRecord, limit float64, filters []Filter) []VectorRecord {
+	var similarities []VectorRecord
+	for _, record := range records {
+		if !matchFilters(record, filters) {
+			continue
+		}
+		similarity := cosineSimilarity(embeddingFromQuestion.Embedding, record.Embedding)
+		if similarity >= limit {
+			record.CosineSimilarity = similarity
+			similarities = append(similarities, record)
+		}
+	}
+	return similarities
+}
+
+// vectorStore returns the vector store of the Agent, or an error if the Agent has no RAG memory.
+func (agent *Agent) vectorStore() (VectorStore, error) {
+	if agent.Store == nil {
+		return nil, fmt.Errorf("no vector store: use WithRAGMemory or WithVectorStore")
+	}
+	return agent.Store, nil
+}
@@ -0,0 +1,179 @@
+package robby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRedisVectorStoreKey is the key of the Redis hash of the records of a RedisVectorStore.
+const DefaultRedisVectorStoreKey = "robby:vectors"
+
+// redisTransactionAttempts is the number of attempts of a DeleteWhere or UpdateWhere transaction
+// when the hash is changed by another client in the meantime.
+const redisTransactionAttempts = 10
+
+// RedisVectorStore is a VectorStore in a Redis hash (or a Redis compatible server: Valkey, KeyDB...):
+// every record is a field of the hash, with its ID as the name and the record in JSON as the value.
+// The searches read all the records of the hash (no Redis module is needed).
+type RedisVectorStore struct {
+	ctx    context.Context
+	client redis.UniversalClient
+	key    string
+}
+
+// NewRedisVectorStore creates a RedisVectorStore using the Redis client (e.g. redis.NewClient(&redis.Options{Addr: "localhost:6379"}))
+// and the key of the hash of the records. The client is not closed by the store.
+func NewRedisVectorStore(ctx context.Context, client redis.UniversalClient, key string) (*RedisVectorStore, error) {
+	if key == "" {
+		key = DefaultRedisVectorStoreKey
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return &RedisVectorStore{ctx: ctx, client: client, key: key}, nil
+}
+
+// Save implements VectorStore.
+func (store *RedisVectorStore) Save(vectorRecord VectorRecord) (VectorRecord, error) {
+	if vectorRecord.Id == "" {
+		vectorRecord.Id = uuid.New().String()
+	}
+	data, err := json.Marshal(toStoredVectorRecord(vectorRecord))
+	if err != nil {
+		return vectorRecord, err
+	}
+	return vectorRecord, store.client.HSet(store.ctx, store.key, vectorRecord.Id, data).Err()
+}
+
+// Delete implements VectorStore.
+func (store *RedisVectorStore) Delete(id string) error {
+	deleted, err := store.client.HDel(store.ctx, store.key, id).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrVectorRecordNotFound
+	}
+	return nil
+}
+
+// GetAll implements VectorStore.
+func (store *RedisVectorStore) GetAll() ([]VectorRecord, error) {
+	values, err := store.client.HGetAll(store.ctx, store.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeRedisVectorRecords(values)
+}
+
+// decodeRedisVectorRecords returns the records of the fields of the hash.
+func decodeRedisVectorRecords(values map[string]string) ([]VectorRecord, error) {
+	records := make([]VectorRecord, 0, len(values))
+	for id, value := range values {
+		stored := storedVectorRecord{}
+		if err := json.Unmarshal([]byte(value), &stored); err != nil {
+			return nil, fmt.Errorf("invalid vector record %s: %w", id, err)
+		}
+		records = append(records, stored.vectorRecord())
+	}
+	return records, nil
+}
+
+// SearchSimilarities implements VectorStore.
+func (store *RedisVectorStore) SearchSimilarities(embeddingFromQuestion VectorRecord, limit float64, filters ...Filter) ([]VectorRecord, error) {
+	records, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return searchSimilarities(records, embeddingFromQuestion, limit, filters), nil
+}
+
+// SearchTopNSimilarities implements VectorStore.
+func (store *RedisVectorStore) SearchTopNSimilarities(embeddingFromQuestion VectorRecord, limit float64, max int, filters ...Filter) ([]VectorRecord, error) {
+	records, err := store.SearchSimilarities(embeddingFromQuestion, limit, filters...)
+	if err != nil {
+		return nil, err
+	}
+	return getTopNVectorRecords(records, max), nil
+}
+
+// DeleteWhere implements VectorStore. The records are read and the matched records are removed atomically
+// (WATCH/MULTI transaction, retried if the hash is changed by another client in the meantime).
+func (store *RedisVectorStore) DeleteWhere(filter Filter) (int, error) {
+	if filter == nil {
+		return 0, errNoFilter
+	}
+	return store.transaction(func(records []VectorRecord, pipe redis.Pipeliner) (int, error) {
+		ids := []string{}
+		for _, record := range records {
+			if filter.Match(record.Metadata) {
+				ids = append(ids, record.Id)
+			}
+		}
+		if len(ids) > 0 {
+			pipe.HDel(store.ctx, store.key, ids...)
+		}
+		return len(ids), nil
+	})
+}
+
+// UpdateWhere implements VectorStore. The records are read and the matched records are updated atomically
+// (WATCH/MULTI transaction, retried if the hash is changed by another client in the meantime).
+func (store *RedisVectorStore) UpdateWhere(filter Filter, metadata map[string]any) (int, error) {
+	if filter == nil {
+		return 0, errNoFilter
+	}
+	return store.transaction(func(records []VectorRecord, pipe redis.Pipeliner) (int, error) {
+		values := []any{}
+		for _, record := range records {
+			if filter.Match(record.Metadata) {
+				record.Metadata = updateMetadata(record.Metadata, metadata)
+				data, err := json.Marshal(toStoredVectorRecord(record))
+				if err != nil {
+					return 0, err
+				}
+				values = append(values, record.Id, data)
+			}
+		}
+		if len(values) > 0 {
+			pipe.HSet(store.ctx, store.key, values...)
+		}
+		return len(values) / 2, nil
+	})
+}
+
+// transaction reads the records of the hash and queues the commands of the update function in a transaction,
+// executed only if the hash was not changed since it was read (it is retried otherwise).
+// It returns the number of records changed by the update function.
+func (store *RedisVectorStore) transaction(update func(records []VectorRecord, pipe redis.Pipeliner) (int, error)) (int, error) {
+	for attempt := 0; attempt < redisTransactionAttempts; attempt++ {
+		count := 0
+		err := store.client.Watch(store.ctx, func(tx *redis.Tx) error {
+			values, err := tx.HGetAll(store.ctx, store.key).Result()
+			if err != nil {
+				return err
+			}
+			records, err := decodeRedisVectorRecords(values)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(store.ctx, func(pipe redis.Pipeliner) error {
+				count, err = update(records, pipe)
+				return err
+			})
+			return err
+		}, store.key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err != nil {
+				return 0, err
+			}
+			return count, nil
+		}
+	}
+	return 0, fmt.Errorf("the vector store %s is changed too often: %w", store.key, redis.TxFailedErr)
+}
@@ -0,0 +1,47 @@
+package robby
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	published := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	metadata := map[string]any{
+		"source":    "avengers.md",
+		"chunk":     float64(3), // the numbers read from JSON are float64
+		"tags":      []any{"spy", "tv"},
+		"published": published.Format(time.RFC3339), // the times read from JSON are strings
+	}
+
+	for name, test := range map[string]struct {
+		filter   Filter
+		expected bool
+	}{
+		"eq":                  {Eq("source", "avengers.md"), true},
+		"eq int":              {Eq("chunk", 3), true},
+		"ne":                  {Ne("source", "avengers.md"), false},
+		"ne missing key":      {Ne("title", "x"), true},
+		"in":                  {In("chunk", 1, 2, 3), true},
+		"in missing":          {In("source", "mother.md"), false},
+		"contains":            {Contains("tags", "spy"), true},
+		"contains not a list": {Contains("source", "avengers.md"), false},
+		"exists":              {Exists("tags"), true},
+		"gt":                  {Gt("chunk", 2), true},
+		"gte":                 {Gte("chunk", int64(3)), true},
+		"lt":                  {Lt("chunk", 3), false},
+		"lte string":          {Lte("source", "b"), true},
+		"gt time":             {Gt("published", published.Add(-time.Hour)), true},
+		"lt time":             {Lt("published", published), false},
+		"gt incomparable":     {Gt("source", 1), false},
+		"and":                 {And(Eq("source", "avengers.md"), Gt("chunk", 5)), false},
+		"and empty":           {And(), true},
+		"or":                  {Or(Eq("source", "mother.md"), Contains("tags", "tv")), true},
+		"not":                 {Not(Exists("title")), true},
+		"func":                {FilterFunc(func(metadata map[string]any) bool { return len(metadata) == 4 }), true},
+	} {
+		if actual := test.filter.Match(metadata); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, actual)
+		}
+	}
+}
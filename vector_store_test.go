@@ -0,0 +1,200 @@
+package robby
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeEmbedding returns an embedding of the text that only depends on the characters it mentions.
+func fakeEmbedding(text string) []float64 {
+	switch {
+	case strings.Contains(text, "Emma"):
+		return []float64{1, 0.1, 0}
+	case strings.Contains(text, "Steed"):
+		return []float64{0.1, 1, 0}
+	default:
+		return []float64{0, 0, 1}
+	}
+}
+
+// checkVectorStore checks the operations of a VectorStore.
+func checkVectorStore(t *testing.T, store VectorStore) {
+	t.Helper()
+	for _, record := range []VectorRecord{
+		{Id: "emma", Prompt: "Emma Peel", Embedding: fakeEmbedding("Emma"), Metadata: map[string]any{"source": "avengers.md", "chunk": 0}},
+		{Id: "steed", Prompt: "John Steed", Embedding: fakeEmbedding("Steed"), Metadata: map[string]any{"source": "avengers.md", "chunk": 1}},
+		{Prompt: "Mother", Embedding: fakeEmbedding("Mother"), Metadata: map[string]any{"source": "mother.md"}},
+	} {
+		saved, err := store.Save(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Id == "" {
+			t.Fatal("expected a generated ID")
+		}
+	}
+
+	records, err := store.GetAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d, %v", len(records), err)
+	}
+
+	similarities, err := store.SearchSimilarities(VectorRecord{Embedding: fakeEmbedding("Emma")}, 0.5)
+	if err != nil || len(similarities) != 1 || similarities[0].Id != "emma" || similarities[0].CosineSimilarity < 0.99 {
+		t.Fatalf("unexpected similarities: %+v, %v", similarities, err)
+	}
+	top, err := store.SearchTopNSimilarities(VectorRecord{Embedding: fakeEmbedding("Steed")}, 0, 2)
+	if err != nil || len(top) != 2 || top[0].Id != "steed" || top[1].Id != "emma" {
+		t.Fatalf("unexpected top similarities: %+v, %v", top, err)
+	}
+
+	// Metadata filters
+	filtered, err := store.SearchTopNSimilarities(VectorRecord{Embedding: fakeEmbedding("Steed")}, 0, 3, Eq("source", "avengers.md"), Gt("chunk", 0))
+	if err != nil || len(filtered) != 1 || filtered[0].Id != "steed" || filtered[0].Metadata["source"] != "avengers.md" {
+		t.Fatalf("unexpected filtered similarities: %+v, %v", filtered, err)
+	}
+	updated, err := store.UpdateWhere(Eq("source", "avengers.md"), map[string]any{"series": "The Avengers", "chunk": nil})
+	if err != nil || updated != 2 {
+		t.Fatalf("expected 2 updated records, got %d, %v", updated, err)
+	}
+	filtered, _ = store.SearchSimilarities(VectorRecord{Embedding: fakeEmbedding("Emma")}, 0, And(Exists("series"), Not(Exists("chunk"))))
+	if len(filtered) != 2 {
+		t.Fatalf("expected 2 updated records, got %+v", filtered)
+	}
+	if _, err := store.DeleteWhere(nil); err == nil {
+		t.Fatal("expected an error without a filter")
+	}
+	if deleted, err := store.DeleteWhere(Eq("source", "mother.md")); err != nil || deleted != 1 {
+		t.Fatalf("expected 1 deleted record, got %d, %v", deleted, err)
+	}
+
+	if err := store.Delete("emma"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("emma"); !errors.Is(err, ErrVectorRecordNotFound) {
+		t.Fatalf("expected ErrVectorRecordNotFound, got %v", err)
+	}
+	if records, _ := store.GetAll(); len(records) != 1 || records[0].Metadata["series"] != "The Avengers" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestMemoryVectorStore(t *testing.T) {
+	checkVectorStore(t, &MemoryVectorStore{})
+}
+
+func TestBoltVectorStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.db")
+	store, err := NewBoltVectorStore(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVectorStore(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The records are kept in the file
+	store, err = NewBoltVectorStore(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if records, err := store.GetAll(); err != nil || len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d, %v", len(records), err)
+	}
+}
+
+func TestRedisVectorStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store, err := NewRedisVectorStore(context.Background(), client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVectorStore(t, store)
+	if !server.Exists(DefaultRedisVectorStoreKey) {
+		t.Fatalf("expected the hash %s", DefaultRedisVectorStoreKey)
+	}
+}
+
+func TestRedisVectorStoreConcurrentUpdates(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store, err := NewRedisVectorStore(context.Background(), client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"emma", "steed", "mother"} {
+		if _, err := store.Save(VectorRecord{Id: id, Prompt: id, Embedding: []float64{1, 0}, Metadata: map[string]any{"show": "avengers"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Every update reads and writes all the records: none of them must be lost
+	const updates = 10
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.UpdateWhere(Eq("show", "avengers"), map[string]any{fmt.Sprintf("tag%d", i): true}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := store.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if len(record.Metadata) != updates+1 {
+			t.Errorf("record %s: %d metadata, want %d: %v", record.Id, len(record.Metadata), updates+1, record.Metadata)
+		}
+	}
+}
+
+func TestWithRAGMemoryAndVectorStore(t *testing.T) {
+	fake := newFakeEmbeddingsDMR(t, fakeEmbedding, nil)
+	store, err := NewBoltVectorStore(filepath.Join(t.TempDir(), "vectors.db"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	bob, err := NewAgent(
+		fake.Option(),
+		WithEmbeddingParams(openai.EmbeddingNewParams{Model: "fake-embeddings"}),
+		WithVectorStore(store),
+		WithRAGMemory([]string{"Emma Peel is a secret agent", "John Steed wears a bowler hat", "Mother is the superior"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := store.GetAll(); len(records) != 3 {
+		t.Fatalf("expected 3 records in the store, got %d", len(records))
+	}
+
+	similarities, err := bob.RAGMemorySearchSimilaritiesWithText("Who is Emma?", 0.9)
+	if err != nil || len(similarities) != 1 || similarities[0] != "Emma Peel is a secret agent" {
+		t.Fatalf("unexpected similarities: %v, %v", similarities, err)
+	}
+
+	if _, err := (&Agent{}).RAGMemorySearchSimilaritiesWithText("Who is Emma?", 0.9); err == nil {
+		t.Fatal("expected an error without a vector store")
+	}
+}